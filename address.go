@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/openrdap/rdap"
+)
+
+// adr components, in the order RFC 6350 section 6.3.1 lists them.
+const (
+	adrPOBox = iota
+	adrExtended
+	adrStreet
+	adrLocality
+	adrRegion
+	adrPostalCode
+	adrCountry
+	adrComponents
+)
+
+// Countries writing the postal code before the locality ("10117 Berlin"),
+// and countries writing addresses from the largest unit down to the street.
+var (
+	postalCodeFirstCountries = map[string]bool{
+		"AT": true, "BE": true, "CH": true, "CZ": true, "DE": true, "DK": true, "ES": true, "FI": true,
+		"FR": true, "IT": true, "LU": true, "NL": true, "NO": true, "PL": true, "PT": true, "SE": true,
+	}
+	largestFirstCountries = map[string]bool{"CN": true, "JP": true, "KR": true, "TW": true}
+)
+
+// formatAddress renders the postal address of vcard on one line. An
+// address given in several languages is rendered in the message language
+// when available. The label parameter, the address as the registrant wrote
+// it, wins over the structured components, which are otherwise ordered as
+// the country (the cc parameter, RFC 8605) writes them.
+func formatAddress(vcard *rdap.VCard) string {
+	property := preferredAddress(vcard.Get("adr"))
+	if property == nil {
+		return ""
+	}
+	if labels := property.Parameters["label"]; len(labels) > 0 {
+		if label := joinNonEmpty(strings.Split(strings.Join(labels, "\n"), "\n"), ", "); label != "" {
+			return label
+		}
+	}
+	components := addressComponents(property.Value)
+	country := ""
+	if codes := property.Parameters["cc"]; len(codes) > 0 {
+		country = strings.ToUpper(strings.TrimSpace(codes[0]))
+	}
+	street := joinNonEmpty([]string{components[adrPOBox], components[adrExtended], components[adrStreet]}, ", ")
+	switch {
+	case largestFirstCountries[country]:
+		return joinNonEmpty([]string{components[adrCountry], components[adrPostalCode], components[adrRegion], components[adrLocality], street}, ", ")
+	case postalCodeFirstCountries[country]:
+		town := joinNonEmpty([]string{components[adrPostalCode], components[adrLocality]}, " ")
+		return joinNonEmpty([]string{street, town, components[adrRegion], components[adrCountry]}, ", ")
+	default:
+		town := joinNonEmpty([]string{components[adrLocality], joinNonEmpty([]string{components[adrRegion], components[adrPostalCode]}, " ")}, ", ")
+		return joinNonEmpty([]string{street, town, components[adrCountry]}, ", ")
+	}
+}
+
+// preferredAddress picks the adr property in the message language, or
+// else the first one.
+func preferredAddress(properties []*rdap.VCardProperty) *rdap.VCardProperty {
+	for _, property := range properties {
+		for _, language := range property.Parameters["language"] {
+			primary, _, _ := strings.Cut(strings.ToLower(language), "-")
+			if primary == messageLanguage {
+				return property
+			}
+		}
+	}
+	if len(properties) == 0 {
+		return nil
+	}
+	return properties[0]
+}
+
+// addressComponents returns the seven adr components of value, each with
+// its values (a street may have several lines) joined by ", ".
+func addressComponents(value any) [adrComponents]string {
+	var components [adrComponents]string
+	values, ok := value.([]any)
+	if !ok {
+		return components
+	}
+	for index := range min(len(values), adrComponents) {
+		switch component := values[index].(type) {
+		case string:
+			components[index] = strings.TrimSpace(component)
+		case []any:
+			var parts []string
+			for _, part := range component {
+				if text, ok := part.(string); ok {
+					parts = append(parts, text)
+				}
+			}
+			components[index] = joinNonEmpty(parts, ", ")
+		}
+	}
+	return components
+}
+
+// joinNonEmpty joins the parts that are not blank.
+func joinNonEmpty(parts []string, separator string) string {
+	var kept []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, separator)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+)
+
+// errNotArchived means an archive source holds no record for the target at
+// or before the requested time.
+var errNotArchived = errors.New("no archived record")
+
+// archiveFlag lists the archive sources consulted by --as-of, in order. Each
+// is "snapshots" (the dated snapshots every lookup, watch and canary run
+// keeps) or an HTTP URL template whose {asn}, {date} and {time} placeholders
+// are filled in and which must answer with an RDAP autnum document, e.g. a
+// WHOIS-history service fronted by an RDAP-shaped API.
+type archiveFlag []string
+
+func (f *archiveFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *archiveFlag) Set(value string) error {
+	for _, source := range strings.Split(value, ",") {
+		source = strings.TrimSpace(source)
+		if source != "snapshots" && !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+			return fmt.Errorf("unknown archive source %q (want snapshots or an http(s) URL template)", source)
+		}
+		*f = append(*f, source)
+	}
+	return nil
+}
+
+// parseAsOf accepts a date (meaning the end of that day, UTC) or an RFC 3339
+// timestamp.
+func parseAsOf(text string) (time.Time, error) {
+	if day, err := time.Parse(time.DateOnly, text); err == nil {
+		return day.Add(24*time.Hour - time.Second), nil
+	}
+	moment, err := time.Parse(time.RFC3339, text)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -as-of %q (want 2006-01-02 or an RFC 3339 time)", text)
+	}
+	return moment.UTC(), nil
+}
+
+// historicalAnswer records where a historical result came from.
+type historicalAnswer struct {
+	Source     string    `json:"source"`
+	CapturedAt time.Time `json:"captured_at"`
+}
+
+// historicalLookup answers asn from the first archive source that has a
+// record at or before asOf. Results are never taken from live registries.
+func historicalLookup(ctx context.Context, httpClient *http.Client, sources []string, asn int64, asOf time.Time) (string, *autnumFetch, *historicalAnswer, error) {
+	key := "AS" + strconv.FormatInt(asn, 10)
+	var searched []string
+	for _, source := range sources {
+		var rawBody []byte
+		var err error
+		answer := &historicalAnswer{Source: source, CapturedAt: asOf}
+		if source == "snapshots" {
+			rawBody, answer.CapturedAt, err = loadSnapshotAsOf(key, asOf)
+		} else {
+			rawBody, answer, err = fetchArchived(ctx, httpClient, source, asn, asOf)
+		}
+		if errors.Is(err, errNotArchived) {
+			searched = append(searched, answer.Source)
+			continue
+		}
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("archive %s: %w", answer.Source, err)
+		}
+		record, err := decodeAutnum(rawBody)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("archive %s: %w", answer.Source, err)
+		}
+		fetch := &autnumFetch{Record: record, RawBody: rawBody}
+		return rdaplookup.ExtractName(record), fetch, answer, nil
+	}
+	return "", nil, nil, fmt.Errorf("%w as of %s (searched %s)", errNotArchived, asOf.Format(time.RFC3339), strings.Join(searched, ", "))
+}
+
+// fetchArchived queries an HTTP archive source. A 404 means the source has
+// nothing for that time; Last-Modified, when sent, is taken as the capture
+// time.
+func fetchArchived(ctx context.Context, httpClient *http.Client, template string, asn int64, asOf time.Time) ([]byte, *historicalAnswer, error) {
+	replacer := strings.NewReplacer(
+		"{asn}", strconv.FormatInt(asn, 10),
+		"{date}", asOf.Format(time.DateOnly),
+		"{time}", url.QueryEscape(asOf.Format(time.RFC3339)),
+	)
+	archiveURL := replacer.Replace(template)
+	answer := &historicalAnswer{Source: template, CapturedAt: asOf}
+	if parsed, err := url.Parse(archiveURL); err == nil {
+		answer.Source = parsed.Host
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, archiveURL, nil)
+	if err != nil {
+		return nil, answer, err
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, answer, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, answer, errNotArchived
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, answer, fmt.Errorf("HTTP %s", response.Status)
+	}
+	if modified, err := http.ParseTime(response.Header.Get("Last-Modified")); err == nil {
+		answer.CapturedAt = modified.UTC()
+	}
+	rawBody, err := io.ReadAll(response.Body)
+	return rawBody, answer, err
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// upstreamCredential authenticates the requests sent under one base URL:
+// a static bearer token, HTTP basic auth, or OAuth2 client credentials (as
+// used by RFC 9560 federated RDAP deployments).
+type upstreamCredential struct {
+	BaseURL string // requests whose URL starts with it are authenticated
+	Scheme  string // bearer, basic or oauth2
+
+	Token              string // bearer
+	Username, Password string // basic
+
+	TokenURL               string // oauth2
+	ClientID, ClientSecret string
+	Scopes                 []string
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// upstreamCredentials is the parsed auth file.
+type upstreamCredentials []*upstreamCredential
+
+// defaultAuthPath returns $XDG_CONFIG_HOME/rdap-tester/auth, defaulting to
+// ~/.config/rdap-tester/auth.
+func defaultAuthPath() (string, error) {
+	return configFilePath("auth")
+}
+
+// loadAuth reads an auth file with one credential per line:
+//
+//	base-URL bearer token
+//	base-URL basic username password
+//	base-URL oauth2 token-URL client-id client-secret [scope...]
+//
+// Any secret may be written as env:NAME to read it from the environment
+// instead. Blank lines and lines starting with # are ignored. A missing file
+// is not an error when optional is set.
+func loadAuth(path string, optional bool) (upstreamCredentials, error) {
+	file, err := os.Open(path)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var credentials upstreamCredentials
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		credential, err := parseUpstreamCredential(strings.Fields(line))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials, scanner.Err()
+}
+
+func parseUpstreamCredential(fields []string) (*upstreamCredential, error) {
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("expected \"base-URL scheme ...\"")
+	}
+	parsed, err := url.Parse(fields[0])
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", fields[0])
+	}
+	credential := &upstreamCredential{BaseURL: fields[0], Scheme: strings.ToLower(fields[1])}
+	arguments := fields[2:]
+	for index, argument := range arguments {
+		if name, ok := strings.CutPrefix(argument, "env:"); ok {
+			if arguments[index] = os.Getenv(name); arguments[index] == "" {
+				return nil, fmt.Errorf("environment variable %s is empty", name)
+			}
+		}
+	}
+	switch {
+	case credential.Scheme == "bearer" && len(arguments) == 1:
+		credential.Token = arguments[0]
+	case credential.Scheme == "basic" && len(arguments) == 2:
+		credential.Username, credential.Password = arguments[0], arguments[1]
+	case credential.Scheme == "oauth2" && len(arguments) >= 3:
+		credential.TokenURL, credential.ClientID, credential.ClientSecret = arguments[0], arguments[1], arguments[2]
+		credential.Scopes = arguments[3:]
+	case credential.Scheme == "bearer", credential.Scheme == "basic", credential.Scheme == "oauth2":
+		return nil, fmt.Errorf("wrong number of arguments for %s (want bearer token, basic username password or oauth2 token-URL client-id client-secret [scope...])", credential.Scheme)
+	default:
+		return nil, fmt.Errorf("unknown auth scheme %q (want bearer, basic or oauth2)", fields[1])
+	}
+	return credential, nil
+}
+
+// forURL returns the credential with the longest base URL prefixing rawURL.
+func (c upstreamCredentials) forURL(rawURL string) *upstreamCredential {
+	var best *upstreamCredential
+	for _, candidate := range c {
+		if strings.HasPrefix(rawURL, candidate.BaseURL) && (best == nil || len(candidate.BaseURL) > len(best.BaseURL)) {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// authTransport adds the Authorization header of the matching credential.
+// It matches every request, redirects included, by its own URL, so a
+// redirect to another server never carries the credential along.
+type authTransport struct {
+	base        http.RoundTripper
+	credentials upstreamCredentials
+}
+
+func (t *authTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	credential := t.credentials.forURL(request.URL.String())
+	if credential == nil {
+		return t.base.RoundTrip(request)
+	}
+	request = request.Clone(request.Context())
+	switch credential.Scheme {
+	case "bearer":
+		request.Header.Set("Authorization", "Bearer "+credential.Token)
+	case "basic":
+		request.SetBasicAuth(credential.Username, credential.Password)
+	case "oauth2":
+		token, err := credential.oauth2Token(t.base)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2 token for %s: %v", credential.BaseURL, err)
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return t.base.RoundTrip(request)
+}
+
+// oauth2Token returns a cached access token, fetching a new one with the
+// client credentials grant (RFC 6749 section 4.4) shortly before it expires.
+func (c *upstreamCredential) oauth2Token(transport http.RoundTripper) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessToken != "" && time.Now().Before(c.expiresAt) {
+		return c.accessToken, nil
+	}
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	request, err := http.NewRequest(http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	response, err := (&http.Client{Transport: transport, Timeout: 10 * time.Second}).Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("token endpoint returned %s: %v", response.Status, err)
+	}
+	if response.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("token endpoint returned %s: %s %s", response.Status, token.Error, token.ErrorDescription)
+	}
+	c.accessToken = token.AccessToken
+	c.expiresAt = time.Now().Add(time.Hour)
+	if token.ExpiresIn > 0 {
+		// Refresh a little early so a token never expires in flight.
+		c.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - min(30*time.Second, time.Duration(token.ExpiresIn)*time.Second/2))
+	}
+	return c.accessToken, nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/openrdap/rdap/bootstrap"
+	"github.com/openrdap/rdap/bootstrap/cache"
+)
+
+// bootstrapFiles holds downloaded bootstrap registry files for several
+// bootstrap clients. Each client parses its own copy, since openrdap
+// mutates parsed service URLs in place, but only one has to download.
+// Files are also kept in dir, so a run only downloads the ones older
+// than timeout (-bootstrap-ttl).
+type bootstrapFiles struct {
+	mutex   sync.Mutex
+	dir     string // empty keeps files in memory only
+	timeout time.Duration
+	files   map[string]bootstrapFile
+	// fallback marks files bootstrapFallbackTransport served from the
+	// cache in place of a download, which Save must not take for fresh.
+	fallback map[string]bool
+}
+
+type bootstrapFile struct {
+	data  []byte
+	saved time.Time
+}
+
+func newBootstrapFiles(dir string, timeout time.Duration) *bootstrapFiles {
+	return &bootstrapFiles{dir: dir, timeout: timeout, files: map[string]bootstrapFile{}, fallback: map[string]bool{}}
+}
+
+// bootstrapCacheDir returns the bootstrap directory under cacheHomeDir.
+func bootstrapCacheDir() (string, error) {
+	home, err := cacheHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "bootstrap"), nil
+}
+
+// file returns filename from memory, reading it from dir the first time it
+// is asked for, with its modification time as when it was saved. The
+// caller holds the mutex.
+func (f *bootstrapFiles) file(filename string) (bootstrapFile, bool) {
+	if file, ok := f.files[filename]; ok || f.dir == "" {
+		return file, ok
+	}
+	path := filepath.Join(f.dir, filename)
+	info, err := os.Stat(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("bootstrap cache unreadable", "file", path, "error", err)
+		}
+		return bootstrapFile{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		slog.Warn("bootstrap cache unreadable", "file", path, "error", err)
+		return bootstrapFile{}, false
+	}
+	file := bootstrapFile{data: data, saved: info.ModTime()}
+	f.files[filename] = file
+	return file, true
+}
+
+// cached returns the data of filename and when it was saved, however old.
+func (f *bootstrapFiles) cached(filename string) (bootstrapFile, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.file(filename)
+}
+
+// view returns the cache one bootstrap client uses over the shared files.
+func (f *bootstrapFiles) view() *bootstrapFileCache {
+	return &bootstrapFileCache{files: f, loaded: map[string]time.Time{}}
+}
+
+// bootstrapFileCache is one client's cache.RegistryCache over shared
+// bootstrapFiles. It reports ShouldReload for files another client saved
+// since this one last loaded them, as openrdap's DiskCache does for a
+// shared directory.
+type bootstrapFileCache struct {
+	files *bootstrapFiles
+	// loaded records when each file this client parsed was saved.
+	loaded map[string]time.Time
+}
+
+func (c *bootstrapFileCache) Load(filename string) ([]byte, error) {
+	c.files.mutex.Lock()
+	defer c.files.mutex.Unlock()
+	file, ok := c.files.file(filename)
+	if !ok {
+		return nil, fmt.Errorf("file %s not in cache", filename)
+	}
+	c.loaded[filename] = file.saved
+	return append([]byte(nil), file.data...), nil
+}
+
+func (c *bootstrapFileCache) Save(filename string, data []byte) error {
+	c.files.mutex.Lock()
+	defer c.files.mutex.Unlock()
+	if c.files.fallback[filename] {
+		// The cached copy came back: it stays as old as it was.
+		delete(c.files.fallback, filename)
+		c.loaded[filename] = c.files.files[filename].saved
+		return nil
+	}
+	now := time.Now()
+	c.files.files[filename] = bootstrapFile{data: append([]byte(nil), data...), saved: now}
+	c.loaded[filename] = now
+	if c.files.dir != "" {
+		// A cache that cannot be written only costs the next run a download.
+		if err := writeCacheEntry(filepath.Join(c.files.dir, filename), data); err != nil {
+			slog.Warn("bootstrap cache not written", "file", filename, "error", err)
+		}
+	}
+	return nil
+}
+
+func (c *bootstrapFileCache) State(filename string) cache.FileState {
+	c.files.mutex.Lock()
+	defer c.files.mutex.Unlock()
+	file, ok := c.files.file(filename)
+	switch {
+	case !ok:
+		return cache.Absent
+	case time.Since(file.saved) > c.files.timeout:
+		return cache.Expired
+	case !c.loaded[filename].Equal(file.saved):
+		return cache.ShouldReload
+	default:
+		return cache.Good
+	}
+}
+
+func (c *bootstrapFileCache) SetTimeout(timeout time.Duration) {
+	c.files.mutex.Lock()
+	defer c.files.mutex.Unlock()
+	c.files.timeout = timeout
+}
+
+// bootstrapCacheName returns the name openrdap caches a registry file
+// downloaded from baseURL under: the file name, prefixed with a hash of
+// the base URL unless it is IANA's.
+func bootstrapCacheName(baseURL *url.URL, filename string) string {
+	if baseURL == nil || baseURL.String() == bootstrap.DefaultBaseURL {
+		return filename
+	}
+	hash := sha256.Sum256([]byte(baseURL.String()))
+	return hex.EncodeToString(hash[:])[:6] + "_" + filename
+}
+
+// bootstrapFallbackTransport answers a bootstrap registry download that
+// fails, by a network error or a server error, with the cached copy of the
+// file when -offline-bootstrap allows it; otherwise the error says such a
+// copy exists.
+type bootstrapFallbackTransport struct {
+	base    http.RoundTripper
+	files   *bootstrapFiles
+	allowed bool
+	// baseURL is the bootstrap client's base URL; nil for IANA's.
+	baseURL *url.URL
+}
+
+func (t *bootstrapFallbackTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := t.base.RoundTrip(request)
+	if err == nil && response.StatusCode < http.StatusInternalServerError {
+		return response, nil
+	}
+	filename := bootstrapCacheName(t.baseURL, path.Base(request.URL.Path))
+	file, ok := t.files.cached(filename)
+	if !ok {
+		return response, err
+	}
+	failure := err
+	if failure == nil {
+		failure = fmt.Errorf("%s", response.Status)
+	}
+	age := time.Since(file.saved).Round(time.Second)
+	if !t.allowed {
+		if err != nil {
+			return nil, fmt.Errorf("%w (a cached copy %s old exists; -offline-bootstrap uses it)", err, age)
+		}
+		return response, nil
+	}
+	if response != nil {
+		response.Body.Close()
+	}
+	slog.Warn("bootstrap registry unreachable, using the cached copy", "file", filename, "age", age, "error", failure)
+	t.files.mutex.Lock()
+	t.files.fallback[filename] = true
+	t.files.mutex.Unlock()
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(file.data)),
+		ContentLength: int64(len(file.data)),
+		Request:       request,
+	}, nil
+}
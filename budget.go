@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// budgetPeriods are the accounting windows a budget can use. Windows are
+// aligned to UTC.
+var budgetPeriods = map[string]time.Duration{
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+}
+
+// registryBudget caps the queries sent to one registry per period.
+type registryBudget struct {
+	Registry string // as named by registryForURL, compared case-insensitively
+	Limit    int
+	Period   string
+}
+
+// budgetFlag implements -budget arin=1000/day (comma-separated, repeatable).
+type budgetFlag []registryBudget
+
+func (b *budgetFlag) String() string {
+	if b == nil {
+		return ""
+	}
+	var parts []string
+	for _, budget := range *b {
+		parts = append(parts, fmt.Sprintf("%s=%d/%s", strings.ToLower(budget.Registry), budget.Limit, budget.Period))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (b *budgetFlag) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		registry, quota, ok := strings.Cut(strings.TrimSpace(part), "=")
+		limitText, period, hasPeriod := strings.Cut(quota, "/")
+		limit, err := strconv.Atoi(limitText)
+		if !ok || registry == "" || !hasPeriod || err != nil || limit < 0 {
+			return fmt.Errorf("invalid budget %q (want registry=N/day, /hour or /minute)", part)
+		}
+		if _, known := budgetPeriods[period]; !known {
+			return fmt.Errorf("invalid budget period %q (want day, hour or minute)", period)
+		}
+		*b = append(*b, registryBudget{Registry: registry, Limit: limit, Period: period})
+	}
+	return nil
+}
+
+// periodKey names the window containing now, e.g. "day:2026-10-15".
+func periodKey(period string, now time.Time) string {
+	return period + ":" + now.UTC().Truncate(budgetPeriods[period]).Format(time.RFC3339)
+}
+
+// queryCounter is the persistent per-registry count of queries sent, kept in
+// $XDG_DATA_HOME/rdaptester/query-counts.json and shared by every client in
+// the process. Counts for windows that have ended are dropped on save.
+type queryCounter struct {
+	mutex  sync.Mutex
+	loaded bool
+	path   string
+	Counts map[string]map[string]int `json:"counts"` // registry -> period key -> queries
+}
+
+var registryQueryCounter = &queryCounter{}
+
+func queryCounterPath() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "rdaptester", "query-counts.json"), nil
+}
+
+// load reads the counter file once; the caller holds the mutex.
+func (c *queryCounter) load() error {
+	if c.loaded {
+		return nil
+	}
+	c.loaded = true
+	c.Counts = map[string]map[string]int{}
+	path, err := queryCounterPath()
+	if err != nil {
+		return err
+	}
+	c.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if c.Counts == nil {
+		c.Counts = map[string]map[string]int{}
+	}
+	return nil
+}
+
+// save writes the counts; the caller holds the mutex.
+func (c *queryCounter) save(now time.Time) error {
+	if c.path == "" {
+		return nil
+	}
+	current := map[string]bool{}
+	for period := range budgetPeriods {
+		current[periodKey(period, now)] = true
+	}
+	for registry, windows := range c.Counts {
+		for key := range windows {
+			if !current[key] {
+				delete(windows, key)
+			}
+		}
+		if len(windows) == 0 {
+			delete(c.Counts, registry)
+		}
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+	temporary := c.path + ".tmp"
+	if err := os.WriteFile(temporary, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(temporary, c.path)
+}
+
+// reserve counts one query to registry unless that would exceed one of the
+// budgets. On refusal it returns the exhausted budget and when its window ends.
+func (c *queryCounter) reserve(registry string, budgets []registryBudget, now time.Time) (*registryBudget, time.Time, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.load(); err != nil {
+		return nil, time.Time{}, err
+	}
+	windows := c.Counts[registry]
+	for index := range budgets {
+		budget := &budgets[index]
+		if !strings.EqualFold(budget.Registry, registry) {
+			continue
+		}
+		if windows[periodKey(budget.Period, now)] >= budget.Limit {
+			period := budgetPeriods[budget.Period]
+			return budget, now.UTC().Truncate(period).Add(period), nil
+		}
+	}
+	if windows == nil {
+		windows = map[string]int{}
+		c.Counts[registry] = windows
+	}
+	for period := range budgetPeriods {
+		windows[periodKey(period, now)]++
+	}
+	return nil, time.Time{}, c.save(now)
+}
+
+// used returns the queries counted for registry in the window of period
+// containing now.
+func (c *queryCounter) used(registry, period string, now time.Time) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.load(); err != nil {
+		return 0, err
+	}
+	return c.Counts[registry][periodKey(period, now)], nil
+}
+
+// budgetTransport counts every RDAP query (bootstrap downloads excluded) in
+// the persistent counter and refuses, or with deferQueries waits out,
+// queries that would exceed a registry budget.
+type budgetTransport struct {
+	base         http.RoundTripper
+	budgets      []registryBudget
+	deferQueries bool
+}
+
+func (t *budgetTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if isBootstrapRequest(request) {
+		return t.base.RoundTrip(request)
+	}
+	if err := reserveQuery(request.Context(), registryForURL(request.URL), t.budgets, t.deferQueries); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(request)
+}
+
+// reserveQuery counts one query to registry against budgets. Over budget,
+// it fails, or with deferQueries waits for the next budget window.
+func reserveQuery(ctx context.Context, registry string, budgets []registryBudget, deferQueries bool) error {
+	for {
+		exhausted, resetsAt, err := registryQueryCounter.reserve(registry, budgets, time.Now())
+		if err != nil {
+			slog.Warn("query counter not updated", "error", err)
+		}
+		if exhausted == nil {
+			return nil
+		}
+		if !deferQueries {
+			return fmt.Errorf("query budget for %s exhausted (%d/%s, resets %s)",
+				registry, exhausted.Limit, exhausted.Period, resetsAt.Local().Format(time.RFC3339))
+		}
+		slog.Info("query budget exhausted, deferring", "registry", registry,
+			"budget", fmt.Sprintf("%d/%s", exhausted.Limit, exhausted.Period), "until", resetsAt)
+		timer := time.NewTimer(time.Until(resetsAt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// runBudget implements "budget": print the queries counted per registry in
+// the current minute, hour and day.
+func runBudget(args []string) int {
+	if len(args) > 0 {
+		fmt.Println("usage: go run . budget")
+		return 2
+	}
+	counter := registryQueryCounter
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+	if err := counter.load(); err != nil {
+		fmt.Printf("budget: %v\n", err)
+		return 1
+	}
+	now := time.Now()
+	registries := make([]string, 0, len(counter.Counts))
+	for registry := range counter.Counts {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	fmt.Printf("%-24s %8s %8s %8s\n", "registry", "minute", "hour", "day")
+	for _, registry := range registries {
+		windows := counter.Counts[registry]
+		fmt.Printf("%-24s %8d %8d %8d\n", registry, windows[periodKey("minute", now)],
+			windows[periodKey("hour", now)], windows[periodKey("day", now)])
+	}
+	return 0
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// zstdMagic starts every zstd frame.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// bundleCompression returns how a bundle written to name is compressed:
+// "gzip" for .tar.gz and .tgz files, "zstd" for .tar.zst and .tzst files and "" for
+// "-" and .tar files, which are plain tar. zstd bundles need the zstd
+// command.
+func bundleCompression(name string) (string, error) {
+	switch lower := strings.ToLower(name); {
+	case strings.HasSuffix(lower, ".tar.zst"), strings.HasSuffix(lower, ".tzst"):
+		// Checked before the bundle file is created.
+		if _, err := exec.LookPath("zstd"); err != nil {
+			return "", errZstdMissing
+		}
+		return "zstd", nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "gzip", nil
+	case lower == "-", strings.HasSuffix(lower, ".tar"):
+		return "", nil
+	default:
+		return "", fmt.Errorf("%s: want a .tar, .tar.gz, .tgz, .tar.zst or .tzst bundle, or - for stdout", name)
+	}
+}
+
+var errZstdMissing = errors.New("zstd bundles need the zstd command on PATH")
+
+// zstdPipe runs the zstd command, as zstd is not in the standard library.
+// Closing it ends its input, or drains its output, and waits for it.
+type zstdPipe struct {
+	process *exec.Cmd
+	stdin   io.WriteCloser // compressing
+	stdout  io.ReadCloser  // decompressing
+	stderr  bytes.Buffer
+}
+
+// startZstd starts zstd with args; it fails when the command is not on
+// PATH.
+func startZstd(args ...string) (*zstdPipe, error) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		return nil, errZstdMissing
+	}
+	pipe := &zstdPipe{process: exec.Command("zstd", args...)}
+	pipe.process.Stderr = &pipe.stderr
+	return pipe, nil
+}
+
+// zstdWriter returns a writer compressing into w.
+func zstdWriter(w io.Writer) (*zstdPipe, error) {
+	pipe, err := startZstd("-q", "-c")
+	if err != nil {
+		return nil, err
+	}
+	pipe.process.Stdout = w
+	if pipe.stdin, err = pipe.process.StdinPipe(); err != nil {
+		return nil, err
+	}
+	return pipe, pipe.process.Start()
+}
+
+// zstdReader returns a reader decompressing r.
+func zstdReader(r io.Reader) (*zstdPipe, error) {
+	pipe, err := startZstd("-q", "-d", "-c")
+	if err != nil {
+		return nil, err
+	}
+	pipe.process.Stdin = r
+	if pipe.stdout, err = pipe.process.StdoutPipe(); err != nil {
+		return nil, err
+	}
+	return pipe, pipe.process.Start()
+}
+
+func (p *zstdPipe) Write(data []byte) (int, error) { return p.stdin.Write(data) }
+
+func (p *zstdPipe) Read(data []byte) (int, error) { return p.stdout.Read(data) }
+
+func (p *zstdPipe) Close() error {
+	if p.stdin != nil {
+		p.stdin.Close()
+	} else {
+		// The tar reader stops at the end-of-archive marker, before any
+		// padding zstd still has to write.
+		io.Copy(io.Discard, p.stdout)
+	}
+	if err := p.process.Wait(); err != nil {
+		if message := strings.TrimSpace(p.stderr.String()); message != "" {
+			return fmt.Errorf("zstd: %s", message)
+		}
+		return fmt.Errorf("zstd: %v", err)
+	}
+	return nil
+}
+
+// exportResponseCache writes every cached answer to w as a tar archive of
+// the cache directory, compressed with compression ("gzip", "zstd" or ""),
+// and returns how many answers it wrote.
+func exportResponseCache(w io.Writer, compression string) (int, error) {
+	dir, err := responseCacheDir()
+	if err != nil {
+		return 0, err
+	}
+	var compressor io.WriteCloser
+	switch compression {
+	case "gzip":
+		compressor = gzip.NewWriter(w)
+	case "zstd":
+		if compressor, err = zstdWriter(w); err != nil {
+			return 0, err
+		}
+	}
+	if compressor != nil {
+		w = compressor
+	}
+	archive := tar.NewWriter(w)
+	exported := 0
+	err = filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			return err
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{Name: filepath.ToSlash(relative), Mode: 0o644, Size: int64(len(data)), ModTime: info.ModTime(), Typeflag: tar.TypeReg}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := archive.Write(data); err != nil {
+			return err
+		}
+		exported++
+		return nil
+	})
+	if err == nil {
+		err = archive.Close()
+	}
+	if compressor != nil {
+		if closeErr := compressor.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return exported, err
+}
+
+// importResponseCache adds the answers of a bundle read from r, gzipped,
+// zstd-compressed or plain, to the cache. An answer already cached is only replaced by a more
+// recently fetched one. It returns how many answers it imported and how
+// many it kept as they were.
+func importResponseCache(r io.Reader) (imported, kept int, err error) {
+	dir, err := responseCacheDir()
+	if err != nil {
+		return 0, 0, err
+	}
+	buffered := bufio.NewReader(r)
+	magic, _ := buffered.Peek(len(zstdMagic))
+	switch {
+	case bytes.Equal(magic, zstdMagic):
+		zstd, startErr := zstdReader(buffered)
+		if startErr != nil {
+			return 0, 0, startErr
+		}
+		defer func() {
+			if closeErr := zstd.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		r = zstd
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer gzipReader.Close()
+		r = gzipReader
+	default:
+		r = buffered
+	}
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return imported, kept, nil
+		}
+		if err != nil {
+			return imported, kept, err
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		// Entries are vantage/kind/value.json; anything else, or any path
+		// leaving the cache directory, is not a cache bundle.
+		name := path.Clean(header.Name)
+		if header.Typeflag != tar.TypeReg || strings.HasPrefix(name, "../") || path.IsAbs(name) ||
+			strings.Count(name, "/") != 2 || !strings.HasSuffix(name, ".json") {
+			return imported, kept, fmt.Errorf("%s: not a cached answer", header.Name)
+		}
+		data, err := io.ReadAll(archive)
+		if err != nil {
+			return imported, kept, err
+		}
+		var answer cachedResponse
+		if err := json.Unmarshal(data, &answer); err != nil {
+			return imported, kept, fmt.Errorf("%s: %v", header.Name, err)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if existing, err := loadCachedResponse(target); err == nil && !existing.FetchedAt.Before(answer.FetchedAt) {
+			kept++
+			continue
+		}
+		if err := writeCacheEntry(target, data); err != nil {
+			return imported, kept, err
+		}
+		imported++
+	}
+}
+
+// runCacheExport writes the cache bundle to name, or stdout for "-".
+func runCacheExport(name string) int {
+	compression, err := bundleCompression(name)
+	if err != nil {
+		fmt.Printf("cache export: %v\n", err)
+		return 2
+	}
+	var output io.WriteCloser = os.Stdout
+	if name != "-" {
+		if output, err = os.Create(name); err != nil {
+			fmt.Printf("cache export: %v\n", err)
+			return 1
+		}
+	}
+	exported, err := exportResponseCache(output, compression)
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Printf("cache export: %v\n", err)
+		return 1
+	}
+	// The bundle itself may be on stdout.
+	fmt.Fprintf(os.Stderr, "exported %d cached answers\n", exported)
+	return 0
+}
+
+// runCacheImport adds the answers of the bundle in name, or stdin for "-",
+// to the cache.
+func runCacheImport(name string) int {
+	var input io.ReadCloser = os.Stdin
+	if name != "-" {
+		var err error
+		if input, err = os.Open(name); err != nil {
+			fmt.Printf("cache import: %v\n", err)
+			return 1
+		}
+		defer input.Close()
+	}
+	imported, kept, err := importResponseCache(input)
+	if err != nil {
+		fmt.Printf("cache import: %v (%d answers imported before)\n", err, imported)
+		return 1
+	}
+	fmt.Printf("imported %d cached answers, kept %d already cached ones at least as recent\n", imported, kept)
+	return 0
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// volatileRDAPKeys are top-level response members that change for benign reasons
+// (terms-of-service wording, conformance tags) and are excluded when comparing
+// a response with its baseline.
+var volatileRDAPKeys = map[string]bool{
+	"notices":         true,
+	"rdapConformance": true,
+	"lang":            true,
+}
+
+// volatileEventActions are event actions whose date moves without the
+// object changing, e.g. on every database export.
+var volatileEventActions = map[string]bool{
+	"last update of RDAP database": true,
+}
+
+// canaryBaseline is the on-disk baseline file: normalized fields per ASN,
+// and per vantage point when probing through several ("AS15169 [eu]").
+type canaryBaseline struct {
+	Objects map[string]canarySnapshot `json:"objects"`
+}
+
+type canarySnapshot struct {
+	CapturedAt time.Time         `json:"captured_at"`
+	Fields     map[string]string `json:"fields"`
+}
+
+// fieldChange describes one differing field between a baseline and a fresh response.
+type fieldChange struct {
+	Path   string `json:"path"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// canaryMonitor holds the state shared by successive canary rounds.
+type canaryMonitor struct {
+	clients      []vantageClient
+	tagVantage   bool // include the vantage point in console output
+	asns         []int64
+	baseline     *canaryBaseline
+	baselinePath string
+	update       bool
+	status       *monitorStatus
+	resultsDir   string
+	webhook      *webhookNotifier
+}
+
+// canaryRun is the per-run result file written with --results-dir.
+type canaryRun struct {
+	StartedAt  time.Time            `json:"started_at"`
+	FinishedAt time.Time            `json:"finished_at"`
+	Targets    []canaryTargetResult `json:"targets"`
+}
+
+type canaryTargetResult struct {
+	Target   string        `json:"target"`
+	Vantage  string        `json:"vantage"`
+	Endpoint string        `json:"endpoint"`
+	Outcome  string        `json:"outcome"` // recorded, unchanged, changed or error
+	Error    string        `json:"error,omitempty"`
+	Changes  []fieldChange `json:"changes,omitempty"`
+}
+
+func runCanary(args []string) int {
+	flagSet := newFlagSet("canary")
+	var options clientOptions
+	options.registerFlags(flagSet)
+	var vantages vantageFlag
+	flagSet.Var(&vantages, "vantage", "also probe through a vantage point `name=socks5://host:port` (repeatable)")
+	interval := flagSet.Duration("interval", time.Hour, "time between query rounds")
+	schedule := flagSet.String("schedule", "", "cron expression for rounds (e.g. \"0 */6 * * *\"); overrides --interval")
+	baselinePath := flagSet.String("baseline", "baseline.json", "baseline file to diff against (created if missing)")
+	rounds := flagSet.Int("count", 0, "number of rounds to run (0 = run until interrupted)")
+	update := flagSet.Bool("update", false, "accept detected changes into the baseline")
+	resultsDir := flagSet.String("results-dir", "", "write one JSON result file per run into this directory")
+	statusListen := flagSet.String("status-listen", "", "serve an endpoint status page at /status on this address (e.g. :8080)")
+	statusWindow := flagSet.Duration("status-window", 24*time.Hour, "rolling window summarized by the status page")
+	var webhook webhookOptions
+	webhook.registerFlags(flagSet)
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . canary [flags] <ASN> [ASN...]")
+		flagSet.PrintDefaults()
+	}
+	targets, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	if err := options.validate(); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if len(targets) == 0 {
+		flagSet.Usage()
+		return 2
+	}
+	var cronSchedule *cronExpression
+	if *schedule != "" {
+		if cronSchedule, err = parseCronExpression(*schedule); err != nil {
+			fmt.Printf("canary: --schedule: %v\n", err)
+			return 2
+		}
+	}
+
+	notifier, err := webhook.notifier()
+	if err != nil {
+		fmt.Printf("canary: %v\n", err)
+		return 2
+	}
+
+	monitor := &canaryMonitor{
+		baselinePath: *baselinePath,
+		update:       *update,
+		status:       newMonitorStatus(*statusWindow),
+		resultsDir:   *resultsDir,
+		webhook:      notifier,
+	}
+	for _, target := range targets {
+		asn, err := parseASN(target)
+		if err != nil {
+			fmt.Printf("%s: invalid ASN: %v\n", target, err)
+			return 2
+		}
+		monitor.asns = append(monitor.asns, asn)
+	}
+	if monitor.baseline, err = loadCanaryBaseline(*baselinePath); err != nil {
+		fmt.Printf("canary: %v\n", err)
+		return 1
+	}
+	if *resultsDir != "" {
+		if err := os.MkdirAll(*resultsDir, 0o755); err != nil {
+			fmt.Printf("canary: %v\n", err)
+			return 1
+		}
+	}
+
+	if *statusListen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/status", monitor.status)
+		go func() {
+			if err := http.ListenAndServe(*statusListen, mux); err != nil {
+				fmt.Printf("canary: status page: %v\n", err)
+			}
+		}()
+	}
+
+	monitor.clients = newVantageClients(options, vantages, nil)
+	monitor.tagVantage = len(vantages) > 0
+	changesSeen := false
+	if cronSchedule == nil {
+		for round := 1; ; round++ {
+			changed, err := monitor.round()
+			if err != nil {
+				fmt.Printf("canary: %v\n", err)
+				return 1
+			}
+			changesSeen = changesSeen || changed
+			if *rounds > 0 && round >= *rounds {
+				break
+			}
+			time.Sleep(*interval)
+		}
+	} else {
+		changesSeen = monitor.runScheduled(cronSchedule, *rounds)
+	}
+
+	if changesSeen {
+		return 1
+	}
+	return 0
+}
+
+// runScheduled starts a round at every cron tick. A tick that fires while the
+// previous round is still running is skipped rather than stacked.
+func (m *canaryMonitor) runScheduled(schedule *cronExpression, rounds int) bool {
+	var running sync.Mutex
+	var waitGroup sync.WaitGroup
+	var changesSeen atomic.Bool
+	for started := 0; rounds == 0 || started < rounds; {
+		next := schedule.next(time.Now())
+		if next.IsZero() {
+			fmt.Println("canary: schedule never fires")
+			break
+		}
+		fmt.Printf("%s canary: next run at %s\n", time.Now().Format(time.RFC3339), next.Format(time.RFC3339))
+		time.Sleep(time.Until(next))
+		if !running.TryLock() {
+			fmt.Printf("%s canary: previous run still in progress, skipping\n", time.Now().Format(time.RFC3339))
+			continue
+		}
+		started++
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			defer running.Unlock()
+			changed, err := m.round()
+			if err != nil {
+				fmt.Printf("canary: %v\n", err)
+			}
+			if changed {
+				changesSeen.Store(true)
+			}
+		}()
+	}
+	waitGroup.Wait()
+	return changesSeen.Load()
+}
+
+// round queries every target once, diffs it against the baseline and reports
+// whether any target changed.
+func (m *canaryMonitor) round() (bool, error) {
+	run := canaryRun{StartedAt: time.Now().UTC()}
+	baselineDirty := false
+	changesSeen := false
+	for _, asn := range m.asns {
+		for _, vantage := range m.clients {
+			changed, recorded := m.check(asn, vantage, &run)
+			changesSeen = changesSeen || changed
+			baselineDirty = baselineDirty || recorded
+		}
+	}
+
+	if baselineDirty {
+		if err := saveCanaryBaseline(m.baselinePath, m.baseline); err != nil {
+			return changesSeen, err
+		}
+	}
+	run.FinishedAt = time.Now().UTC()
+	if m.resultsDir != "" {
+		if err := writeCanaryRun(m.resultsDir, run); err != nil {
+			return changesSeen, err
+		}
+	}
+	return changesSeen, nil
+}
+
+// check queries one target through one vantage point and diffs it against
+// the baseline. It reports whether the target changed and whether the
+// baseline was modified.
+func (m *canaryMonitor) check(asn int64, vantage vantageClient, run *canaryRun) (changed, recorded bool) {
+	target := "AS" + strconv.FormatInt(asn, 10)
+	// Vantage points may be answered by different servers, so each keeps
+	// its own baseline.
+	key := target
+	if m.tagVantage {
+		key += " [" + vantage.Vantage + "]"
+	}
+	label := key
+	result := canaryTargetResult{Target: target, Vantage: vantage.Vantage, Endpoint: "(bootstrap)"}
+	fetch, err := fetchAutnum(context.Background(), vantage.Client, asn)
+	if fetch != nil {
+		result.Endpoint = endpointOf(fetch.URL)
+	}
+	var fields map[string]string
+	if err == nil {
+		fields, err = normalizeRDAPResponse(fetch.RawBody)
+	}
+	if err != nil {
+		result.Outcome, result.Error = "error", err.Error()
+		run.Targets = append(run.Targets, result)
+		m.status.record(result.Endpoint, vantage.Vantage, target, false, err.Error())
+		fmt.Printf("%s %s: error: %v\n", time.Now().Format(time.RFC3339), label, err)
+		return false, false
+	}
+
+	previous, known := m.baseline.Objects[key]
+	if !known {
+		m.baseline.Objects[key] = canarySnapshot{CapturedAt: time.Now().UTC(), Fields: fields}
+		result.Outcome = "recorded"
+		run.Targets = append(run.Targets, result)
+		m.status.record(result.Endpoint, vantage.Vantage, target, true, "")
+		fmt.Printf("%s %s: baseline recorded (%d fields)\n", time.Now().Format(time.RFC3339), label, len(fields))
+		return false, true
+	}
+
+	changes := diffFields(previous.Fields, fields)
+	if len(changes) == 0 {
+		result.Outcome = "unchanged"
+		run.Targets = append(run.Targets, result)
+		m.status.record(result.Endpoint, vantage.Vantage, target, true, "")
+		fmt.Printf("%s %s: unchanged\n", time.Now().Format(time.RFC3339), label)
+		return false, false
+	}
+	result.Outcome, result.Changes = "changed", changes
+	run.Targets = append(run.Targets, result)
+	m.status.record(result.Endpoint, vantage.Vantage, target, true, fmt.Sprintf("%d field(s) changed", len(changes)))
+	fmt.Printf("%s %s: %d field(s) changed since %s\n", time.Now().Format(time.RFC3339), label, len(changes), previous.CapturedAt.Format(time.RFC3339))
+	for _, change := range changes {
+		fmt.Printf("  %s: %q -> %q\n", change.Path, change.Before, change.After)
+	}
+	event := changeEvent{Time: time.Now().UTC(), Source: "canary", Target: label, Class: "autnum", Server: result.Endpoint, Since: previous.CapturedAt, Changes: changes}
+	if err := m.webhook.notify(event); err != nil {
+		fmt.Printf("%s %s: webhook not delivered: %v\n", time.Now().Format(time.RFC3339), label, err)
+	}
+	if m.update {
+		m.baseline.Objects[key] = canarySnapshot{CapturedAt: time.Now().UTC(), Fields: fields}
+	}
+	return true, m.update
+}
+
+// writeCanaryRun stores a run as <dir>/run-<start time>.json.
+func writeCanaryRun(dir string, run canaryRun) error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	filename := "run-" + run.StartedAt.Format("20060102T150405Z") + ".json"
+	if err := os.WriteFile(filepath.Join(dir, filename), data, 0o644); err != nil {
+		return fmt.Errorf("writing run result: %w", err)
+	}
+	return nil
+}
+
+func loadCanaryBaseline(path string) (*canaryBaseline, error) {
+	baseline := &canaryBaseline{Objects: map[string]canarySnapshot{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return baseline, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading baseline: %w", err)
+	}
+	if err := json.Unmarshal(data, baseline); err != nil {
+		return nil, fmt.Errorf("parsing baseline %s: %w", path, err)
+	}
+	if baseline.Objects == nil {
+		baseline.Objects = map[string]canarySnapshot{}
+	}
+	return baseline, nil
+}
+
+func saveCanaryBaseline(path string, baseline *canaryBaseline) error {
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	temporaryPath := path + ".tmp"
+	if err := os.WriteFile(temporaryPath, data, 0o644); err != nil {
+		return fmt.Errorf("writing baseline: %w", err)
+	}
+	return os.Rename(temporaryPath, path)
+}
+
+// normalizeRDAPResponse flattens a raw RDAP JSON document into path -> value
+// pairs (e.g. "entities[ORG-1].handle"), dropping volatile top-level members
+// and events. Array elements are matched by their handle or eventAction, so
+// a server listing them in another order changes nothing.
+func normalizeRDAPResponse(rawBody []byte) (map[string]string, error) {
+	var document map[string]any
+	if err := json.Unmarshal(rawBody, &document); err != nil {
+		return nil, fmt.Errorf("decoding RDAP JSON: %w", err)
+	}
+	fields := map[string]string{}
+	for key, value := range document {
+		if volatileRDAPKeys[key] {
+			continue
+		}
+		flattenJSON(key, value, fields)
+	}
+	return fields, nil
+}
+
+func flattenJSON(path string, value any, fields map[string]string) {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, child := range typed {
+			flattenJSON(path+"."+key, child, fields)
+		}
+	case []any:
+		for _, element := range arrayElements(typed) {
+			flattenJSON(path+"["+element.index+"]", element.value, fields)
+		}
+	case nil:
+		fields[path] = "null"
+	case string:
+		fields[path] = typed
+	default:
+		encoded, _ := json.Marshal(typed)
+		fields[path] = string(encoded)
+	}
+}
+
+type arrayElement struct {
+	index string
+	value any
+}
+
+// arrayElements returns the elements of an array of objects, or of
+// strings (roles, status), in a stable order: objects by handle or
+// eventAction, indexed by it when it is unique, then by their JSON
+// encoding. Volatile events are dropped. Other arrays, like the positional
+// members of a vCard property, keep their order.
+func arrayElements(array []any) []arrayElement {
+	type keyed struct {
+		key, encoded string
+		value        any
+	}
+	elements := make([]keyed, 0, len(array))
+	objects, texts := 0, 0
+	for _, value := range array {
+		var key string
+		switch typed := value.(type) {
+		case map[string]any:
+			objects++
+			action, _ := typed["eventAction"].(string)
+			if volatileEventActions[action] {
+				continue
+			}
+			if key, _ = typed["handle"].(string); key == "" {
+				key = action
+			}
+		case string:
+			texts++
+			key = typed
+		}
+		encoded, _ := json.Marshal(value)
+		elements = append(elements, keyed{key: key, encoded: string(encoded), value: value})
+	}
+	if objects != len(array) && texts != len(array) {
+		indexed := make([]arrayElement, len(array))
+		for index, value := range array {
+			indexed[index] = arrayElement{index: strconv.Itoa(index), value: value}
+		}
+		return indexed
+	}
+	sort.SliceStable(elements, func(i, j int) bool {
+		if elements[i].key != elements[j].key {
+			return elements[i].key < elements[j].key
+		}
+		return elements[i].encoded < elements[j].encoded
+	})
+	uses := map[string]int{}
+	for _, element := range elements {
+		uses[element.key]++
+	}
+	indexed := make([]arrayElement, len(elements))
+	for index, element := range elements {
+		indexed[index] = arrayElement{index: strconv.Itoa(index), value: element.value}
+		if objects > 0 && element.key != "" && uses[element.key] == 1 {
+			indexed[index].index = element.key
+		}
+	}
+	return indexed
+}
+
+// diffFields compares two normalized documents and returns the changed,
+// added and removed fields sorted by path.
+func diffFields(before, after map[string]string) []fieldChange {
+	var changes []fieldChange
+	for path, oldValue := range before {
+		newValue, present := after[path]
+		if !present {
+			changes = append(changes, fieldChange{Path: path, Before: oldValue, After: "(removed)"})
+		} else if newValue != oldValue {
+			changes = append(changes, fieldChange{Path: path, Before: oldValue, After: newValue})
+		}
+	}
+	for path, newValue := range after {
+		if _, present := before[path]; !present {
+			changes = append(changes, fieldChange{Path: path, Before: "(absent)", After: newValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// capabilityProbe is one request used to discover whether a server implements
+// an object class or search endpoint.
+type capabilityProbe struct {
+	Kind string // "object" or "search"
+	Name string
+	Path string // relative to the base URL
+}
+
+// rdapExchange is a minimal record of one raw RDAP HTTP exchange.
+type rdapExchange struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	Redirects   *redirectChain
+}
+
+func runCapabilities(args []string) int {
+	flagSet := newFlagSet("capabilities")
+	var options clientOptions
+	options.registerFlags(flagSet)
+	asn := flagSet.String("asn", "1", "AS number used to probe autnum support")
+	ip := flagSet.String("ip", "192.0.2.1", "IP address used to probe ip support")
+	domain := flagSet.String("domain", "example.com", "domain used to probe domain support")
+	entity := flagSet.String("entity", "EXAMPLE-HANDLE", "entity handle used to probe entity support")
+	nameserver := flagSet.String("nameserver", "ns1.example.com", "nameserver used to probe nameserver support")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . capabilities [flags] <base-url>")
+		flagSet.PrintDefaults()
+	}
+	positional, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	if err := options.validate(); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if len(positional) != 1 {
+		flagSet.Usage()
+		return 2
+	}
+	baseURL := strings.TrimSuffix(positional[0], "/") + "/"
+	httpClient := newRDAPClient(options).HTTP
+
+	fmt.Printf("RDAP server: %s\n", baseURL)
+	help, err := rdapGet(context.Background(), httpClient, baseURL+"help")
+	switch {
+	case err != nil:
+		fmt.Printf("\n/help: error: %v\n", err)
+	case help.StatusCode != http.StatusOK:
+		fmt.Printf("\n/help: HTTP %d (%s)\n", help.StatusCode, help.ContentType)
+	default:
+		printHelpDocument(help.Body)
+	}
+
+	probes := []capabilityProbe{
+		{"object", "autnum", "autnum/" + strings.TrimPrefix(strings.ToUpper(*asn), "AS")},
+		{"object", "ip", "ip/" + *ip},
+		{"object", "domain", "domain/" + *domain},
+		{"object", "entity", "entity/" + *entity},
+		{"object", "nameserver", "nameserver/" + *nameserver},
+		{"search", "domains?name=", "domains?name=" + *domain},
+		{"search", "domains?nsLdhName=", "domains?nsLdhName=" + *nameserver},
+		{"search", "nameservers?name=", "nameservers?name=" + *nameserver},
+		{"search", "entities?fn=", "entities?fn=Example*"},
+		{"search", "entities?handle=", "entities?handle=" + *entity},
+	}
+	fmt.Println("\nEndpoint probes:")
+	for _, probe := range probes {
+		exchange, err := rdapGet(context.Background(), httpClient, baseURL+probe.Path)
+		verdict := ""
+		if err != nil {
+			verdict = "error: " + err.Error()
+		} else {
+			verdict = classifyProbe(exchange)
+		}
+		fmt.Printf("  %-6s %-20s %s\n", probe.Kind, probe.Name, verdict)
+	}
+	return 0
+}
+
+// rdapGet issues a GET with RDAP content negotiation and reads the whole body.
+// If the request fails after following redirects, the partial exchange still
+// carries the redirect chain.
+func rdapGet(ctx context.Context, httpClient *http.Client, rdapURL string) (*rdapExchange, error) {
+	ctx, chain := withRedirectChain(ctx)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, rdapURL, nil)
+	if err != nil {
+		return &rdapExchange{Redirects: chain}, err
+	}
+	request.Header.Set("Accept", "application/rdap+json, application/json")
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return &rdapExchange{Redirects: chain}, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return &rdapExchange{Redirects: chain}, err
+	}
+	return &rdapExchange{StatusCode: response.StatusCode, ContentType: response.Header.Get("Content-Type"), Body: body, Redirects: chain}, nil
+}
+
+// isRDAPJSON reports whether the exchange carries a JSON document, which is
+// what distinguishes an RDAP answer from a generic web server error page.
+func (e *rdapExchange) isRDAPJSON() bool {
+	mediaType, _, _ := mime.ParseMediaType(e.ContentType)
+	if mediaType != "application/rdap+json" && mediaType != "application/json" {
+		return false
+	}
+	return json.Valid(e.Body)
+}
+
+// classifyProbe turns a probe response into a human verdict. A well-formed
+// RDAP 404 still shows the path is routed; an HTML 404 or 501 means it is not.
+func classifyProbe(exchange *rdapExchange) string {
+	switch {
+	case exchange.StatusCode >= 200 && exchange.StatusCode <= 299:
+		return fmt.Sprintf("supported (HTTP %d)", exchange.StatusCode)
+	case exchange.StatusCode == http.StatusNotImplemented:
+		return "not implemented (HTTP 501)"
+	case exchange.isRDAPJSON() && exchange.StatusCode == http.StatusNotFound:
+		return "supported (probe object not found)"
+	case exchange.isRDAPJSON() && (exchange.StatusCode == http.StatusBadRequest || exchange.StatusCode == http.StatusUnprocessableEntity):
+		return fmt.Sprintf("supported (probe rejected, HTTP %d)", exchange.StatusCode)
+	case exchange.isRDAPJSON() && exchange.StatusCode == http.StatusForbidden:
+		return "restricted (HTTP 403)"
+	case exchange.StatusCode == http.StatusNotFound:
+		return "unsupported (non-RDAP 404)"
+	default:
+		return fmt.Sprintf("unknown (HTTP %d, %s)", exchange.StatusCode, exchange.ContentType)
+	}
+}
+
+func printHelpDocument(body []byte) {
+	var help struct {
+		Conformance []string `json:"rdapConformance"`
+		Notices     []struct {
+			Title       string   `json:"title"`
+			Description []string `json:"description"`
+		} `json:"notices"`
+	}
+	if err := json.Unmarshal(body, &help); err != nil {
+		fmt.Printf("\n/help: invalid JSON: %v\n", err)
+		return
+	}
+	fmt.Println("\nDeclared extensions (rdapConformance):")
+	if len(help.Conformance) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, extension := range help.Conformance {
+		fmt.Printf("  %s\n", extension)
+	}
+	fmt.Println("\nNotices:")
+	if len(help.Notices) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, notice := range help.Notices {
+		fmt.Printf("  %s\n", notice.Title)
+		for _, line := range notice.Description {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+}
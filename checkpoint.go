@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hookster007/rdap-test/pkg/sink"
+)
+
+// checkpoint records, one per line, the targets of a batch whose results
+// have been written (-checkpoint), so that -resume can continue a crashed
+// or interrupted run without querying them again.
+type checkpoint struct {
+	mutex sync.Mutex
+	file  *os.File
+	// sinks are flushed before a target is recorded, so the checkpoint
+	// never lists a target whose result a crash could still lose.
+	sinks []sink.Sink
+	// held are done targets whose results a batchedSink has yet to
+	// commit.
+	held []string
+}
+
+// bufferedSink is implemented by sinks holding written results in memory
+// until their final Flush.
+type bufferedSink interface {
+	FlushBuffer() error
+}
+
+// batchedSink is implemented by sinks committing written results in
+// batches, such as the sqlite sink. Rather than committing each result,
+// the checkpoint holds targets back until their batch is committed.
+type batchedSink interface {
+	uncommitted() int // results written since the last commit
+}
+
+// loadCheckpoint returns the targets a checkpoint file records as done; a
+// missing file records none.
+func loadCheckpoint(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	done := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// A crash can leave the last line unterminated; the scanner still
+		// returns it, and a truncated target merely is not skipped.
+		if target := strings.TrimSpace(scanner.Text()); target != "" {
+			done[target] = true
+		}
+	}
+	return done, scanner.Err()
+}
+
+// openCheckpoint opens the checkpoint file at path, adding to it when
+// resuming and starting it afresh otherwise.
+func openCheckpoint(path string, resume bool, sinks []sink.Sink) (*checkpoint, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if !resume {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &checkpoint{file: file, sinks: sinks}, nil
+}
+
+// record marks target as done once the results written so far are out of
+// the sinks' buffers and committed by batched sinks.
+func (c *checkpoint) record(target string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.held = append(c.held, target)
+	for _, opened := range c.sinks {
+		if buffered, ok := opened.(bufferedSink); ok {
+			if err := buffered.FlushBuffer(); err != nil {
+				return err
+			}
+		}
+		if batched, ok := opened.(batchedSink); ok && batched.uncommitted() > 0 {
+			return nil
+		}
+	}
+	return c.writeHeld()
+}
+
+// recordHeld marks the targets held back as done; call it once the sinks
+// were flushed, which commits their last batch.
+func (c *checkpoint) recordHeld() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.writeHeld()
+}
+
+func (c *checkpoint) writeHeld() error {
+	if len(c.held) == 0 {
+		return nil
+	}
+	_, err := c.file.WriteString(strings.Join(c.held, "\n") + "\n")
+	c.held = c.held[:0]
+	return err
+}
+
+func (c *checkpoint) Close() error {
+	return c.file.Close()
+}
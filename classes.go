@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	rdap "github.com/openrdap/rdap"
+)
+
+// objectClasses are the RDAP object classes -classes can try.
+var objectClasses = []string{"autnum", "ip", "domain", "nameserver", "entity"}
+
+// classList implements -classes autnum,entity,ip: the object classes an
+// ambiguous target is tried against, in priority order.
+type classList []string
+
+func (c *classList) String() string {
+	if c == nil {
+		return ""
+	}
+	return strings.Join(*c, ",")
+}
+
+func (c *classList) Set(value string) error {
+	*c = nil
+	for _, class := range strings.Split(value, ",") {
+		class = strings.ToLower(strings.TrimSpace(class))
+		if !slices.Contains(objectClasses, class) {
+			return fmt.Errorf("unknown object class %q (want %s)", class, strings.Join(objectClasses, ", "))
+		}
+		if !slices.Contains(*c, class) {
+			*c = append(*c, class)
+		}
+	}
+	return nil
+}
+
+// classQuery returns the query for target as an object of class, or ok
+// false when target cannot name such an object (e.g. "ORG-1" as an ip).
+func classQuery(class, target string) (query string, ok bool) {
+	switch class {
+	case "autnum":
+		asn, err := parseASN(target)
+		return strconv.FormatInt(asn, 10), err == nil && asn > 0 && asn <= 4294967295
+	case "ip":
+		return parseNetworkTarget(target)
+	case "domain", "nameserver":
+		name, err := parseDomainName(target)
+		return name, err == nil
+	case "entity":
+		return target, target != "" && !strings.ContainsAny(target, "/ ")
+	}
+	return "", false
+}
+
+// lookupClass looks query up as an object of class.
+func lookupClass(ctx context.Context, client *rdap.Client, class, query string, verbosity, followUps int) lookupResult {
+	switch class {
+	case "autnum":
+		asn, _ := parseASN(query)
+		name, fetch, err := rdapASNLookup(ctx, client, asn, verbosity, followUps)
+		return lookupResult{Name: name, Fetch: fetch, Err: err}
+	case "ip":
+		return rdapNetworkLookup(ctx, client, query)
+	case "domain":
+		return rdapDomainLookup(ctx, client, query)
+	case "nameserver":
+		return rdapNameserverLookup(ctx, client, query)
+	default:
+		return rdapEntityLookup(ctx, client, query, nil)
+	}
+}
+
+// lookupClasses tries target against classes in order and returns the
+// first answer, with Class set to the class that matched. Classes target
+// cannot name are skipped. When none matches, the result is not found if
+// every registry said so, and otherwise the first other failure; its error
+// lists what each class answered.
+func lookupClasses(target string, classes []string, try func(class, query string) lookupResult) lookupResult {
+	var tried []string
+	var failure *lookupResult
+	var last lookupResult
+	for _, class := range classes {
+		query, ok := classQuery(class, target)
+		if !ok {
+			continue
+		}
+		result := try(class, query)
+		result.Class = class
+		if result.Err == nil {
+			return result
+		}
+		tried = append(tried, class+": "+result.Err.Error())
+		if failure == nil && resultClass(result.Err) != classNotFound {
+			failure = &result
+		}
+		last = result
+	}
+	if len(tried) == 0 {
+		return lookupResult{Err: fmt.Errorf("%q cannot name an object of class %s", target, strings.Join(classes, ", "))}
+	}
+	if failure != nil {
+		last = *failure
+	}
+	last.Class = ""
+	last.Err = &classesError{tried: tried, err: last.Err}
+	return last
+}
+
+// classesError is the error of a target no object class matched. It
+// unwraps to the error deciding the result class.
+type classesError struct {
+	tried []string
+	err   error
+}
+
+func (e *classesError) Error() string {
+	return "no object class matched (" + strings.Join(e.tried, "; ") + ")"
+}
+
+func (e *classesError) Unwrap() error { return e.err }
+
+// classNote renders the object class that matched under -classes.
+func (r lookupResult) classNote() string {
+	if r.Class == "" {
+		return ""
+	}
+	return fmt.Sprintf(localize(" [class %s]"), r.Class)
+}
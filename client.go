@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+	rdap "github.com/openrdap/rdap"
+	"github.com/openrdap/rdap/bootstrap"
+)
+
+// clientOptions collects the knobs that shape the HTTP transport shared by the
+// bootstrap and RDAP clients.
+type clientOptions struct {
+	// Trace, when set, receives phase timings for every HTTP request made,
+	// including bootstrap registry downloads.
+	Trace func(request *http.Request, timings requestTimings)
+
+	IPv4Only bool // dial RDAP and bootstrap servers over IPv4 only (-4)
+	IPv6Only bool // dial RDAP and bootstrap servers over IPv6 only (-6)
+
+	// Proxy relays all traffic through a SOCKS5 or HTTP proxy (a vantage
+	// point, or -proxy). Nil uses the environment's proxy settings.
+	Proxy *url.URL
+
+	// Timeout bounds each HTTP request, including reading the body
+	// (-timeout); 0 waits as long as the server takes.
+	Timeout time.Duration
+
+	// BootstrapURL replaces the IANA bootstrap base URL (-bootstrap-url,
+	// defaulting to RDAP_BOOTSTRAP_URL), e.g. to point at a mock-server.
+	BootstrapURL string
+
+	// BootstrapTTL is how long a bootstrap registry file kept on disk is
+	// used before it is downloaded again (-bootstrap-ttl);
+	// OfflineBootstrap uses the kept copy, however old, when the download
+	// fails (-offline-bootstrap).
+	BootstrapTTL     time.Duration
+	OfflineBootstrap bool
+
+	// CacheDir replaces $XDG_CACHE_HOME/rdap-tester as the directory of
+	// cached answers and snapshots (-cache-dir); validate installs it.
+	CacheDir string
+
+	// Verbosity is 0 by default, or 1-3 for -v, -vv and -vvv.
+	Verbosity int
+
+	// LogFormat and LogLevel configure the slog handler on stderr
+	// (--log-format, --log-level).
+	LogFormat string
+	LogLevel  string
+
+	// NoColor and ASCIITables degrade console output (--no-color,
+	// --ascii-tables); see configureConsole.
+	NoColor     bool
+	ASCIITables bool
+	// MaxNameLength truncates names in console output (-max-name-len); 0
+	// shows them in full.
+	MaxNameLength int
+
+	// Budgets cap the queries sent to each registry (-budget); DeferOverBudget
+	// waits for the next window instead of failing the query.
+	Budgets         budgetFlag
+	DeferOverBudget bool
+
+	// RateLimit caps the queries per second sent to each RDAP server (0 is
+	// unlimited); BackoffRetries is how often a query answered 429 or 503
+	// is retried after backing off (-rate-limit, -backoff-retries).
+	RateLimit      float64
+	BackoffRetries int
+
+	// Retries is how often a query failing transiently (network error,
+	// timeout, 500, 502 or 504) is retried, after RetryDelay and then twice
+	// as long each time, jittered (-retries, -retry-delay).
+	Retries    int
+	RetryDelay time.Duration
+
+	// Stats, when set, counts every request and the bytes received (-stats).
+	Stats *runStats
+
+	// DumpHTTP, when set, receives every request and response verbatim
+	// (-dump-http).
+	DumpHTTP io.Writer
+
+	// AuditLog, when set, records every request sent in a hash-chained,
+	// append-only log (-audit-log).
+	AuditLog *auditLog
+
+	// EndpointsPath is the file of custom registry endpoints (-endpoints);
+	// empty uses ~/.config/rdap-tester/endpoints if present.
+	EndpointsPath string
+
+	// Server, when set, receives every query instead of the bootstrapped or
+	// -endpoints registry (-server).
+	Server string
+
+	// AuthPath is the file of per-base-URL upstream credentials (-auth);
+	// empty uses ~/.config/rdap-tester/auth if present. validate loads it
+	// into Credentials.
+	AuthPath    string
+	Credentials upstreamCredentials
+
+	// SessionsPath is the file of per-base-URL pre-flight requests
+	// (-sessions); empty uses ~/.config/rdap-tester/sessions if present.
+	// validate loads it into Sessions.
+	SessionsPath string
+	Sessions     upstreamSessions
+
+	// Offline refuses every HTTP request, so lookups are answered from the
+	// response cache only (-offline).
+	Offline bool
+
+	// Limits reject pathological responses (-max-response-size,
+	// -max-json-depth, -max-entities).
+	Limits responseLimits
+}
+
+// registerFlags adds the transport flags shared by every mode to flagSet.
+func (o *clientOptions) registerFlags(flagSet *flag.FlagSet) {
+	flagSet.BoolVar(&o.IPv4Only, "4", false, "connect over IPv4 only")
+	flagSet.BoolVar(&o.IPv6Only, "6", false, "connect over IPv6 only")
+	flagSet.Func("proxy", "relay all traffic through this SOCKS5 or HTTP proxy `URL`, e.g. socks5://127.0.0.1:1080 (-vantage points use their own)", func(value string) error {
+		proxyURL, err := parseProxyURL(value)
+		o.Proxy = proxyURL
+		return err
+	})
+	flagSet.DurationVar(&o.Timeout, "timeout", 0, "give up on each HTTP request, body included, after this `duration` (0 waits as long as the server takes)")
+	flagSet.StringVar(&o.BootstrapURL, "bootstrap-url", "", "fetch the IANA bootstrap registries from this base `URL` instead (default $RDAP_BOOTSTRAP_URL, else IANA)")
+	flagSet.DurationVar(&o.BootstrapTTL, "bootstrap-ttl", bootstrap.DefaultCacheTimeout, "download the bootstrap registries kept in the cache directory again once they are older than this `age`")
+	flagSet.BoolVar(&o.OfflineBootstrap, "offline-bootstrap", false, "when a bootstrap registry cannot be downloaded, use the copy kept in the cache directory however old it is")
+	flagSet.StringVar(&o.CacheDir, "cache-dir", "", "keep cached answers and snapshots in this `directory` (default $XDG_CACHE_HOME/rdap-tester)")
+	flagSet.Var(verbosityFlag{&o.Verbosity, verboseExtraction}, "v", "verbose: explain name extraction and print redirect chains (stderr)")
+	flagSet.Var(verbosityFlag{&o.Verbosity, verboseHTTP}, "vv", "more verbose: also print HTTP request/response summaries and record JSON (stderr)")
+	flagSet.Var(verbosityFlag{&o.Verbosity, verboseTrace}, "vvv", "most verbose: also print httptrace DNS, connect, TLS and TTFB events (stderr)")
+	flagSet.StringVar(&o.LogFormat, "log-format", "text", "diagnostic log format on stderr: text or json")
+	flagSet.StringVar(&o.LogLevel, "log-level", "", "minimum diagnostic log level: debug, info, warn or error (default info, or debug with -v)")
+	flagSet.BoolVar(&o.NoColor, "no-color", false, "disable ANSI colors and cursor control (also NO_COLOR, non-terminals and legacy Windows consoles)")
+	flagSet.BoolVar(&o.ASCIITables, "ascii-tables", false, "draw rules and ellipses with ASCII characters only")
+	flagSet.IntVar(&o.MaxNameLength, "max-name-len", defaultDisplayNameLimit, "truncate names in console output to `n` characters, ending with an ellipsis (0 shows names in full; -json and -csv always carry the full name)")
+	flagSet.Var(&o.Budgets, "budget", "cap queries per registry, e.g. `arin=1000/day` (also /hour, /minute; comma-separated, repeatable)")
+	flagSet.BoolVar(&o.DeferOverBudget, "budget-defer", false, "wait for the next budget window instead of refusing over-budget queries")
+	flagSet.Float64Var(&o.RateLimit, "rate-limit", 0, "send at most `n` queries per second to each RDAP server (0 for no limit)")
+	flagSet.IntVar(&o.Retries, "retries", 2, "retry queries failing transiently (network errors, timeouts, 500, 502, 504) up to `n` times; 404 and other answers are final (0 disables)")
+	flagSet.DurationVar(&o.RetryDelay, "retry-delay", 500*time.Millisecond, "wait about this `delay` before the first retry, doubling for each further one (jittered)")
+	flagSet.IntVar(&o.BackoffRetries, "backoff-retries", 4, "retry queries answered 429 or 503 up to `n` times, waiting as Retry-After asks or with exponential backoff (0 disables)")
+	flagSet.StringVar(&o.EndpointsPath, "endpoints", "", "`file` of \"selector base-URL\" lines routing ASN ranges, prefixes or TLDs to custom RDAP servers (default ~/.config/rdap-tester/endpoints if present)")
+	flagSet.StringVar(&o.Server, "server", "", "send every query to this RDAP base `URL`, e.g. a registry's staging server, bypassing the bootstrap registries and -endpoints")
+	flagSet.StringVar(&o.AuthPath, "auth", "", "`file` of \"base-URL bearer|basic|oauth2 ...\" lines authenticating requests to upstream RDAP servers (default ~/.config/rdap-tester/auth if present)")
+	flagSet.StringVar(&o.SessionsPath, "sessions", "", "`file` of \"base-URL pre-flight-URL\" lines: fetch the pre-flight URL for session cookies before querying the base URL (default ~/.config/rdap-tester/sessions if present)")
+	o.Limits = defaultResponseLimits
+	flagSet.Var(&o.Limits.MaxBodySize, "max-response-size", "reject response bodies larger than `size`, e.g. 512KiB (0 disables)")
+	flagSet.IntVar(&o.Limits.MaxDepth, "max-json-depth", o.Limits.MaxDepth, "reject responses with JSON nested deeper than `n` levels (0 disables)")
+	flagSet.IntVar(&o.Limits.MaxEntities, "max-entities", o.Limits.MaxEntities, "reject responses listing more than `n` entities in total (0 disables)")
+	flagSet.Var(&auditLogFlag{target: &o.AuditLog}, "audit-log", "append every request sent (time, method, URL, status) to `file` as a hash-chained log; verify it with \"audit verify\"")
+	flagSet.Var(&dumpHTTPFlag{target: &o.DumpHTTP}, "dump-http", "print every request and response with headers and body to stderr, or append them to `file` with -dump-http=file")
+}
+
+// validate rejects contradictory option combinations, installs the
+// diagnostic logger and console settings they describe and loads the custom
+// endpoints, upstream credentials and sessions.
+func (o *clientOptions) validate() error {
+	if o.IPv4Only && o.IPv6Only {
+		return fmt.Errorf("-4 and -6 are mutually exclusive")
+	}
+	if o.MaxNameLength < 0 {
+		return fmt.Errorf("-max-name-len must not be negative; use 0 to show names in full")
+	}
+	if o.Limits.MaxDepth < 0 || o.Limits.MaxEntities < 0 {
+		return fmt.Errorf("-max-json-depth and -max-entities must not be negative")
+	}
+	if o.RateLimit < 0 || o.BackoffRetries < 0 || o.Retries < 0 || o.RetryDelay < 0 {
+		return fmt.Errorf("-rate-limit, -backoff-retries, -retries and -retry-delay must not be negative")
+	}
+	if o.Timeout < 0 {
+		return fmt.Errorf("-timeout must not be negative")
+	}
+	if o.BootstrapTTL < 0 {
+		return fmt.Errorf("-bootstrap-ttl must not be negative")
+	}
+	if o.BootstrapURL == "" {
+		o.BootstrapURL = os.Getenv("RDAP_BOOTSTRAP_URL")
+	}
+	if o.BootstrapURL != "" {
+		if parsed, err := url.Parse(o.BootstrapURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("-bootstrap-url: invalid URL %q", o.BootstrapURL)
+		}
+	}
+	if o.CacheDir != "" {
+		cacheDir = o.CacheDir
+	}
+	configureConsole(o.NoColor, o.ASCIITables, o.MaxNameLength)
+	if err := installLogger(o.LogFormat, o.LogLevel, o.Verbosity); err != nil {
+		return err
+	}
+	endpointsPath, optional := o.EndpointsPath, o.EndpointsPath == ""
+	if optional {
+		endpointsPath, _ = defaultEndpointsPath()
+	}
+	endpoints, err := loadEndpoints(endpointsPath, optional)
+	if err != nil {
+		return fmt.Errorf("-endpoints: %v", err)
+	}
+	customEndpoints = endpoints
+	if o.Server != "" {
+		server, err := parseEndpointOverride("*", o.Server)
+		if err != nil {
+			return fmt.Errorf("-server: %v", err)
+		}
+		server.Selector = "-server"
+		customEndpoints = endpointOverrides{server}
+	}
+	authPath, optional := o.AuthPath, o.AuthPath == ""
+	if optional {
+		authPath, _ = defaultAuthPath()
+	}
+	if o.Credentials, err = loadAuth(authPath, optional); err != nil {
+		return fmt.Errorf("-auth: %v", err)
+	}
+	sessionsPath, optional := o.SessionsPath, o.SessionsPath == ""
+	if optional {
+		sessionsPath, _ = defaultSessionsPath()
+	}
+	if o.Sessions, err = loadSessions(sessionsPath, optional); err != nil {
+		return fmt.Errorf("-sessions: %v", err)
+	}
+	return nil
+}
+
+// network returns the dial network implied by the address family flags.
+func (o *clientOptions) network() string {
+	switch {
+	case o.IPv4Only:
+		return "tcp4"
+	case o.IPv6Only:
+		return "tcp6"
+	default:
+		return "tcp"
+	}
+}
+
+// requestTimings are the httptrace phase durations of one HTTP request.
+// Phases that did not happen (e.g. DNS on a reused connection) are zero.
+type requestTimings struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration // from request start to first response byte
+	Total   time.Duration // from request start to response headers
+}
+
+// newRDAPClient builds the RDAP client used by every mode. BootstrapURL,
+// or else RDAP_BOOTSTRAP_URL, replaces the IANA bootstrap base URL, e.g. to
+// point at a mock-server.
+func newRDAPClient(options clientOptions) *rdap.Client {
+	baseTransport := http.DefaultTransport.(*http.Transport).Clone()
+	baseTransport.DialContext = dialerForNetwork(options.network())
+	if options.Proxy != nil {
+		baseTransport.Proxy = http.ProxyURL(options.Proxy)
+	}
+	var transport http.RoundTripper = baseTransport
+	if options.Offline {
+		transport = offlineTransport{}
+	}
+	if options.AuditLog != nil {
+		// Innermost, so each request that leaves the process is recorded.
+		transport = &auditTransport{base: transport, log: options.AuditLog}
+	}
+	transport = &hopTransport{base: transport}
+	if options.DumpHTTP != nil {
+		// Inside authTransport and sessionTransport, so the credentials and
+		// cookies sent are dumped, redacted.
+		transport = &dumpTransport{base: transport, output: options.DumpHTTP, maxBody: options.Limits.MaxBodySize}
+	}
+	if len(options.Credentials) > 0 {
+		transport = &authTransport{base: transport, credentials: options.Credentials}
+	}
+	// Each client keeps its own cookies, so vantage points never share a
+	// session.
+	jar, _ := cookiejar.New(nil)
+	transport = &sessionTransport{base: transport, jar: jar, sessions: options.Sessions}
+	transport = &budgetTransport{base: transport, budgets: options.Budgets, deferQueries: options.DeferOverBudget}
+	transport = &limitTransport{base: transport, limits: options.Limits}
+	if options.Stats != nil {
+		transport = &statsTransport{base: transport, stats: options.Stats}
+	}
+	if options.Verbosity >= verboseHTTP {
+		transport = &loggingTransport{base: transport, verbosity: options.Verbosity}
+	}
+	if options.Trace != nil {
+		transport = &tracingTransport{base: transport, observe: options.Trace}
+	}
+	// Outermost, so each retried attempt is throttled, traced, logged and
+	// counted.
+	transport = &throttleTransport{base: transport, rate: options.RateLimit, retries: options.BackoffRetries, timeout: options.Timeout}
+	if options.Retries > 0 {
+		transport = &retryTransport{base: transport, retries: options.Retries, delay: options.RetryDelay}
+	}
+	// throttleTransport applies -timeout per attempt, so a client-wide
+	// timeout would cut short retries and backoff waits.
+	httpClient := &http.Client{Transport: transport}
+	// Without a cache directory the registries are only kept for the run.
+	bootstrapDir, _ := bootstrapCacheDir()
+	files := newBootstrapFiles(bootstrapDir, options.BootstrapTTL)
+	bootstrapTransport := &bootstrapFallbackTransport{base: &objectTagsTransport{base: transport}, files: files, allowed: options.OfflineBootstrap}
+	bootstrapClient := &bootstrap.Client{HTTP: &http.Client{Transport: bootstrapTransport, Timeout: options.Timeout}, Cache: files.view()}
+	baseURL := options.BootstrapURL
+	if baseURL == "" {
+		baseURL = os.Getenv("RDAP_BOOTSTRAP_URL")
+	}
+	if baseURL != "" {
+		if parsed, err := url.Parse(baseURL); err == nil {
+			bootstrapClient.BaseURL = parsed
+			bootstrapTransport.baseURL = parsed
+		}
+	}
+	// Entity handles are routed by their object tag (RFC 8521), which
+	// openrdap still treats as an experiment.
+	return &rdap.Client{HTTP: httpClient, Bootstrap: bootstrapClient, ServiceProviderExperiment: true}
+}
+
+// autnumFetch is the outcome of one autnum HTTP exchange.
+type autnumFetch struct {
+	Record    *rdap.Autnum
+	RawBody   []byte
+	URL       string // final RDAP URL queried; empty if bootstrap failed
+	Redirects *redirectChain
+	// Attempts lists every HTTP request made for the lookup, across query
+	// formats and bootstrap endpoints, in order.
+	Attempts []rdaplookup.Attempt
+	// ValidUntil is when the answer should be refreshed, from the
+	// response's caching headers; zero when they gave no hint.
+	ValidUntil time.Time
+}
+
+// attempts returns the attempts of fetch, which may be nil.
+func (f *autnumFetch) attempts() []rdaplookup.Attempt {
+	if f == nil {
+		return nil
+	}
+	return f.Attempts
+}
+
+// fetchAutnum performs a single bootstrapped autnum query and returns both the
+// decoded record and the raw JSON body the server sent. The returned fetch is
+// non-nil even on error whenever a request reached an RDAP server.
+func fetchAutnum(ctx context.Context, client *rdap.Client, asn int64) (*autnumFetch, error) {
+	if asn <= 0 || asn > 4294967295 {
+		return nil, fmt.Errorf("invalid ASN: %d", asn)
+	}
+	return queryAutnum(ctx, client, strconv.FormatInt(asn, 10))
+}
+
+// queryAutnum runs an autnum query for the given query text ("15169" or
+// "AS15169"), recording the redirect chain taken to the answer.
+func queryAutnum(ctx context.Context, client *rdap.Client, query string) (*autnumFetch, error) {
+	ctx, chain := withRedirectChain(ctx)
+	ctx, retried := withAttemptLog(ctx)
+	response, err := doRDAP(client, rdap.NewRequest(rdap.AutnumRequest, query).WithContext(ctx))
+	var fetch *autnumFetch
+	if lastExchange := lastHTTPExchange(response); lastExchange != nil {
+		fetch = &autnumFetch{URL: lastExchange.URL, RawBody: lastExchange.Body, Redirects: chain, Attempts: retried.merge(rdaplookup.AttemptsFrom(query, response)),
+			ValidUntil: validUntil(lastExchange, time.Now())}
+	}
+	if err != nil {
+		return fetch, err
+	}
+	switch object := response.Object.(type) {
+	case *rdap.Autnum:
+		if fetch != nil {
+			fetch.Record = object
+			if asn, err := parseASN(query); err == nil {
+				saveSnapshot("AS"+strconv.FormatInt(asn, 10), fetch.RawBody)
+			}
+			return fetch, nil
+		}
+	case *rdap.Error:
+		return fetch, fmt.Errorf("server returned error code %d, title=%q, description=%q",
+			object.ErrorCode, object.Title, strings.Join(object.Description, " "))
+	}
+	return fetch, fmt.Errorf("unexpected RDAP response type %T for %s", response.Object, query)
+}
+
+// doRDAP sends request to its custom endpoint or, failing that, through the
+// bootstrap registry.
+func doRDAP(client *rdap.Client, request *rdap.Request) (*rdap.Response, error) {
+	return (&rdaplookup.Client{RDAP: client, Route: routeRequest}).Do(request)
+}
+
+func lastHTTPExchange(response *rdap.Response) *rdap.HTTPResponse {
+	if response == nil || len(response.HTTP) == 0 {
+		return nil
+	}
+	return response.HTTP[len(response.HTTP)-1]
+}
+
+// tracingTransport attaches an httptrace.ClientTrace to each request and
+// reports the collected phase timings once response headers arrive.
+type tracingTransport struct {
+	base    http.RoundTripper
+	observe func(request *http.Request, timings requestTimings)
+}
+
+func (t *tracingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	var timings requestTimings
+	var dnsStart, connectStart, tlsStart time.Time
+	start := time.Now()
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:           func(httptrace.DNSDoneInfo) { timings.DNS = time.Since(dnsStart) },
+		ConnectStart:      func(string, string) { connectStart = time.Now() },
+		ConnectDone:       func(string, string, error) { timings.Connect = time.Since(connectStart) },
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { timings.TLS = time.Since(tlsStart) },
+		GotFirstResponseByte: func() {
+			timings.TTFB = time.Since(start)
+		},
+	}
+	response, err := t.base.RoundTrip(request.WithContext(httptrace.WithClientTrace(request.Context(), trace)))
+	timings.Total = time.Since(start)
+	if err == nil {
+		t.observe(request, timings)
+	}
+	return response, err
+}
+
+// warmBootstrap downloads, one vantage point at a time, the bootstrap
+// registries the targets will need. An openrdap client fills its bootstrap
+// registries lazily, so -concurrency warms them once before fanning lookups
+// out to workers holding copies of the clients (see workerClients).
+func warmBootstrap(clients []vantageClient, targets []string, domainMode bool) {
+	needed := map[bootstrap.RegistryType]bool{}
+	for _, target := range targets {
+		request, registry, ok := targetRequest(target, domainMode)
+		// Targets answered by -endpoints never consult the bootstrap.
+		if ok && customEndpoints.forRequest(request) == nil {
+			needed[registry] = true
+		}
+	}
+	for _, vantage := range clients {
+		for _, registry := range []bootstrap.RegistryType{bootstrap.ASN, bootstrap.IPv4, bootstrap.IPv6, bootstrap.DNS, bootstrap.ServiceProvider} {
+			if !needed[registry] {
+				continue
+			}
+			if err := vantage.Client.Bootstrap.Download(registry); err != nil {
+				slog.Warn("bootstrap warm-up failed", "vantage", vantage.Vantage, "registry", registry.Filename(), "error", err)
+			}
+		}
+	}
+}
+
+// targetRequest returns the RDAP request a batch target is looked up with
+// and the bootstrap registry that routes it; ok is false for targets that
+// are not valid ASNs, IP addresses, prefixes or (in domain mode) names.
+func targetRequest(target string, domainMode bool) (request *rdap.Request, registry bootstrap.RegistryType, ok bool) {
+	if handle, entity := entityTarget(target); entity {
+		return rdap.NewRequest(rdap.EntityRequest, handle), bootstrap.ServiceProvider, handle != ""
+	}
+	if name, nameserver := nameserverTarget(target); nameserver {
+		// Nameservers are asked at the registry of their domain.
+		target, domainMode = name, true
+	}
+	network, isNetwork := parseNetworkTarget(target)
+	switch {
+	case domainMode:
+		name, err := parseDomainName(target)
+		if err != nil {
+			return nil, 0, false
+		}
+		return rdap.NewRequest(rdap.DomainRequest, name), bootstrap.DNS, true
+	case !isNetwork:
+		asn, err := parseASN(target)
+		if err != nil {
+			return nil, 0, false
+		}
+		return rdap.NewRequest(rdap.AutnumRequest, strconv.FormatInt(asn, 10)), bootstrap.ASN, true
+	case strings.Contains(network, ":"):
+		return rdap.NewRequest(rdap.IPRequest, network), bootstrap.IPv6, true
+	default:
+		return rdap.NewRequest(rdap.IPRequest, network), bootstrap.IPv4, true
+	}
+}
+
+// workerClients copies the vantage clients for one -concurrency worker. An
+// openrdap client writes its own fields, and its parsed bootstrap
+// registries, on every query, so workers must not share one; the copies
+// share the HTTP clients, their transports and the bootstrap files
+// downloaded by warmBootstrap.
+func workerClients(clients []vantageClient) []vantageClient {
+	copies := make([]vantageClient, len(clients))
+	for index, vantage := range clients {
+		client := *vantage.Client
+		if shared := client.Bootstrap; shared != nil {
+			client.Bootstrap = &bootstrap.Client{HTTP: shared.HTTP, BaseURL: shared.BaseURL, Cache: shared.Cache}
+			if view, ok := shared.Cache.(*bootstrapFileCache); ok {
+				client.Bootstrap.Cache = view.files.view()
+			}
+		}
+		vantage.Client = &client
+		copies[index] = vantage
+	}
+	return copies
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommands lists the copy and paste helpers tried in order for the
+// current platform. The first one found on PATH is used.
+func clipboardCommands() (copyCommands, pasteCommands [][]string) {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}, [][]string{{"pbpaste"}}
+	case "windows":
+		return [][]string{{"clip.exe"}},
+			[][]string{{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"}}
+	default:
+		return [][]string{{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}, {"clip.exe"}},
+			[][]string{{"wl-paste", "--no-newline"}, {"xclip", "-selection", "clipboard", "-o"}, {"xsel", "--clipboard", "--output"}}
+	}
+}
+
+func findClipboardCommand(candidates [][]string) ([]string, error) {
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate[0]); err == nil {
+			return candidate, nil
+		}
+	}
+	var names []string
+	for _, candidate := range candidates {
+		names = append(names, candidate[0])
+	}
+	return nil, errors.New("no clipboard tool found (tried " + strings.Join(names, ", ") + ")")
+}
+
+// copyToClipboard places text on the system clipboard.
+func copyToClipboard(text string) error {
+	copyCommands, _ := clipboardCommands()
+	command, err := findClipboardCommand(copyCommands)
+	if err != nil {
+		return err
+	}
+	process := exec.Command(command[0], command[1:]...)
+	process.Stdin = strings.NewReader(text)
+	return process.Run()
+}
+
+// readClipboard returns the current clipboard text.
+func readClipboard() (string, error) {
+	_, pasteCommands := clipboardCommands()
+	command, err := findClipboardCommand(pasteCommands)
+	if err != nil {
+		return "", err
+	}
+	output, err := exec.Command(command[0], command[1:]...).Output()
+	return string(output), err
+}
+
+// clipboardTargets splits clipboard text into ASN targets, accepting any mix
+// of whitespace, commas and semicolons and ignoring tokens that are not ASNs.
+func clipboardTargets(text string) (targets []string, ignored int) {
+	tokens := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	for _, token := range tokens {
+		token = strings.TrimSuffix(token, ":")
+		if _, err := parseASN(token); err != nil {
+			ignored++
+			continue
+		}
+		targets = append(targets, token)
+	}
+	return targets, ignored
+}
+
+// clipboardText renders results for --copy (the result lines, or just the
+// name for a single successful lookup) or --copy-json (the raw RDAP objects
+// as a JSON array). Signed results (-sign) are exported as {"rdap": object,
+// "provenance": signature} pairs instead of bare objects.
+func clipboardText(results []lookupResult, asJSON bool) (string, error) {
+	if asJSON {
+		objects := []any{}
+		for _, result := range results {
+			if result.Err != nil || result.Fetch == nil || !json.Valid(result.Fetch.RawBody) {
+				continue
+			}
+			if result.Provenance != nil {
+				wrapped := map[string]any{"rdap": json.RawMessage(result.Fetch.RawBody), "provenance": result.Provenance}
+				if !result.Fetch.ValidUntil.IsZero() {
+					wrapped["valid_until"] = result.Fetch.ValidUntil
+				}
+				objects = append(objects, wrapped)
+			} else {
+				objects = append(objects, json.RawMessage(result.Fetch.RawBody))
+			}
+		}
+		var indented bytes.Buffer
+		encoded, err := json.Marshal(objects)
+		if err == nil {
+			err = json.Indent(&indented, encoded, "", "  ")
+		}
+		return indented.String(), err
+	}
+	if len(results) == 1 && results[0].Err == nil {
+		return results[0].Name, nil
+	}
+	var lines []string
+	for _, result := range results {
+		lines = append(lines, result.line())
+	}
+	return strings.Join(lines, "\n"), nil
+}
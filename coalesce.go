@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// lookupCoalescer merges identical lookups that start within a window of
+// each other into one upstream request whose answer every caller shares. It
+// is not a cache: an entry lives only for the window after its first caller
+// started, so it absorbs bursts (dashboards refreshing together, duplicate
+// input rows) without ever serving stale data past the window.
+type lookupCoalescer struct {
+	window time.Duration
+	mu     sync.Mutex
+	calls  map[string]*coalescedLookup
+}
+
+// coalescedLookup is one upstream lookup and the callers waiting on it.
+type coalescedLookup struct {
+	done   chan struct{}
+	result lookupResult
+}
+
+// newLookupCoalescer returns a coalescer for window, or nil (every lookup
+// goes upstream) when window is not positive.
+func newLookupCoalescer(window time.Duration) *lookupCoalescer {
+	if window <= 0 {
+		return nil
+	}
+	return &lookupCoalescer{window: window, calls: make(map[string]*coalescedLookup)}
+}
+
+// do runs lookup for key, unless a lookup for the same key started less than
+// the window ago, in which case it waits for that one and returns its answer.
+// c may be nil.
+func (c *lookupCoalescer) do(key string, lookup func() lookupResult) lookupResult {
+	if c == nil {
+		return lookup()
+	}
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		slog.Debug("coalesced lookup", "key", key)
+		return call.result
+	}
+	call := &coalescedLookup{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+	time.AfterFunc(c.window, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.calls[key] == call {
+			delete(c.calls, key)
+		}
+	})
+	call.result = lookup()
+	close(call.done)
+	return call.result
+}
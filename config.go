@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// configEnvPrefix starts the environment variables setting flag defaults:
+// RDAP_TESTER_TIMEOUT sets -timeout, RDAP_TESTER_CACHE_DIR sets -cache-dir.
+const configEnvPrefix = "RDAP_TESTER_"
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/rdap-tester/config.yaml,
+// defaulting to ~/.config/rdap-tester/config.yaml; RDAP_TESTER_CONFIG
+// names another file.
+func defaultConfigPath() (string, error) {
+	if path := os.Getenv(configEnvPrefix + "CONFIG"); path != "" {
+		return path, nil
+	}
+	return configFilePath("config.yaml")
+}
+
+// loadConfig reads a config file of "flag-name: value" lines, a flat YAML
+// mapping. Values may be quoted; blank lines and comments are ignored. A
+// missing file is not an error.
+//
+//	timeout: 30s
+//	concurrency: 4
+//	proxy: socks5://127.0.0.1:1080
+//	json: true
+func loadConfig(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		// Indented lines would be nested mappings, which no flag takes.
+		if !ok || line != strings.TrimLeft(line, " \t") {
+			return nil, fmt.Errorf("%s:%d: expected \"name: value\" at the start of the line, got %q", path, lineNumber, line)
+		}
+		key = strings.ReplaceAll(strings.TrimSpace(key), "_", "-")
+		if value, err = configValue(strings.TrimSpace(value)); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		if _, duplicate := config[key]; duplicate {
+			return nil, fmt.Errorf("%s:%d: %s is set twice", path, lineNumber, key)
+		}
+		config[key] = value
+	}
+	return config, scanner.Err()
+}
+
+// configValue unquotes a YAML scalar and strips a trailing comment from an
+// unquoted one.
+func configValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted value %s", value)
+		}
+		return unquoted, nil
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("invalid quoted value %s", value)
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	}
+	if index := strings.Index(value, " #"); index >= 0 {
+		value = strings.TrimSpace(value[:index])
+	}
+	return value, nil
+}
+
+// loadedConfig is the config file, read once per process.
+var loadedConfig = sync.OnceValues(func() (map[string]string, error) {
+	path, err := defaultConfigPath()
+	if err != nil {
+		return nil, nil
+	}
+	return loadConfig(path)
+})
+
+// applyConfigDefaults sets the flags of flagSet that the config file or
+// an RDAP_TESTER_ environment variable gives a value, the environment
+// taking precedence. It runs after the command line was parsed and leaves
+// the flags given there alone, so they override both; repeatable flags
+// such as -budget do not add to the configured values. Only the flags
+// shared through clientOptions and those of the lookup command are
+// configured; a command's own flag of the same name, such as mock-server's
+// -rate-limit, is left alone.
+func applyConfigDefaults(flagSet *flag.FlagSet) error {
+	config, err := loadedConfig()
+	if err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	path, _ := defaultConfigPath()
+	warnUnknownConfigKeys(path, config)
+	given := map[string]bool{}
+	flagSet.Visit(func(set *flag.Flag) { given[set.Name] = true })
+	var firstErr error
+	flagSet.VisitAll(func(defined *flag.Flag) {
+		if given[defined.Name] || !configurable(flagSet, defined) {
+			return
+		}
+		origin := configEnvVar(defined.Name)
+		value, ok := os.LookupEnv(origin)
+		if !ok {
+			value, ok = config[defined.Name]
+			origin = path + ": " + defined.Name
+		}
+		if !ok || firstErr != nil {
+			return
+		}
+		if err := flagSet.Set(defined.Name, value); err != nil {
+			firstErr = fmt.Errorf("%s: invalid value %q: %v", origin, value, err)
+		}
+	})
+	return firstErr
+}
+
+// lookupConfigFlags are the flags of the lookup command, besides the
+// clientOptions ones, that config keys and environment variables set.
+var lookupConfigFlags = []string{
+	"archive", "as-of", "checkpoint", "classes", "concurrency", "copy",
+	"copy-json", "csv", "dedup-window", "domain", "dry-run", "end-line",
+	"entity-server", "explain", "f", "fail-on", "fields", "filter",
+	"follow-ups", "format", "group-by", "hook", "include-raw", "json",
+	"jsonl", "label-skipped", "lang", "latency", "list-networks",
+	"max-memory", "no-cache", "cache-ttl", "ns", "offline", "ordered",
+	"overrides", "paste", "quiet", "raw", "resume", "reverse", "sign", "sink",
+	"skip", "skip-file", "spread", "sqlite", "start-line", "stats",
+	"unordered", "vantage", "watch", "whois-fallback",
+}
+
+// clientConfigFlags holds the flags clientOptions registers, which every
+// command taking them has configured.
+var clientConfigFlags = sync.OnceValue(func() *flag.FlagSet {
+	flagSet := flag.NewFlagSet("", flag.ContinueOnError)
+	var options clientOptions
+	options.registerFlags(flagSet)
+	return flagSet
+})
+
+// configurable reports whether defined, a flag of flagSet, takes config
+// values: one of clientOptions, recognized by its usage as commands may
+// define flags of the same name, or one of the lookup command.
+func configurable(flagSet *flag.FlagSet, defined *flag.Flag) bool {
+	if shared := clientConfigFlags().Lookup(defined.Name); shared != nil {
+		return shared.Usage == defined.Usage
+	}
+	return flagSet.Name() == "rdap-test" && slices.Contains(lookupConfigFlags, defined.Name)
+}
+
+// warnedConfigKeys makes warnUnknownConfigKeys warn once per process.
+var warnedConfigKeys sync.Once
+
+// warnUnknownConfigKeys warns about config keys that name no configurable
+// flag, typically misspelled ones or flags of other commands.
+func warnUnknownConfigKeys(path string, config map[string]string) {
+	warnedConfigKeys.Do(func() {
+		keys := make([]string, 0, len(config))
+		for key := range config {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if clientConfigFlags().Lookup(key) == nil && !slices.Contains(lookupConfigFlags, key) {
+				slog.Warn("config key names no configurable flag; ignored", "file", path, "key", key)
+			}
+		}
+	})
+}
+
+// configEnvVar returns the environment variable setting flag name, e.g.
+// RDAP_TESTER_CACHE_DIR for cache-dir.
+func configEnvVar(name string) string {
+	return configEnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// conformanceCheck is a single request with the HTTP statuses a conforming
+// server may answer it with.
+type conformanceCheck struct {
+	Name           string
+	Path           string // relative to the base URL
+	AllowedStatus  []int
+	ExpectRDAPBody bool // the answer must be an RFC 9083 error object
+}
+
+// negativeConformanceChecks are deliberately invalid queries. RFC 7480 asks
+// for 404 on unknown objects and 400 on malformed queries; either way the
+// body should be an RDAP error object rather than an HTML page.
+var negativeConformanceChecks = []conformanceCheck{
+	{"nonexistent ASN", "autnum/4294967294", []int{http.StatusNotFound}, true},
+	{"malformed autnum", "autnum/not-a-number", []int{http.StatusBadRequest, http.StatusNotFound}, true},
+	{"malformed IP address", "ip/999.1.2.3", []int{http.StatusBadRequest, http.StatusNotFound}, true},
+	{"malformed entity handle", "entity/%20%3C%3E", []int{http.StatusBadRequest, http.StatusNotFound}, true},
+	{"unsupported path", "no-such-object-class/1", []int{http.StatusBadRequest, http.StatusNotFound, http.StatusNotImplemented}, true},
+}
+
+func runConformance(args []string) int {
+	flagSet := newFlagSet("conformance")
+	var options clientOptions
+	options.registerFlags(flagSet)
+	maxRedirects := flagSet.Int("max-redirects", 2, "flag answers reached through more redirects than this")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . conformance [flags] <base-url>")
+		flagSet.PrintDefaults()
+	}
+	positional, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	if err := options.validate(); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if len(positional) != 1 {
+		flagSet.Usage()
+		return 2
+	}
+	baseURL := strings.TrimSuffix(positional[0], "/") + "/"
+	httpClient := newRDAPClient(options).HTTP
+
+	fmt.Printf("RDAP server: %s\n", baseURL)
+	failures := 0
+	for _, check := range negativeConformanceChecks {
+		problems := runConformanceCheck(httpClient, baseURL, check, *maxRedirects)
+		if len(problems) == 0 {
+			fmt.Printf("  PASS  %s\n", check.Name)
+			continue
+		}
+		failures++
+		fmt.Printf("  FAIL  %s (%s)\n", check.Name, check.Path)
+		for _, problem := range problems {
+			fmt.Printf("          - %s\n", problem)
+		}
+	}
+	fmt.Printf("%d/%d checks passed\n", len(negativeConformanceChecks)-failures, len(negativeConformanceChecks))
+	if failures > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runConformanceCheck performs one check and returns the list of violations found.
+func runConformanceCheck(httpClient *http.Client, baseURL string, check conformanceCheck, maxRedirects int) []string {
+	exchange, err := rdapGet(context.Background(), httpClient, baseURL+check.Path)
+	if err != nil {
+		return append(exchange.Redirects.problems(maxRedirects), "request failed: "+err.Error())
+	}
+
+	problems := exchange.Redirects.problems(maxRedirects)
+	if !slices.Contains(check.AllowedStatus, exchange.StatusCode) {
+		problems = append(problems, fmt.Sprintf("HTTP %d, expected one of %v", exchange.StatusCode, check.AllowedStatus))
+	}
+	if !check.ExpectRDAPBody || exchange.StatusCode == http.StatusNotImplemented {
+		return problems
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(exchange.ContentType)
+	if mediaType != "application/rdap+json" {
+		problems = append(problems, fmt.Sprintf("content type %q, expected application/rdap+json", exchange.ContentType))
+	}
+	var errorObject struct {
+		ErrorCode   *int     `json:"errorCode"`
+		Title       string   `json:"title"`
+		Description []string `json:"description"`
+	}
+	if err := json.Unmarshal(exchange.Body, &errorObject); err != nil {
+		return append(problems, "body is not JSON (likely an HTML error page)")
+	}
+	if errorObject.ErrorCode == nil {
+		problems = append(problems, "RDAP error object has no errorCode")
+	} else if *errorObject.ErrorCode != exchange.StatusCode {
+		problems = append(problems, fmt.Sprintf("errorCode %d does not match HTTP status %d", *errorObject.ErrorCode, exchange.StatusCode))
+	}
+	return problems
+}
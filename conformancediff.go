@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// queryIndexPattern matches array indexes in flattened JSON paths, which
+// are dropped to compare the structure of two documents.
+var queryIndexPattern = regexp.MustCompile(`\[\d+\]`)
+
+// conformanceQueryPath turns a -queries line into a path relative to an
+// RDAP base URL: paths such as "autnum/15169" or "help" are kept, and bare
+// ASNs, IP addresses or prefixes and domain names become lookups.
+func conformanceQueryPath(query string) (string, error) {
+	query = strings.TrimPrefix(query, "/")
+	if query == "help" {
+		return query, nil
+	}
+	for _, prefix := range []string{"autnum/", "ip/", "domain/", "entity/", "nameserver/", "domains?", "entities?", "nameservers?"} {
+		if strings.HasPrefix(query, prefix) {
+			return query, nil
+		}
+	}
+	if network, ok := parseNetworkTarget(query); ok {
+		return "ip/" + network, nil
+	}
+	if asn, err := parseASN(query); err == nil {
+		return "autnum/" + strconv.FormatInt(asn, 10), nil
+	}
+	if name, err := parseDomainName(query); err == nil {
+		return "domain/" + name, nil
+	}
+	return "", fmt.Errorf("%q is neither an RDAP path nor an ASN, IP address, prefix or domain name", query)
+}
+
+// serverAnswer is one server's answer to a query, normalized for comparison.
+type serverAnswer struct {
+	Status      int
+	MediaType   string
+	Conformance []string
+	Fields      map[string]string // flattened members, base URL replaced by {base}
+	Err         error
+}
+
+func fetchServerAnswer(httpClient *http.Client, baseURL, path string) serverAnswer {
+	exchange, err := rdapGet(context.Background(), httpClient, baseURL+path)
+	if err != nil {
+		return serverAnswer{Err: err}
+	}
+	answer := serverAnswer{Status: exchange.StatusCode}
+	answer.MediaType, _, _ = mime.ParseMediaType(exchange.ContentType)
+	var document map[string]any
+	if err := json.Unmarshal(exchange.Body, &document); err != nil {
+		return answer
+	}
+	answer.Fields = map[string]string{}
+	for key, value := range document {
+		if key == "rdapConformance" {
+			if tags, ok := value.([]any); ok {
+				for _, tag := range tags {
+					answer.Conformance = append(answer.Conformance, fmt.Sprint(tag))
+				}
+				sort.Strings(answer.Conformance)
+			}
+			continue
+		}
+		flattenJSON(key, value, answer.Fields)
+	}
+	for path, value := range answer.Fields {
+		answer.Fields[path] = strings.ReplaceAll(value, baseURL, "{base}/")
+	}
+	return answer
+}
+
+// structure returns the member paths of the answer without array indexes.
+func (a serverAnswer) structure() map[string]bool {
+	paths := map[string]bool{}
+	for path := range a.Fields {
+		paths[queryIndexPattern.ReplaceAllString(path, "[]")] = true
+	}
+	return paths
+}
+
+// compareAnswers lists the differences between two answers to one query:
+// status and media type, conformance tags, members present on only one
+// side (structure), then differing values (content).
+func compareAnswers(a, b serverAnswer) []string {
+	var differences []string
+	if a.Err != nil || b.Err != nil {
+		if errorText(a.Err) != errorText(b.Err) {
+			differences = append(differences, fmt.Sprintf("request: %v -> %v", errorText(a.Err), errorText(b.Err)))
+		}
+		return differences
+	}
+	if a.Status != b.Status {
+		differences = append(differences, fmt.Sprintf("status: %d -> %d", a.Status, b.Status))
+	}
+	if a.MediaType != b.MediaType {
+		differences = append(differences, fmt.Sprintf("content type: %s -> %s", a.MediaType, b.MediaType))
+	}
+	for _, tag := range a.Conformance {
+		if !slices.Contains(b.Conformance, tag) {
+			differences = append(differences, "conformance: only A declares "+tag)
+		}
+	}
+	for _, tag := range b.Conformance {
+		if !slices.Contains(a.Conformance, tag) {
+			differences = append(differences, "conformance: only B declares "+tag)
+		}
+	}
+	structureA, structureB := a.structure(), b.structure()
+	var structural []string
+	for path := range structureA {
+		if !structureB[path] {
+			structural = append(structural, "structure: only in A: "+path)
+		}
+	}
+	for path := range structureB {
+		if !structureA[path] {
+			structural = append(structural, "structure: only in B: "+path)
+		}
+	}
+	sort.Strings(structural)
+	differences = append(differences, structural...)
+	for _, change := range diffFields(a.Fields, b.Fields) {
+		// Members missing on one side are already reported as structure.
+		if structureA[queryIndexPattern.ReplaceAllString(change.Path, "[]")] && structureB[queryIndexPattern.ReplaceAllString(change.Path, "[]")] {
+			differences = append(differences, fmt.Sprintf("content: %s: %q -> %q", change.Path, change.Before, change.After))
+		}
+	}
+	return differences
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return err.Error()
+}
+
+func runConformanceDiff(args []string) int {
+	flagSet := newFlagSet("conformance-diff")
+	var options clientOptions
+	options.registerFlags(flagSet)
+	queriesPath := flagSet.String("queries", "", "`file` of queries, one per line: RDAP paths such as autnum/15169 or help, or bare ASNs, IPs, prefixes and domains (# starts a comment; - is stdin)")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . conformance-diff [flags] <base-url-A> <base-url-B> --queries q.txt")
+		flagSet.PrintDefaults()
+	}
+	positional, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	if err := options.validate(); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if len(positional) != 2 || *queriesPath == "" {
+		flagSet.Usage()
+		return 2
+	}
+	queries, err := readTargetsFile(*queriesPath)
+	if err != nil {
+		fmt.Printf("conformance-diff: %v\n", err)
+		return 2
+	}
+	var paths []string
+	for _, query := range queries {
+		path, err := conformanceQueryPath(query)
+		if err != nil {
+			fmt.Printf("conformance-diff: %v\n", err)
+			return 2
+		}
+		paths = append(paths, path)
+	}
+	baseA := strings.TrimSuffix(positional[0], "/") + "/"
+	baseB := strings.TrimSuffix(positional[1], "/") + "/"
+	httpClient := newRDAPClient(options).HTTP
+
+	fmt.Printf("A: %s\nB: %s\n\n", baseA, baseB)
+	differing := 0
+	for _, path := range paths {
+		differences := compareAnswers(fetchServerAnswer(httpClient, baseA, path), fetchServerAnswer(httpClient, baseB, path))
+		if len(differences) == 0 {
+			fmt.Printf("  SAME    %s\n", path)
+			continue
+		}
+		differing++
+		fmt.Printf("  DIFFER  %s\n", path)
+		for _, difference := range differences {
+			fmt.Printf("          %s\n", difference)
+		}
+	}
+	fmt.Printf("\n%d of %d queries differ\n", differing, len(paths))
+	if differing > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// consoleCapabilities describes what the console views (batch output, watch
+// mode, TUI) may emit.
+type consoleCapabilities struct {
+	Color bool // ANSI colors, reverse video and cursor control
+	ASCII bool // ASCII-only rules and ellipses instead of Unicode drawing characters
+	// NameLimit is the longest name, in runes, shown before it is truncated
+	// with an ellipsis; 0 shows names in full.
+	NameLimit int
+}
+
+// console is set once at startup by configureConsole.
+var console = consoleCapabilities{Color: true, NameLimit: defaultDisplayNameLimit}
+
+// configureConsole disables color when asked (--no-color or NO_COLOR), when
+// stdout is not a terminal, for TERM=dumb, and on legacy Windows consoles
+// that do not interpret ANSI sequences. Those consoles also get ASCII
+// output, as does --ascii-tables. Names are truncated to nameLimit runes.
+func configureConsole(noColor, asciiTables bool, nameLimit int) {
+	legacyWindows := runtime.GOOS == "windows" && os.Getenv("WT_SESSION") == "" &&
+		os.Getenv("ANSICON") == "" && os.Getenv("ConEmuANSI") != "ON" && os.Getenv("TERM") == ""
+	_, noColorEnv := os.LookupEnv("NO_COLOR")
+	console = consoleCapabilities{
+		Color: !noColor && !noColorEnv && !legacyWindows && os.Getenv("TERM") != "dumb" &&
+			term.IsTerminal(int(os.Stdout.Fd())),
+		ASCII:     asciiTables || legacyWindows,
+		NameLimit: nameLimit,
+	}
+}
+
+// styled wraps text in an SGR style such as "1;7" when color is enabled.
+func styled(style, text string) string {
+	if !console.Color || style == "" {
+		return text
+	}
+	return "\x1b[" + style + "m" + text + "\x1b[0m"
+}
+
+// horizontalRule returns a rule width columns wide.
+func horizontalRule(width int) string {
+	if console.ASCII {
+		return strings.Repeat("-", width)
+	}
+	return strings.Repeat("─", width)
+}
+
+// ellipsis marks truncated text.
+func ellipsis() string {
+	if console.ASCII {
+		return "..."
+	}
+	return "…"
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/openrdap/rdap"
+)
+
+// contactRoles are the RDAP entity roles extracted as contacts, in the
+// order they are printed.
+var contactRoles = []string{"registrant", "administrative", "technical", "abuse"}
+
+// contact is one entity of a record acting in one of contactRoles.
+type contact struct {
+	Role         string `json:"role"`
+	Handle       string `json:"handle,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Phone        string `json:"phone,omitempty"`
+	Address      string `json:"address,omitempty"`
+}
+
+// contactEntity is the part of an RDAP entity contacts are read from.
+type contactEntity struct {
+	Handle     string          `json:"handle"`
+	Roles      []string        `json:"roles"`
+	VCardArray json.RawMessage `json:"vcardArray"`
+	Entities   []contactEntity `json:"entities"`
+}
+
+// contactsOf extracts the contacts of the RDAP document body from the
+// vCards of its entities, including entities nested in other entities
+// (registries such as ARIN list the abuse contact under the registrant).
+// An entity with several of contactRoles is listed once per role.
+func contactsOf(body []byte) []contact {
+	var document struct {
+		Entities []contactEntity `json:"entities"`
+	}
+	if len(body) == 0 || json.Unmarshal(body, &document) != nil {
+		return nil
+	}
+	var contacts []contact
+	var walk func(entities []contactEntity)
+	walk = func(entities []contactEntity) {
+		for _, entity := range entities {
+			for _, role := range entity.Roles {
+				if slices.Contains(contactRoles, role) {
+					contacts = appendContact(contacts, entity.contact(role))
+				}
+			}
+			walk(entity.Entities)
+		}
+	}
+	walk(document.Entities)
+	slices.SortStableFunc(contacts, func(a, b contact) int {
+		return slices.Index(contactRoles, a.Role) - slices.Index(contactRoles, b.Role)
+	})
+	return contacts
+}
+
+// appendContact adds c unless an identical contact is already listed, as
+// when a record embeds the same entity twice.
+func appendContact(contacts []contact, c contact) []contact {
+	if slices.Contains(contacts, c) {
+		return contacts
+	}
+	return append(contacts, c)
+}
+
+func (e contactEntity) contact(role string) contact {
+	c := contact{Role: role, Handle: e.Handle}
+	if len(e.VCardArray) == 0 {
+		return c
+	}
+	vcard, err := rdap.NewVCard(e.VCardArray)
+	if err != nil {
+		return c
+	}
+	return c.withVCard(vcard)
+}
+
+// withVCard fills in the name, organization, email, phone and postal
+// address of vcard.
+func (c contact) withVCard(vcard *rdap.VCard) contact {
+	c.Name = strings.TrimSpace(vcard.Name())
+	c.Organization = registrantOrganization(vcard)
+	if c.Organization == c.Name {
+		c.Organization = ""
+	}
+	c.Email = strings.TrimSpace(vcard.Email())
+	c.Phone = strings.TrimSpace(vcard.Tel())
+	c.Address = formatAddress(vcard)
+	return c
+}
+
+// contacts returns the contacts of the record the result was answered from.
+func (r lookupResult) contacts() []contact {
+	if r.Fetch == nil || r.Err != nil {
+		return nil
+	}
+	return contactsOf(r.Fetch.RawBody)
+}
+
+// contactOfRole returns the first contact of role, if any.
+func (r lookupResult) contactOfRole(role string) (contact, bool) {
+	for _, c := range r.contacts() {
+		if c.Role == role {
+			return c, true
+		}
+	}
+	return contact{}, false
+}
+
+func (c contact) line() string {
+	var parts []string
+	if who := strings.Trim(c.Name+", "+c.Organization, ", "); who != "" {
+		parts = append(parts, who)
+	}
+	if c.Email != "" {
+		parts = append(parts, "<"+c.Email+">")
+	}
+	if c.Phone != "" {
+		parts = append(parts, c.Phone)
+	}
+	if c.Handle != "" {
+		parts = append(parts, "("+c.Handle+")")
+	}
+	line := fmt.Sprintf("  %s: %s", localize(c.Role), strings.Join(parts, " "))
+	if c.Address != "" {
+		line += "; " + c.Address
+	}
+	return line
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronExpression is a parsed five-field cron schedule
+// (minute hour day-of-month month day-of-week) in local time.
+type cronExpression struct {
+	minutes     [60]bool
+	hours       [24]bool
+	daysOfMonth [32]bool
+	months      [13]bool
+	daysOfWeek  [7]bool
+	// Standard cron semantics: when both day fields are restricted, a time
+	// matches if either of them matches.
+	daysOfMonthRestricted bool
+	daysOfWeekRestricted  bool
+}
+
+// cronField describes the accepted range of one cron field.
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7}, // 0 and 7 are both Sunday
+}
+
+// parseCronExpression accepts "*", numbers, ranges (1-5), lists (1,15) and
+// steps (*/15, 0-30/5) in each of the five standard fields.
+func parseCronExpression(expression string) (*cronExpression, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d in %q", len(fields), expression)
+	}
+
+	parsed := &cronExpression{}
+	for index, field := range fields {
+		values, err := parseCronField(field, cronFields[index])
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			switch index {
+			case 0:
+				parsed.minutes[value] = true
+			case 1:
+				parsed.hours[value] = true
+			case 2:
+				parsed.daysOfMonth[value] = true
+			case 3:
+				parsed.months[value] = true
+			case 4:
+				parsed.daysOfWeek[value%7] = true
+			}
+		}
+	}
+	parsed.daysOfMonthRestricted = fields[2] != "*"
+	parsed.daysOfWeekRestricted = fields[4] != "*"
+	return parsed, nil
+}
+
+func parseCronField(field string, spec cronField) ([]int, error) {
+	var values []int
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if rangePart, stepPart, found := strings.Cut(part, "/"); found {
+			parsedStep, err := strconv.Atoi(stepPart)
+			if err != nil || parsedStep <= 0 {
+				return nil, fmt.Errorf("invalid step %q in %s field", stepPart, spec.name)
+			}
+			part, step = rangePart, parsedStep
+		}
+
+		low, high := spec.min, spec.max
+		if part != "*" {
+			lowText, highText, isRange := strings.Cut(part, "-")
+			var err error
+			if low, err = strconv.Atoi(lowText); err != nil {
+				return nil, fmt.Errorf("invalid value %q in %s field", lowText, spec.name)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highText); err != nil {
+					return nil, fmt.Errorf("invalid value %q in %s field", highText, spec.name)
+				}
+			} else if step > 1 {
+				high = spec.max // "5/15" means from 5 to the end in steps of 15
+			}
+		}
+		if low < spec.min || high > spec.max || low > high {
+			return nil, fmt.Errorf("%s field %q out of range %d-%d", spec.name, part, spec.min, spec.max)
+		}
+		for value := low; value <= high; value += step {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// matchesDay applies the cron day-of-month / day-of-week rule.
+func (c *cronExpression) matchesDay(moment time.Time) bool {
+	dayOfMonth := c.daysOfMonth[moment.Day()]
+	dayOfWeek := c.daysOfWeek[int(moment.Weekday())]
+	if c.daysOfMonthRestricted && c.daysOfWeekRestricted {
+		return dayOfMonth || dayOfWeek
+	}
+	return dayOfMonth && dayOfWeek
+}
+
+// next returns the first matching minute strictly after from, or the zero
+// time if nothing matches within five years (e.g. "0 0 31 2 *").
+func (c *cronExpression) next(from time.Time) time.Time {
+	moment := from.Truncate(time.Minute).Add(time.Minute)
+	limit := from.AddDate(5, 0, 0)
+	for moment.Before(limit) {
+		if !c.months[int(moment.Month())] {
+			moment = time.Date(moment.Year(), moment.Month()+1, 1, 0, 0, 0, 0, moment.Location())
+			continue
+		}
+		if !c.matchesDay(moment) {
+			moment = time.Date(moment.Year(), moment.Month(), moment.Day()+1, 0, 0, 0, 0, moment.Location())
+			continue
+		}
+		if !c.hours[moment.Hour()] {
+			moment = time.Date(moment.Year(), moment.Month(), moment.Day(), moment.Hour()+1, 0, 0, 0, moment.Location())
+			continue
+		}
+		if !c.minutes[moment.Minute()] {
+			moment = moment.Add(time.Minute)
+			continue
+		}
+		return moment
+	}
+	return time.Time{}
+}
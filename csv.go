@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+)
+
+// defaultCSVFields are the -csv columns when -fields is not given.
+const defaultCSVFields = "asn,name,country,rir,handle,registered,warnings"
+
+// csvFields maps a -fields column name to its value for one result.
+var csvFields = map[string]func(r lookupResult, record resultRecord) string{
+	"asn": func(_ lookupResult, record resultRecord) string {
+		if record.ASN == 0 {
+			return ""
+		}
+		return strconv.FormatInt(record.ASN, 10)
+	},
+	"target":  func(_ lookupResult, record resultRecord) string { return record.Target },
+	"vantage": func(_ lookupResult, record resultRecord) string { return record.Vantage },
+	"line": func(_ lookupResult, record resultRecord) string {
+		if record.Line == 0 {
+			return ""
+		}
+		return strconv.Itoa(record.Line)
+	},
+	"name":    func(_ lookupResult, record resultRecord) string { return record.Name },
+	"country": func(_ lookupResult, record resultRecord) string { return record.Country },
+	"rir":     func(_ lookupResult, record resultRecord) string { return record.RIR },
+	"handle":  func(_ lookupResult, record resultRecord) string { return record.Handle },
+	"registered": func(r lookupResult, _ resultRecord) string {
+		switch {
+		case r.Domain != nil:
+			return r.Domain.Created
+		case r.Fetch != nil && r.Fetch.Record != nil:
+			return eventDate(r.Fetch.Record.Events, "registration")
+		}
+		return ""
+	},
+	"error": func(_ lookupResult, record resultRecord) string {
+		if record.Error == nil {
+			return ""
+		}
+		return *record.Error
+	},
+	"error_class": func(_ lookupResult, record resultRecord) string { return record.ErrorClass },
+	"http_status": func(_ lookupResult, record resultRecord) string {
+		if record.HTTPStatus == 0 {
+			return ""
+		}
+		return strconv.Itoa(record.HTTPStatus)
+	},
+	"error_code": func(_ lookupResult, record resultRecord) string {
+		if record.ErrorCode == 0 {
+			return ""
+		}
+		return strconv.Itoa(record.ErrorCode)
+	},
+	"source": func(_ lookupResult, record resultRecord) string { return record.Source },
+	"url":    func(_ lookupResult, record resultRecord) string { return record.URL },
+	"class":  func(_ lookupResult, record resultRecord) string { return record.Class },
+	"server": func(_ lookupResult, record resultRecord) string { return record.Server },
+	"port43": func(_ lookupResult, record resultRecord) string { return record.Port43 },
+	"valid_until": func(_ lookupResult, record resultRecord) string {
+		if record.ValidUntil.IsZero() {
+			return ""
+		}
+		return record.ValidUntil.Format(time.RFC3339)
+	},
+	"attempts": func(_ lookupResult, record resultRecord) string {
+		if len(record.Attempts) == 0 {
+			return ""
+		}
+		return strconv.Itoa(len(record.Attempts))
+	},
+	"failed_attempts": func(r lookupResult, _ resultRecord) string {
+		var reasons []string
+		for _, attempt := range rdaplookup.TransientFailures(r.attempts()) {
+			reasons = append(reasons, attempt.Reason())
+		}
+		return strings.Join(reasons, ";")
+	},
+	"tags":     func(_ lookupResult, record resultRecord) string { return strings.Join(record.Tags, ";") },
+	"warnings": func(_ lookupResult, record resultRecord) string { return strings.Join(record.Warnings, ";") },
+	"abuse_email": func(r lookupResult, _ resultRecord) string {
+		abuse, _ := r.contactOfRole("abuse")
+		return abuse.Email
+	},
+	"abuse_phone": func(r lookupResult, _ resultRecord) string {
+		abuse, _ := r.contactOfRole("abuse")
+		return abuse.Phone
+	},
+}
+
+// parseCSVFields validates a comma-separated -fields list.
+func parseCSVFields(list string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(list, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if _, ok := csvFields[field]; !ok {
+			known := make([]string, 0, len(csvFields))
+			for name := range csvFields {
+				known = append(known, name)
+			}
+			slices.Sort(known)
+			return nil, fmt.Errorf("-fields: unknown field %q (known: %s)", field, strings.Join(known, ", "))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// csvRow renders result as the values of fields.
+func (r lookupResult) csvRow(fields []string) []string {
+	record := r.record(false)
+	row := make([]string, len(fields))
+	for index, field := range fields {
+		row[index] = csvFields[field](r, record)
+	}
+	return row
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+	rdap "github.com/openrdap/rdap"
+)
+
+// diffSide is one of the two objects being compared.
+type diffSide struct {
+	Label   string
+	Record  *rdap.Autnum
+	RawBody []byte
+}
+
+// entityContact is the comparable contact data of one entity.
+type entityContact struct {
+	Handle string
+	Roles  []string
+	Name   string
+	Emails []string
+	Phones []string
+}
+
+func runDiff(args []string) int {
+	flagSet := newFlagSet("diff")
+	var options clientOptions
+	options.registerFlags(flagSet)
+	against := flagSet.String("against", "", "compare a single target with its \"cached\" snapshot instead of a second target")
+	showAll := flagSet.Bool("all", false, "also list every differing raw field")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . diff [flags] <ASN> <ASN>\n       go run . diff [flags] <ASN> --against cached")
+		flagSet.PrintDefaults()
+	}
+	targets, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	if err := options.validate(); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if (*against == "" && len(targets) != 2) || (*against != "" && len(targets) != 1) {
+		flagSet.Usage()
+		return 2
+	}
+	if *against != "" && *against != "cached" {
+		fmt.Printf("diff: unsupported --against %q (only \"cached\")\n", *against)
+		return 2
+	}
+
+	client := newRDAPClient(options)
+	var sides []diffSide
+	if *against == "cached" {
+		// Load the snapshot before the live query replaces it.
+		asn, err := parseASN(targets[0])
+		if err != nil {
+			fmt.Printf("%s: invalid ASN: %v\n", targets[0], err)
+			return 2
+		}
+		key := fmt.Sprintf("AS%d", asn)
+		rawBody, savedAt, err := loadSnapshot(key)
+		if err != nil {
+			fmt.Printf("diff: %v\n", err)
+			return 1
+		}
+		cached, err := decodeAutnum(rawBody)
+		if err != nil {
+			fmt.Printf("diff: cached %s: %v\n", key, err)
+			return 1
+		}
+		sides = append(sides, diffSide{Label: key + " (cached " + savedAt.Format(time.RFC3339) + ")", Record: cached, RawBody: rawBody})
+	}
+	for _, target := range targets {
+		asn, err := parseASN(target)
+		if err != nil {
+			fmt.Printf("%s: invalid ASN: %v\n", target, err)
+			return 2
+		}
+		fetch, err := fetchAutnum(context.Background(), client, asn)
+		if err != nil {
+			fmt.Printf("AS%d: error: %v\n", asn, err)
+			return 1
+		}
+		label := fmt.Sprintf("AS%d", asn)
+		if *against != "" {
+			label += " (live)"
+		}
+		sides = append(sides, diffSide{Label: label, Record: fetch.Record, RawBody: fetch.RawBody})
+	}
+
+	printObjectDiff(sides[0], sides[1])
+	printEntityDiff(sides[0], sides[1])
+	if *showAll {
+		before, errBefore := normalizeRDAPResponse(sides[0].RawBody)
+		after, errAfter := normalizeRDAPResponse(sides[1].RawBody)
+		if errBefore == nil && errAfter == nil {
+			fmt.Println("\nRaw field differences:")
+			for _, change := range diffFields(before, after) {
+				fmt.Printf("  %s: %q -> %q\n", change.Path, change.Before, change.After)
+			}
+		}
+	}
+	return 0
+}
+
+// decodeAutnum decodes a raw RDAP autnum document.
+func decodeAutnum(rawBody []byte) (*rdap.Autnum, error) {
+	object, err := rdap.NewDecoder(rawBody).Decode()
+	if err != nil {
+		return nil, err
+	}
+	autnumRecord, ok := object.(*rdap.Autnum)
+	if !ok {
+		return nil, fmt.Errorf("not an autnum object (%T)", object)
+	}
+	return autnumRecord, nil
+}
+
+func printObjectDiff(left, right diffSide) {
+	rows := [][3]string{
+		{"handle", left.Record.Handle, right.Record.Handle},
+		{"name", left.Record.Name, right.Record.Name},
+		{"organization", rdaplookup.ExtractName(left.Record), rdaplookup.ExtractName(right.Record)},
+		{"country", left.Record.Country, right.Record.Country},
+		{"type", left.Record.Type, right.Record.Type},
+		{"status", strings.Join(left.Record.Status, ","), strings.Join(right.Record.Status, ",")},
+		{"port43", left.Record.Port43, right.Record.Port43},
+		{"registration", eventDate(left.Record.Events, "registration"), eventDate(right.Record.Events, "registration")},
+		{"last changed", eventDate(left.Record.Events, "last changed"), eventDate(right.Record.Events, "last changed")},
+	}
+	fmt.Printf("%-14s %-36s %-36s\n", "field", left.Label, right.Label)
+	for _, row := range rows {
+		marker := " "
+		if row[1] != row[2] {
+			marker = "*"
+		}
+		fmt.Printf("%s %-12s %-36s %-36s\n", marker, row[0], row[1], row[2])
+	}
+}
+
+func eventDate(events []rdap.Event, action string) string {
+	for _, event := range events {
+		if strings.EqualFold(event.Action, action) {
+			return event.Date
+		}
+	}
+	return ""
+}
+
+// printEntityDiff lists entities present on both sides (with any contact
+// differences) and those present on only one side.
+func printEntityDiff(left, right diffSide) {
+	leftContacts := contactsByHandle(left.Record.Entities)
+	rightContacts := contactsByHandle(right.Record.Entities)
+
+	var shared, onlyLeft, onlyRight []string
+	for handle := range leftContacts {
+		if _, ok := rightContacts[handle]; ok {
+			shared = append(shared, handle)
+		} else {
+			onlyLeft = append(onlyLeft, handle)
+		}
+	}
+	for handle := range rightContacts {
+		if _, ok := leftContacts[handle]; !ok {
+			onlyRight = append(onlyRight, handle)
+		}
+	}
+	sort.Strings(shared)
+	sort.Strings(onlyLeft)
+	sort.Strings(onlyRight)
+
+	fmt.Println("\nShared entities:")
+	if len(shared) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, handle := range shared {
+		a, b := leftContacts[handle], rightContacts[handle]
+		fmt.Printf("  %s [%s]\n", handle, strings.Join(a.Roles, ","))
+		for _, difference := range contactDifferences(a, b) {
+			fmt.Printf("    * %s\n", difference)
+		}
+	}
+	printEntityList("Only in "+left.Label, onlyLeft, leftContacts)
+	printEntityList("Only in "+right.Label, onlyRight, rightContacts)
+}
+
+func printEntityList(title string, handles []string, contacts map[string]entityContact) {
+	fmt.Printf("\n%s:\n", title)
+	if len(handles) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, handle := range handles {
+		contact := contacts[handle]
+		fmt.Printf("  %s [%s] %s %s\n", handle, strings.Join(contact.Roles, ","), contact.Name, strings.Join(contact.Emails, ","))
+	}
+}
+
+func contactDifferences(a, b entityContact) []string {
+	var differences []string
+	compare := func(field string, before, after []string) {
+		if !slices.Equal(before, after) {
+			differences = append(differences, fmt.Sprintf("%s: %v -> %v", field, before, after))
+		}
+	}
+	compare("roles", a.Roles, b.Roles)
+	compare("name", []string{a.Name}, []string{b.Name})
+	compare("email", a.Emails, b.Emails)
+	compare("phone", a.Phones, b.Phones)
+	return differences
+}
+
+// contactsByHandle flattens nested entities and indexes their contact data.
+func contactsByHandle(entities []rdap.Entity) map[string]entityContact {
+	contacts := map[string]entityContact{}
+	var walk func([]rdap.Entity)
+	walk = func(entities []rdap.Entity) {
+		for _, entity := range entities {
+			contact := entityContact{Handle: entity.Handle, Roles: append([]string(nil), entity.Roles...)}
+			sort.Strings(contact.Roles)
+			if entity.VCard != nil {
+				contact.Name = entity.VCard.Name()
+				for _, property := range entity.VCard.Get("email") {
+					contact.Emails = append(contact.Emails, strings.Join(property.Values(), " "))
+				}
+				for _, property := range entity.VCard.Get("tel") {
+					contact.Phones = append(contact.Phones, strings.Join(property.Values(), " "))
+				}
+				sort.Strings(contact.Emails)
+				sort.Strings(contact.Phones)
+			}
+			if contact.Handle == "" {
+				contact.Handle = "(no handle: " + contact.Name + ")"
+			}
+			contacts[contact.Handle] = contact
+			walk(entity.Entities)
+		}
+	}
+	walk(entities)
+	return contacts
+}
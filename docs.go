@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commandSummaries are the one-line descriptions used in generated docs.
+// The empty name is the default batch lookup.
+var commandSummaries = map[string]string{
+	"":                 "look up the organization name of ASNs over RDAP",
+	"canary":           "monitor RDAP objects for changes against a baseline",
+	"mock-server":      "serve RDAP fixtures locally for offline testing",
+	"capabilities":     "discover which RDAP paths and extensions a server supports",
+	"matrix":           "probe endpoints over HTTP/1.1 and HTTP/2, IPv4 and IPv6",
+	"conformance":      "check negative-path error handling of an RDAP server",
+	"healthcheck":      "check DNS and DNSSEC health of RDAP endpoint hostnames",
+	"tui":              "interactive terminal UI for lookups",
+	"history":          "list, export or clear the lookup history",
+	"diff":             "compare two RDAP objects, or one with its cached snapshot",
+	"budget":           "show per-registry query counts for the current windows",
+	"self-update":      "replace this binary with the latest verified release",
+	"docs":             "generate man pages or a markdown CLI reference",
+	"report":           "fill an abuse report template from an ASN's RDAP record",
+	"profile":          "describe a domain's delegation and hosting chain as one JSON document",
+	"pipeline":         "run a named batch pipeline defined in the config",
+	"cache":            "purge, export or import the on-disk response cache",
+	"sweep":            "report which domains of a list are registered, not found or failing",
+	"conformance-diff": "compare two RDAP servers' answers to the same queries",
+	"audit":            "verify the hash chain of an -audit-log file",
+	"plan":             "predict per-registry query counts and run time of a batch before running it",
+	"serve":            "answer lookups over an HTTP JSON API backed by the shared cache and rate limiter",
+	"selftest":         "check lookups of well-known objects at the live registries against expected answers",
+	"watch":            "re-query ASNs, prefixes and domains periodically and report changed registration facts",
+}
+
+// commandSynopses cover commands that take no flags and so never build a
+// flag set to read their usage from.
+var commandSynopses = map[string][]string{
+	"history": {"go run . history [list | export [--format json|csv] [--since 6h] [file] | clear]"},
+	"budget":  {"go run . budget"},
+	"docs":    {"go run . docs man [--dir man]", "go run . docs markdown [--output file]"},
+}
+
+// documentedFlagSets, when non-nil, receives every flag set built by
+// newFlagSet; docs uses it to read flag definitions from the commands.
+var documentedFlagSets *[]*flag.FlagSet
+
+// newFlagSet creates a command's flag set. Commands must use it, and parse
+// their flags before doing any work, so that their flags appear in the
+// generated documentation.
+func newFlagSet(name string) *flag.FlagSet {
+	flagSet := flag.NewFlagSet(name, flag.ContinueOnError)
+	if documentedFlagSets != nil {
+		flagSet.SetOutput(io.Discard)
+		*documentedFlagSets = append(*documentedFlagSets, flagSet)
+	}
+	return flagSet
+}
+
+// commandDoc is the documentation of one command.
+type commandDoc struct {
+	Name        string // "" for the default batch lookup
+	Summary     string
+	Synopsis    []string
+	Description []string
+	Flags       []*flag.Flag
+}
+
+func (d commandDoc) title() string {
+	if d.Name == "" {
+		return "rdap-test"
+	}
+	return "rdap-test-" + d.Name
+}
+
+// collectCommandDocs runs every command with -h while recording the flag
+// sets they build, so the docs always match the real flag definitions.
+func collectCommandDocs() []commandDoc {
+	names := []string{""}
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+
+	var docs []commandDoc
+	for _, name := range names {
+		doc := commandDoc{Name: name, Summary: commandSummaries[name], Synopsis: commandSynopses[name]}
+		if doc.Synopsis == nil {
+			var flagSets []*flag.FlagSet
+			documentedFlagSets = &flagSets
+			if name == "" {
+				runLookup([]string{"-h"})
+			} else {
+				subcommands[name]([]string{"-h"})
+			}
+			documentedFlagSets = nil
+			if len(flagSets) > 0 {
+				doc.Synopsis, doc.Description = usageText(flagSets[0], name)
+				flagSets[0].VisitAll(func(f *flag.Flag) { doc.Flags = append(doc.Flags, f) })
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// usageText splits a flag set's custom usage message into "usage:" lines and
+// free-text description lines, leaving out the flag defaults.
+func usageText(flagSet *flag.FlagSet, name string) (synopsis, description []string) {
+	if flagSet.Usage == nil {
+		return []string{"go run . " + name + " [flags]"}, nil
+	}
+	var usage, defaults bytes.Buffer
+	flagSet.SetOutput(&defaults)
+	flagSet.PrintDefaults()
+	flagSet.SetOutput(&usage)
+	flagSet.Usage()
+	flagSet.SetOutput(io.Discard)
+	text := strings.TrimSuffix(usage.String(), defaults.String())
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "usage: "):
+			synopsis = append(synopsis, strings.TrimPrefix(line, "usage: "))
+		case strings.HasPrefix(line, "go run ."):
+			synopsis = append(synopsis, line)
+		case line != "":
+			description = append(description, line)
+		}
+	}
+	return synopsis, description
+}
+
+// commandLine turns the "go run ." form used in usage messages into the
+// installed binary name.
+func commandLine(synopsis string) string {
+	return strings.Replace(synopsis, "go run .", "rdap-test", 1)
+}
+
+// flagDefault returns the default worth documenting, or "" for zero values.
+func flagDefault(f *flag.Flag) string {
+	switch f.DefValue {
+	case "", "false", "0", "0s", "[]":
+		return ""
+	}
+	return f.DefValue
+}
+
+func runDocs(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("usage: go run . docs man [--dir man] | docs markdown [--output file]")
+		return 2
+	}
+	flagSet := newFlagSet("docs " + args[0])
+	switch args[0] {
+	case "man":
+		directory := flagSet.String("dir", "man", "`directory` to write the man pages to")
+		if _, err := parseInterspersed(flagSet, args[1:]); err != nil {
+			return 2
+		}
+		if err := writeManPages(*directory, collectCommandDocs()); err != nil {
+			fmt.Printf("docs: %v\n", err)
+			return 1
+		}
+		return 0
+	case "markdown":
+		outputPath := flagSet.String("output", "", "write the reference to `file` instead of stdout")
+		if _, err := parseInterspersed(flagSet, args[1:]); err != nil {
+			return 2
+		}
+		var output io.Writer = os.Stdout
+		if *outputPath != "" {
+			file, err := os.Create(*outputPath)
+			if err != nil {
+				fmt.Printf("docs: %v\n", err)
+				return 1
+			}
+			defer file.Close()
+			output = file
+		}
+		writeMarkdownReference(output, collectCommandDocs())
+		return 0
+	default:
+		fmt.Println("usage: go run . docs man [--dir man] | docs markdown [--output file]")
+		return 2
+	}
+}
+
+// manDate is the date stamped on man pages, honouring SOURCE_DATE_EPOCH for
+// reproducible package builds.
+func manDate() string {
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return time.Unix(epoch, 0).UTC().Format("2006-01-02")
+	}
+	return time.Now().UTC().Format("2006-01-02")
+}
+
+// roffEscape escapes text for use in a man page line.
+func roffEscape(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\e`)
+	text = strings.ReplaceAll(text, "-", `\-`)
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = `\&` + text
+	}
+	return text
+}
+
+func writeManPages(directory string, docs []commandDoc) error {
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		var page bytes.Buffer
+		fmt.Fprintf(&page, ".TH %s 1 %q %q \"User Commands\"\n", strings.ToUpper(doc.title()), manDate(), "rdap-test "+version)
+		fmt.Fprintf(&page, ".SH NAME\n%s \\- %s\n", roffEscape(doc.title()), roffEscape(doc.Summary))
+		page.WriteString(".SH SYNOPSIS\n")
+		for index, synopsis := range doc.Synopsis {
+			if index > 0 {
+				page.WriteString(".br\n")
+			}
+			fmt.Fprintf(&page, "%s\n", roffEscape(commandLine(synopsis)))
+		}
+		if len(doc.Description) > 0 {
+			page.WriteString(".SH DESCRIPTION\n")
+			for _, line := range doc.Description {
+				fmt.Fprintf(&page, "%s\n", roffEscape(line))
+			}
+		}
+		if len(doc.Flags) > 0 {
+			page.WriteString(".SH OPTIONS\n")
+			for _, f := range doc.Flags {
+				argument, usage := flag.UnquoteUsage(f)
+				fmt.Fprintf(&page, ".TP\n.B \\-%s", roffEscape(f.Name))
+				if argument != "" {
+					fmt.Fprintf(&page, " \\fI%s\\fR", roffEscape(argument))
+				}
+				fmt.Fprintf(&page, "\n%s", roffEscape(usage))
+				if defaultValue := flagDefault(f); defaultValue != "" {
+					fmt.Fprintf(&page, " (default: %s)", roffEscape(defaultValue))
+				}
+				page.WriteString("\n")
+			}
+		}
+		if doc.Name == "" {
+			page.WriteString(".SH SEE ALSO\n")
+			var related []string
+			for _, other := range docs[1:] {
+				related = append(related, fmt.Sprintf(".BR %s (1)", roffEscape(other.title())))
+			}
+			page.WriteString(strings.Join(related, ",\n") + "\n")
+		} else {
+			page.WriteString(".SH SEE ALSO\n.BR rdap\\-test (1)\n")
+		}
+		if err := os.WriteFile(filepath.Join(directory, doc.title()+".1"), page.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMarkdownReference(output io.Writer, docs []commandDoc) {
+	fmt.Fprintln(output, "# rdap-test command reference")
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, "Generated by `go run . docs markdown` from the command definitions.")
+	for _, doc := range docs {
+		heading := "rdap-test"
+		if doc.Name != "" {
+			heading += " " + doc.Name
+		}
+		fmt.Fprintf(output, "\n## %s\n\n%s.\n\n```\n", heading, strings.ToUpper(doc.Summary[:1])+doc.Summary[1:])
+		for _, synopsis := range doc.Synopsis {
+			fmt.Fprintln(output, commandLine(synopsis))
+		}
+		fmt.Fprintln(output, "```")
+		if len(doc.Description) > 0 {
+			fmt.Fprintf(output, "\n%s\n", strings.Join(doc.Description, " "))
+		}
+		if len(doc.Flags) == 0 {
+			continue
+		}
+		fmt.Fprintln(output, "\n| Flag | Description | Default |")
+		fmt.Fprintln(output, "| --- | --- | --- |")
+		for _, f := range doc.Flags {
+			argument, usage := flag.UnquoteUsage(f)
+			name := "-" + f.Name
+			if argument != "" {
+				name += " " + argument
+			}
+			defaultValue := flagDefault(f)
+			if defaultValue != "" {
+				defaultValue = "`" + defaultValue + "`"
+			}
+			fmt.Fprintf(output, "| `%s` | %s | %s |\n", name, strings.ReplaceAll(usage, "|", `\|`), defaultValue)
+		}
+	}
+}
+
+func init() {
+	// Registered here rather than in the subcommands literal, which runDocs
+	// itself reads.
+	subcommands["docs"] = runDocs
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+	rdap "github.com/openrdap/rdap"
+)
+
+// domainDetails are the registration facts of a domain lookup (-domain).
+type domainDetails struct {
+	Zone        string   `json:"zone,omitempty"` // the reverse zone answering (-reverse)
+	Nameservers []string `json:"nameservers,omitempty"`
+	Registrar   string   `json:"registrar,omitempty"`
+	Registrant  string   `json:"registrant,omitempty"` // registrant organization; often redacted
+	Created     string   `json:"created,omitempty"`
+	Expires     string   `json:"expires,omitempty"`
+	Status      []string `json:"status,omitempty"`
+}
+
+// domainDetailsOf extracts the registration facts from a domain record.
+func domainDetailsOf(domain *rdap.Domain) *domainDetails {
+	details := &domainDetails{
+		Created: eventDate(domain.Events, "registration"),
+		Expires: eventDate(domain.Events, "expiration"),
+		Status:  domain.Status,
+	}
+	for _, nameserver := range domain.Nameservers {
+		if name := strings.TrimSuffix(strings.ToLower(nameserver.LDHName), "."); name != "" {
+			details.Nameservers = append(details.Nameservers, name)
+		}
+	}
+	for _, entity := range domain.Entities {
+		if entity.VCard == nil {
+			continue
+		}
+		if slices.Contains(entity.Roles, "registrar") && details.Registrar == "" {
+			details.Registrar = entity.VCard.Name()
+		}
+		if slices.Contains(entity.Roles, "registrant") && details.Registrant == "" {
+			details.Registrant = registrantOrganization(entity.VCard)
+		}
+	}
+	return details
+}
+
+// registrantOrganization prefers the vCard "org" property, then the name of
+// an organization vCard, then any formatted name.
+func registrantOrganization(vcard *rdap.VCard) string {
+	if org := vcard.GetFirst("org"); org != nil {
+		for _, value := range org.Values() {
+			if value = strings.TrimSpace(value); value != "" {
+				return value
+			}
+		}
+	}
+	if name := rdaplookup.OrgNameFromVCard(vcard); name != "" {
+		return name
+	}
+	return strings.TrimSpace(vcard.Name())
+}
+
+// parseDomainName validates a -domain target and returns it in lowercase
+// without a trailing dot.
+func parseDomainName(text string) (string, error) {
+	name := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(text)), ".")
+	if !strings.Contains(name, ".") || len(name) > 253 {
+		return "", fmt.Errorf("%q is not a domain name", text)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") ||
+			strings.ContainsAny(label, " \t/:@_") {
+			return "", fmt.Errorf("%q is not a domain name", text)
+		}
+	}
+	return name, nil
+}
+
+// rdapDomainLookup queries the registry of a domain. The result's Name is
+// the registrant organization or, when that is redacted, the registrar.
+func rdapDomainLookup(ctx context.Context, client *rdap.Client, name string) lookupResult {
+	return rdapDomainLookupAt(ctx, client, name, nil)
+}
+
+// rdapDomainLookupAt is rdapDomainLookup asking server, when not nil,
+// instead of the registry the DNS bootstrap registry names.
+func rdapDomainLookupAt(ctx context.Context, client *rdap.Client, name string, server *url.URL) lookupResult {
+	ctx, chain := withRedirectChain(ctx)
+	ctx, retried := withAttemptLog(ctx)
+	request := rdap.NewRequest(rdap.DomainRequest, name).WithContext(ctx)
+	if server != nil {
+		request = request.WithServer(server)
+	}
+	response, err := doRDAP(client, request)
+	var result lookupResult
+	if lastExchange := lastHTTPExchange(response); lastExchange != nil {
+		result.Fetch = &autnumFetch{URL: lastExchange.URL, RawBody: lastExchange.Body, Redirects: chain, Attempts: retried.merge(rdaplookup.AttemptsFrom(name, response)),
+			ValidUntil: validUntil(lastExchange, time.Now())}
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	switch object := response.Object.(type) {
+	case *rdap.Domain:
+		result.Domain = domainDetailsOf(object)
+		result.Name = result.Domain.Registrant
+		if result.Name == "" {
+			result.Name = result.Domain.Registrar
+		}
+	case *rdap.Error:
+		result.Err = fmt.Errorf("server returned error code %d, title=%q, description=%q",
+			object.ErrorCode, object.Title, strings.Join(object.Description, " "))
+	default:
+		result.Err = fmt.Errorf("unexpected RDAP response type %T for %s", response.Object, name)
+	}
+	return result
+}
+
+// line renders the registration facts after a domain's name.
+func (d *domainDetails) line() string {
+	var parts []string
+	if d.Zone != "" {
+		parts = append(parts, fmt.Sprintf(localize("zone %s"), d.Zone))
+	}
+	if d.Registrar != "" {
+		parts = append(parts, fmt.Sprintf(localize("registrar %s"), d.Registrar))
+	}
+	if d.Created != "" {
+		parts = append(parts, fmt.Sprintf(localize("created %s"), d.Created))
+	}
+	if d.Expires != "" {
+		parts = append(parts, fmt.Sprintf(localize("expires %s"), d.Expires))
+	}
+	if len(d.Status) > 0 {
+		parts = append(parts, fmt.Sprintf(localize("status %s"), strings.Join(d.Status, ", ")))
+	}
+	if len(d.Nameservers) > 0 {
+		parts = append(parts, fmt.Sprintf(localize("nameservers %s"), strings.Join(d.Nameservers, ", ")))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, "; ") + ")"
+}
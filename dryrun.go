@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+	rdap "github.com/openrdap/rdap"
+	"github.com/openrdap/rdap/bootstrap"
+)
+
+// printDryRun resolves the bootstrap decision for asn and prints every RDAP
+// URL rdapASNLookup would try, in order, without querying them. Only the
+// bootstrap registry itself is downloaded.
+func printDryRun(vantage vantageClient, asn int64, label string) error {
+	if asn >= 64512 && asn <= 65535 {
+		fmt.Printf("%s: private ASN, no query would be made\n", label)
+		return nil
+	}
+	route := "direct"
+	if vantage.Proxy != nil {
+		route = "via proxy " + vantage.Proxy.Redacted()
+	}
+	if override := customEndpoints.forASN(asn); override != nil {
+		fmt.Printf("%s: custom endpoint %s (bootstrap bypassed), %s\n", label, override.Selector, route)
+		for index, queryString := range rdaplookup.QueryFormats(asn) {
+			fmt.Printf("  GET %s%s\n", routeRequest(rdap.NewRequest(rdap.AutnumRequest, queryString)).URL(), dryRunFallbackNote(index))
+		}
+		return nil
+	}
+	question := &bootstrap.Question{RegistryType: bootstrap.ASN, Query: strconv.FormatInt(asn, 10)}
+	answer, err := vantage.Client.Bootstrap.Lookup(question)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: bootstrap %s entry %q, %s\n", label, vantage.Client.Bootstrap.BaseURL, answer.Entry, route)
+	if len(answer.URLs) == 0 {
+		fmt.Println("  (no RDAP servers; the lookup would fail)")
+		return nil
+	}
+	for index, queryString := range rdaplookup.QueryFormats(asn) {
+		for _, serverURL := range answer.URLs {
+			request := rdap.NewRequest(rdap.AutnumRequest, queryString).WithServer(serverURL)
+			fmt.Printf("  GET %s%s\n", request.URL(), dryRunFallbackNote(index))
+		}
+	}
+	return nil
+}
+
+// dryRunFallbackNote marks the query format only sent after a 404 for the
+// first.
+func dryRunFallbackNote(index int) string {
+	if index == 0 {
+		return ""
+	}
+	return " (only after a 404)"
+}
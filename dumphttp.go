@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// dumpHTTPFlag implements -dump-http: bare, it dumps to stderr; with a value
+// (-dump-http=wire.log) it appends to that file.
+type dumpHTTPFlag struct {
+	target *io.Writer
+	path   string
+}
+
+func (f *dumpHTTPFlag) IsBoolFlag() bool { return true }
+
+func (f *dumpHTTPFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.path
+}
+
+func (f *dumpHTTPFlag) Set(value string) error {
+	switch value {
+	case "false":
+		*f.target = nil
+	case "true", "-":
+		f.path = "-"
+		*f.target = os.Stderr
+	default:
+		file, err := os.OpenFile(value, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		flagFiles = append(flagFiles, file)
+		f.path = value
+		*f.target = file
+	}
+	return nil
+}
+
+// defaultDumpBodyLimit caps the response bodies dumped without a
+// -max-response-size.
+const defaultDumpBodyLimit = 1 << 20
+
+// dumpTransport writes the complete request and response, headers and
+// bodies, of every HTTP exchange to output, as sent: it runs inside the
+// transports adding credentials and session cookies, whose secrets
+// redactDump replaces. It also runs inside limitTransport, so it reads no
+// more of a response body than maxBody: a longer body is dumped truncated
+// and handed on whole, for limitTransport to reject.
+type dumpTransport struct {
+	base    http.RoundTripper
+	output  io.Writer
+	maxBody byteSize // 0 is defaultDumpBodyLimit
+}
+
+func (t *dumpTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	var dump bytes.Buffer
+	fmt.Fprintf(&dump, "===== %s %s\n", time.Now().Format(time.RFC3339Nano), request.URL)
+	if requestDump, err := httputil.DumpRequestOut(request, true); err == nil {
+		dump.Write(redactDump(requestDump))
+	} else {
+		fmt.Fprintf(&dump, "(request dump failed: %v)\n", err)
+	}
+	dump.WriteString("\n-----\n")
+	response, err := t.base.RoundTrip(request)
+	if err != nil {
+		fmt.Fprintf(&dump, "(no response: %v)\n", err)
+	} else if responseDump, dumpErr := t.dumpResponse(response); dumpErr == nil {
+		dump.Write(redactDump(responseDump))
+	} else {
+		fmt.Fprintf(&dump, "(response dump failed: %v)\n", dumpErr)
+	}
+	dump.WriteString("\n\n")
+	// One write per exchange keeps concurrent dumps from interleaving.
+	t.output.Write(dump.Bytes())
+	return response, err
+}
+
+// dumpResponse dumps the head of response and at most maxBody bytes of its
+// body. The bytes read are put back in front of the rest of the body.
+func (t *dumpTransport) dumpResponse(response *http.Response) ([]byte, error) {
+	dump, err := httputil.DumpResponse(response, false)
+	if err != nil {
+		return nil, err
+	}
+	limit := t.maxBody
+	if limit <= 0 {
+		limit = defaultDumpBodyLimit
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, int64(limit)+1))
+	response.Body = &replayedBody{Reader: io.MultiReader(bytes.NewReader(body), response.Body), Closer: response.Body}
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) <= int64(limit) {
+		return append(dump, body...), nil
+	}
+	dump = append(dump, body[:limit]...)
+	return append(dump, fmt.Sprintf("\n(body truncated after %s)", limit.String())...), nil
+}
+
+// replayedBody is a response body of which a dumped prefix was read.
+type replayedBody struct {
+	io.Reader
+	io.Closer
+}
+
+var (
+	// credentialHeaderPattern matches the value of an Authorization header
+	// after its scheme, e.g. the token of "Bearer token".
+	credentialHeaderPattern = regexp.MustCompile(`(?im)^((?:Proxy-)?Authorization: *(?:\S+ +)?)\S[^\r\n]*`)
+	// cookieHeaderPattern matches the Cookie and Set-Cookie headers.
+	cookieHeaderPattern = regexp.MustCompile(`(?im)^(Cookie|Set-Cookie):[^\r\n]*`)
+	// cookieValuePattern matches the value of one name=value pair of a
+	// cookie header.
+	cookieValuePattern = regexp.MustCompile(`(^|;)( *[^=;]+=)[^;]*`)
+	// secretBodyPattern matches the client secret of an OAuth2 token
+	// request and the tokens of its answer.
+	secretBodyPattern = regexp.MustCompile(`(client_secret=)[^&\s]*|("(?:access|refresh)_token" *: *")[^"]*`)
+)
+
+// redactDump replaces the credentials, cookie values and OAuth2 secrets
+// of a dumped request or response with [redacted].
+func redactDump(dump []byte) []byte {
+	head, body, _ := bytes.Cut(dump, []byte("\r\n\r\n"))
+	head = credentialHeaderPattern.ReplaceAll(head, []byte("${1}[redacted]"))
+	head = cookieHeaderPattern.ReplaceAllFunc(head, func(header []byte) []byte {
+		name, value, _ := bytes.Cut(header, []byte(":"))
+		var attributes []byte
+		if bytes.EqualFold(name, []byte("Set-Cookie")) {
+			// Only the first pair is the cookie; the rest are attributes.
+			if index := bytes.IndexByte(value, ';'); index >= 0 {
+				value, attributes = value[:index], value[index:]
+			}
+		}
+		var redacted bytes.Buffer
+		redacted.Write(name)
+		redacted.WriteByte(':')
+		redacted.Write(cookieValuePattern.ReplaceAll(value, []byte("$1$2[redacted]")))
+		redacted.Write(attributes)
+		return redacted.Bytes()
+	})
+	if body == nil {
+		return head
+	}
+	body = secretBodyPattern.ReplaceAll(body, []byte("${1}${2}[redacted]"))
+	return slices.Concat(head, []byte("\r\n\r\n"), body)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+
+	rdap "github.com/openrdap/rdap"
+)
+
+// endpointOverride routes the targets matched by one selector to a custom
+// RDAP base URL, bypassing the IANA bootstrap registry. Exactly one of
+// ASNs, Prefix, TLD and All is set.
+type endpointOverride struct {
+	Selector string // as written in the file, for dry runs and explanations
+	ASNs     *asnRange
+	Prefix   netip.Prefix
+	TLD      string // lower case, without the leading dot
+	// All ("*") matches every target no narrower selector matches.
+	All     bool
+	BaseURL *url.URL
+}
+
+// endpointOverrides is the parsed endpoints file, in file order.
+type endpointOverrides []endpointOverride
+
+// customEndpoints holds the endpoint overrides for this run; it is loaded by
+// clientOptions.validate and consulted before every bootstrapped query.
+var customEndpoints endpointOverrides
+
+// defaultEndpointsPath returns $XDG_CONFIG_HOME/rdap-tester/endpoints,
+// defaulting to ~/.config/rdap-tester/endpoints.
+func defaultEndpointsPath() (string, error) {
+	return configFilePath("endpoints")
+}
+
+// loadEndpoints reads an endpoints file with one "selector base-URL" pair per
+// line. A selector is an ASN or ASN range ("AS64496-AS64511"), an IP prefix
+// ("192.0.2.0/24", "2001:db8::/32"), a TLD (".test") or "*" for every
+// other target. Blank lines and
+// lines starting with # are ignored. A missing file is not an error when
+// optional is set.
+func loadEndpoints(path string, optional bool) (endpointOverrides, error) {
+	file, err := os.Open(path)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var overrides endpointOverrides
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"selector base-URL\", got %q", path, lineNumber, line)
+		}
+		override, err := parseEndpointOverride(fields[0], fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		overrides = append(overrides, override)
+	}
+	return overrides, scanner.Err()
+}
+
+func parseEndpointOverride(selector, baseURL string) (endpointOverride, error) {
+	override := endpointOverride{Selector: selector}
+	parsed, err := url.Parse(baseURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return override, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+	override.BaseURL = parsed
+	switch {
+	case selector == "*":
+		override.All = true
+	case strings.HasPrefix(selector, "."):
+		override.TLD = strings.ToLower(strings.TrimSuffix(selector[1:], "."))
+		if override.TLD == "" || strings.Contains(override.TLD, ".") {
+			return override, fmt.Errorf("invalid TLD selector %q", selector)
+		}
+	case strings.Contains(selector, "/"):
+		prefix, err := netip.ParsePrefix(selector)
+		if err != nil {
+			return override, fmt.Errorf("invalid prefix selector %q", selector)
+		}
+		override.Prefix = prefix.Masked()
+	default:
+		// Accept "AS64496-AS64511" as well as "64496-64511".
+		asns, err := parseASNRange(strings.Replace(strings.ToUpper(selector), "-AS", "-", 1))
+		if err != nil {
+			return override, fmt.Errorf("invalid selector %q (want an ASN range, IP prefix or .tld)", selector)
+		}
+		override.ASNs = &asns
+	}
+	return override, nil
+}
+
+// forASN returns the override of the narrowest ASN range containing asn.
+func (o endpointOverrides) forASN(asn int64) *endpointOverride {
+	var best *endpointOverride
+	for index := range o {
+		candidate := &o[index]
+		if candidate.ASNs == nil || asn < candidate.ASNs.Low || asn > candidate.ASNs.High {
+			continue
+		}
+		if best == nil || candidate.ASNs.High-candidate.ASNs.Low < best.ASNs.High-best.ASNs.Low {
+			best = candidate
+		}
+	}
+	if best == nil {
+		return o.catchAll()
+	}
+	return best
+}
+
+// forPrefix returns the override of the longest prefix containing prefix.
+func (o endpointOverrides) forPrefix(prefix netip.Prefix) *endpointOverride {
+	var best *endpointOverride
+	for index := range o {
+		candidate := &o[index]
+		if !candidate.Prefix.IsValid() || candidate.Prefix.Addr().Is4() != prefix.Addr().Is4() ||
+			candidate.Prefix.Bits() > prefix.Bits() || !candidate.Prefix.Contains(prefix.Addr()) {
+			continue
+		}
+		if best == nil || candidate.Prefix.Bits() > best.Prefix.Bits() {
+			best = candidate
+		}
+	}
+	if best == nil {
+		return o.catchAll()
+	}
+	return best
+}
+
+// forDomain returns the override for the TLD of domain.
+func (o endpointOverrides) forDomain(domain string) *endpointOverride {
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(domain, ".")), ".")
+	tld := labels[len(labels)-1]
+	for index := range o {
+		if o[index].TLD == tld {
+			return &o[index]
+		}
+	}
+	return o.catchAll()
+}
+
+// catchAll returns the "*" override, if any.
+func (o endpointOverrides) catchAll() *endpointOverride {
+	for index := range o {
+		if o[index].All {
+			return &o[index]
+		}
+	}
+	return nil
+}
+
+// forRequest returns the override matching an RDAP request, or nil when the
+// request should go through the bootstrap registry.
+func (o endpointOverrides) forRequest(request *rdap.Request) *endpointOverride {
+	if len(o) == 0 {
+		return nil
+	}
+	switch request.Type {
+	case rdap.AutnumRequest:
+		if asn, err := parseASN(request.Query); err == nil {
+			return o.forASN(asn)
+		}
+	case rdap.IPRequest:
+		if prefix, err := netip.ParsePrefix(request.Query); err == nil {
+			return o.forPrefix(prefix)
+		}
+		if addr, err := netip.ParseAddr(request.Query); err == nil {
+			return o.forPrefix(netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	case rdap.DomainRequest, rdap.NameserverRequest:
+		return o.forDomain(request.Query)
+	case rdap.EntityRequest:
+		return o.catchAll()
+	}
+	return nil
+}
+
+// routeRequest points request at its custom endpoint, if one matches.
+// Requests already given a server (-entity-server, reverse zones) keep it.
+func routeRequest(request *rdap.Request) *rdap.Request {
+	if request.Server != nil {
+		return request
+	}
+	if override := customEndpoints.forRequest(request); override != nil {
+		// Request.URL clears the server URL's query in place, so hand it a copy.
+		server := *override.BaseURL
+		return request.WithServer(&server)
+	}
+	return request
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+	rdap "github.com/openrdap/rdap"
+)
+
+// entityDetails are the vCard details of an entity lookup (entity:handle).
+type entityDetails struct {
+	Handle       string   `json:"handle,omitempty"`
+	Kind         string   `json:"kind,omitempty"` // individual, org, group or location
+	Roles        []string `json:"roles,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	Organization string   `json:"organization,omitempty"`
+	Email        string   `json:"email,omitempty"`
+	Phone        string   `json:"phone,omitempty"`
+	Address      string   `json:"address,omitempty"`
+}
+
+// entityDetailsOf extracts the details of an entity record.
+func entityDetailsOf(entity *rdap.Entity) *entityDetails {
+	details := &entityDetails{Handle: entity.Handle, Roles: entity.Roles}
+	if entity.VCard == nil {
+		return details
+	}
+	vcard := contact{}.withVCard(entity.VCard)
+	details.Name, details.Organization = vcard.Name, vcard.Organization
+	details.Email, details.Phone, details.Address = vcard.Email, vcard.Phone, vcard.Address
+	if kind := entity.VCard.GetFirst("kind"); kind != nil {
+		details.Kind = strings.TrimSpace(strings.Join(kind.Values(), " "))
+	}
+	return details
+}
+
+// line renders the entity details after its name.
+func (e *entityDetails) line() string {
+	var parts []string
+	if e.Handle != "" {
+		parts = append(parts, fmt.Sprintf(localize("handle %s"), e.Handle))
+	}
+	if e.Kind != "" {
+		parts = append(parts, fmt.Sprintf(localize("kind %s"), e.Kind))
+	}
+	if e.Organization != "" && e.Name != "" {
+		parts = append(parts, e.Name)
+	}
+	if e.Email != "" {
+		parts = append(parts, "<"+e.Email+">")
+	}
+	if e.Phone != "" {
+		parts = append(parts, e.Phone)
+	}
+	if e.Address != "" {
+		parts = append(parts, e.Address)
+	}
+	if len(e.Roles) > 0 {
+		parts = append(parts, fmt.Sprintf(localize("roles %s"), strings.Join(e.Roles, ", ")))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, "; ") + ")"
+}
+
+// entityTarget returns the handle of an "entity:handle" target, or ok
+// false for other targets.
+func entityTarget(text string) (handle string, ok bool) {
+	return prefixedTarget(text, "entity:")
+}
+
+// rdapEntityLookup queries the registry of an entity handle: server when
+// not nil, and otherwise the registry the object tag bootstrap registry
+// names for the handle's suffix (e.g. "-ARIN"). The result's Name is the
+// entity's organization or formatted name.
+func rdapEntityLookup(ctx context.Context, client *rdap.Client, handle string, server *url.URL) lookupResult {
+	ctx, chain := withRedirectChain(ctx)
+	ctx, retried := withAttemptLog(ctx)
+	request := rdap.NewRequest(rdap.EntityRequest, handle).WithContext(ctx)
+	if server != nil {
+		// Request.URL clears the server URL's query in place, so hand it a copy.
+		copied := *server
+		request = request.WithServer(&copied)
+	}
+	response, err := doRDAP(client, request)
+	var result lookupResult
+	if lastExchange := lastHTTPExchange(response); lastExchange != nil {
+		result.Fetch = &autnumFetch{URL: lastExchange.URL, RawBody: lastExchange.Body, Redirects: chain, Attempts: retried.merge(rdaplookup.AttemptsFrom(handle, response)),
+			ValidUntil: validUntil(lastExchange, time.Now())}
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	switch object := response.Object.(type) {
+	case *rdap.Entity:
+		result.Entity = entityDetailsOf(object)
+		if object.VCard != nil {
+			result.Name = registrantOrganization(object.VCard)
+		}
+	case *rdap.Error:
+		result.Err = fmt.Errorf("server returned error code %d, title=%q, description=%q",
+			object.ErrorCode, object.Title, strings.Join(object.Description, " "))
+	default:
+		result.Err = fmt.Errorf("unexpected RDAP response type %T for %s", response.Object, handle)
+	}
+	return result
+}
+
+// openrdap requests the object tag bootstrap registry under the name of the
+// draft it implemented; IANA publishes it as object-tags.json (RFC 8521).
+const (
+	draftServiceProviderFile = "serviceprovider-draft-03.json"
+	objectTagsFile           = "object-tags.json"
+)
+
+// objectTagsTransport serves openrdap's request for the draft service
+// provider registry from IANA's object-tags.json, dropping the contact
+// email member of each service so it has the two members openrdap expects.
+type objectTagsTransport struct {
+	base http.RoundTripper
+}
+
+func (t *objectTagsTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(request.URL.Path, "/"+draftServiceProviderFile) {
+		return t.base.RoundTrip(request)
+	}
+	request = request.Clone(request.Context())
+	request.URL.Path = strings.TrimSuffix(request.URL.Path, draftServiceProviderFile) + objectTagsFile
+	response, err := t.base.RoundTrip(request)
+	if err != nil || response.StatusCode != http.StatusOK {
+		return response, err
+	}
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var registry map[string]any
+	if err := json.Unmarshal(body, &registry); err != nil {
+		return nil, fmt.Errorf("%s: %v", objectTagsFile, err)
+	}
+	if services, ok := registry["services"].([]any); ok {
+		for index, service := range services {
+			if members, ok := service.([]any); ok && len(members) == 3 {
+				services[index] = members[1:]
+			}
+		}
+	}
+	if body, err = json.Marshal(registry); err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	response.ContentLength = int64(len(body))
+	response.Header.Del("Content-Length")
+	return response, nil
+}
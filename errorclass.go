@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+	rdap "github.com/openrdap/rdap"
+)
+
+// Error classes of failed lookups, shown in the console and in the
+// error_class field of -json and -csv output; each has its own exit status
+// (see errorExitCodes).
+const (
+	errorNotFound      = "not-found"         // the registry answered 404
+	errorRateLimited   = "rate-limited"      // answered 429 after all backoff retries
+	errorTimeout       = "timeout"           // -timeout or a network timeout
+	errorBootstrap     = "bootstrap-failure" // no registry: bootstrap download failed or has no entry
+	errorTLS           = "tls-error"         // handshake or certificate verification failed
+	errorServer        = "server-error"      // a 5xx status, or an RDAP error object
+	errorNetwork       = "network-error"     // connection refused, DNS failure and the like
+	errorInvalidTarget = "invalid-target"    // the target never became a query
+	errorOther         = "error"
+)
+
+// errorDetails classify a failed lookup.
+type errorDetails struct {
+	Class string
+	// Status is the HTTP status of the last response received; 0 when
+	// none arrived.
+	Status int
+	// ErrorCode is the errorCode of the RDAP error object the server
+	// answered with; 0 when it sent none.
+	ErrorCode int
+}
+
+// errorDetails classifies the error of r; the zero value for successes.
+func (r lookupResult) errorDetails() errorDetails {
+	if r.Err == nil {
+		return errorDetails{}
+	}
+	var details errorDetails
+	attempts := r.attempts()
+	for index := len(attempts) - 1; index >= 0; index-- {
+		if attempts[index].Status != 0 {
+			details.Status = attempts[index].Status
+			break
+		}
+	}
+	if r.Fetch != nil {
+		var body struct {
+			ErrorCode int `json:"errorCode"`
+		}
+		if json.Unmarshal(r.Fetch.RawBody, &body) == nil {
+			details.ErrorCode = body.ErrorCode
+		}
+	}
+	details.Class = classifyError(r.Err, details)
+	return details
+}
+
+// classifyError returns the error class of err, given the status and
+// errorCode of the last response.
+func classifyError(err error, details errorDetails) string {
+	var (
+		bootstrap   *rdaplookup.BootstrapError
+		invalid     *invalidTargetError
+		clientError *rdap.ClientError
+		netError    net.Error
+		opError     *net.OpError
+		dnsError    *net.DNSError
+	)
+	switch {
+	case errors.As(err, &invalid):
+		return errorInvalidTarget
+	case errors.As(err, &bootstrap):
+		return errorBootstrap
+	case resultClass(err) == classNotFound:
+		return errorNotFound
+	case details.Status == http.StatusTooManyRequests:
+		return errorRateLimited
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netError) && netError.Timeout():
+		return errorTimeout
+	case isTLSError(err):
+		return errorTLS
+	case details.Status >= 500, details.ErrorCode >= 500,
+		errors.As(err, &clientError) && clientError.Type == rdap.RDAPServerError,
+		details.Status >= 200 && details.Status <= 299 && details.ErrorCode != 0:
+		return errorServer
+	case errors.As(err, &opError), errors.As(err, &dnsError):
+		return errorNetwork
+	}
+	return errorOther
+}
+
+// isTLSError reports whether err is a failed TLS handshake or an
+// unverifiable certificate.
+func isTLSError(err error) bool {
+	var (
+		recordHeader tls.RecordHeaderError
+		alert        tls.AlertError
+		verification *tls.CertificateVerificationError
+		authority    x509.UnknownAuthorityError
+		hostname     x509.HostnameError
+		invalid      x509.CertificateInvalidError
+	)
+	return errors.As(err, &recordHeader) || errors.As(err, &alert) || errors.As(err, &verification) ||
+		errors.As(err, &authority) || errors.As(err, &hostname) || errors.As(err, &invalid)
+}
+
+// String renders the class with the status and errorCode it came with,
+// e.g. "server-error, HTTP 503, errorCode 503".
+func (d errorDetails) String() string {
+	parts := []string{d.Class}
+	if d.Status != 0 {
+		parts = append(parts, fmt.Sprintf("HTTP %d", d.Status))
+	}
+	if d.ErrorCode != 0 {
+		parts = append(parts, fmt.Sprintf("errorCode %d", d.ErrorCode))
+	}
+	return strings.Join(parts, ", ")
+}
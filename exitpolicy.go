@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	rdap "github.com/openrdap/rdap"
+)
+
+// Result classes used by the -fail-on exit-code policy.
+const (
+	classOK       = "ok"
+	classNotFound = "notfound"
+	classError    = "error"
+)
+
+// resultClass classifies a lookup error: nil is ok, an RDAP 404 (the object
+// does not exist) is notfound, anything else is an error.
+func resultClass(err error) string {
+	if err == nil {
+		return classOK
+	}
+	var clientError *rdap.ClientError
+	if errors.As(err, &clientError) && clientError.Type == rdap.ObjectDoesNotExist {
+		return classNotFound
+	}
+	return classError
+}
+
+// exitPolicy is the -fail-on setting: which result classes make the process
+// exit with a failure status (see failureExitCode).
+type exitPolicy string
+
+func (p *exitPolicy) String() string {
+	if p == nil {
+		return ""
+	}
+	return string(*p)
+}
+
+func (p *exitPolicy) Set(value string) error {
+	switch value {
+	case "error", "notfound", "any", "never":
+		*p = exitPolicy(value)
+		return nil
+	}
+	return fmt.Errorf("must be one of %s", strings.Join([]string{"error", "notfound", "any", "never"}, ", "))
+}
+
+// fails reports whether a result of class should fail the run.
+func (p exitPolicy) fails(class string) bool {
+	switch p {
+	case "any":
+		return class != classOK
+	case "error", "notfound":
+		return class == string(p)
+	default:
+		return false
+	}
+}
+
+// errorExitCodes are the exit statuses of a run failing -fail-on only with
+// results of one error class. 2 is taken by usage errors; a run failing
+// with several classes, or otherwise (e.g. a sink error), exits with 1.
+var errorExitCodes = map[string]int{
+	errorNotFound:      3,
+	errorRateLimited:   4,
+	errorTimeout:       5,
+	errorBootstrap:     6,
+	errorTLS:           7,
+	errorServer:        8,
+	errorNetwork:       9,
+	errorInvalidTarget: 10,
+}
+
+// failures returns the error classes of the results failing the run.
+func (p exitPolicy) failures(results ...lookupResult) []string {
+	var classes []string
+	for _, result := range results {
+		if p.fails(resultClass(result.Err)) {
+			classes = append(classes, result.errorDetails().Class)
+		}
+	}
+	return classes
+}
+
+// failureExitCode returns the exit status of a run that failed with results
+// of the given error classes.
+func failureExitCode(classes map[string]bool) int {
+	if len(classes) != 1 {
+		return 1
+	}
+	for class := range classes {
+		if code, ok := errorExitCodes[class]; ok {
+			return code
+		}
+	}
+	return 1
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+	"github.com/openrdap/rdap/bootstrap"
+)
+
+// explainBootstrap prints how the bootstrap registry routes asn: where the
+// registry came from, which entry matched, and which service URL is tried
+// first and why.
+func explainBootstrap(vantage vantageClient, asn int64, label string) error {
+	if override := customEndpoints.forASN(asn); override != nil {
+		fmt.Printf("%s: bootstrap explanation\n", label)
+		fmt.Printf("  endpoint:  %s matches custom endpoint %s; the bootstrap registry is not consulted\n", override.Selector, override.BaseURL)
+		fmt.Printf("  queries:   %s, the second only after a 404\n", strings.Join(rdaplookup.QueryFormats(asn), " then "))
+		return nil
+	}
+	bootstrapClient := vantage.Client.Bootstrap
+	// ASN() never downloads; non-nil means the registry is already in memory.
+	alreadyLoaded := bootstrapClient.ASN() != nil
+	question := &bootstrap.Question{RegistryType: bootstrap.ASN, Query: strconv.FormatInt(asn, 10)}
+	answer, err := bootstrapClient.Lookup(question)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: bootstrap explanation\n", label)
+	source := "downloaded from " + bootstrapClient.BaseURL.String() + bootstrap.ASN.Filename()
+	if alreadyLoaded {
+		source = "cached in memory (downloaded earlier in this run)"
+	}
+	fmt.Printf("  registry:  %s, %s\n", bootstrap.ASN.Filename(), source)
+	if answer.Entry == "" {
+		fmt.Printf("  entry:     no range covers %s; the lookup will fail\n", answer.Query)
+		return nil
+	}
+	fmt.Printf("  entry:     %s matches %s\n", answer.Entry, answer.Query)
+	for index, serviceURL := range answer.URLs {
+		role := "fallback, tried only if the previous URLs fail"
+		if index == 0 {
+			role = "chosen: first in the entry's service list"
+		}
+		fmt.Printf("  service %d: %s (%s)\n", index+1, serviceURL, role)
+	}
+	// The client keeps the registry's order rather than preferring HTTPS
+	// (RFC 7484 section 3 recommends HTTPS), so call out when that matters.
+	firstHTTPS := -1
+	for index, serviceURL := range answer.URLs {
+		if serviceURL.Scheme == "https" {
+			firstHTTPS = index
+			break
+		}
+	}
+	switch {
+	case firstHTTPS == -1:
+		fmt.Println("  note:      no HTTPS service URL is listed; queries go over plain HTTP")
+	case firstHTTPS > 0:
+		fmt.Printf("  note:      plain HTTP is listed before HTTPS; the client keeps registry order, so %s is tried first\n", answer.URLs[0])
+	default:
+		fmt.Println("  note:      the chosen URL uses HTTPS")
+	}
+	fmt.Printf("  queries:   %s, the second only after a 404\n", strings.Join(rdaplookup.QueryFormats(asn), " then "))
+	return nil
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// resultFilter is a compiled -filter expression. The language is small on
+// purpose: fields of the result, string and number literals, comparisons
+// (==, !=, <, <=, >, >=, =~ and !~ for regular expressions), !, && and ||,
+// and parentheses. String equality ignores case, so registry == "ripe"
+// matches "RIPE".
+type resultFilter struct {
+	source   string
+	evaluate func(lookupResult) any
+}
+
+// filterFields are the result fields an expression can refer to.
+var filterFields = map[string]func(lookupResult) any{
+	"asn": func(r lookupResult) any {
+		asn, _ := parseASN(r.Target)
+		return float64(asn)
+	},
+	"target":      func(r lookupResult) any { return r.Target },
+	"name":        func(r lookupResult) any { return r.Name },
+	"country":     func(r lookupResult) any { return r.country() },
+	"registry":    func(r lookupResult) any { return r.registry() },
+	"vantage":     func(r lookupResult) any { return r.Vantage },
+	"class":       func(r lookupResult) any { return resultClass(r.Err) },
+	"error_class": func(r lookupResult) any { return r.errorDetails().Class },
+	"overridden":  func(r lookupResult) any { return r.Overridden },
+	"historical":  func(r lookupResult) any { return r.Historical != nil },
+	"source":      func(r lookupResult) any { return r.source() },
+	"tags":        func(r lookupResult) any { return strings.Join(r.Tags, ",") },
+	"homograph_suspect": func(r lookupResult) any {
+		suspect, _ := homographSuspect(r.Name)
+		return suspect && !r.Overridden
+	},
+	"handle": func(r lookupResult) any {
+		if r.Fetch == nil || r.Fetch.Record == nil {
+			return ""
+		}
+		return r.Fetch.Record.Handle
+	},
+	"error": func(r lookupResult) any {
+		if r.Err == nil {
+			return ""
+		}
+		return r.Err.Error()
+	},
+}
+
+func (f *resultFilter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.source
+}
+
+// Set compiles the expression, so syntax errors are reported as flag errors.
+func (f *resultFilter) Set(expression string) error {
+	parser := &filterParser{tokens: tokenizeFilter(expression)}
+	evaluate, err := parser.parseOr()
+	if err == nil && parser.position < len(parser.tokens) {
+		err = fmt.Errorf("unexpected %q", parser.tokens[parser.position].text)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid filter: %v", err)
+	}
+	f.source, f.evaluate = expression, evaluate
+	return nil
+}
+
+// matches reports whether result passes the filter; an unset filter passes
+// everything.
+func (f *resultFilter) matches(result lookupResult) bool {
+	if f.evaluate == nil {
+		return true
+	}
+	return truthy(f.evaluate(result))
+}
+
+func truthy(value any) bool {
+	switch value := value.(type) {
+	case bool:
+		return value
+	case string:
+		return value != ""
+	case float64:
+		return value != 0
+	}
+	return false
+}
+
+type filterToken struct {
+	kind string // "ident", "string", "number", "op" or "error"
+	text string
+}
+
+func tokenizeFilter(expression string) []filterToken {
+	var tokens []filterToken
+	runes := []rune(expression)
+	for index := 0; index < len(runes); {
+		character := runes[index]
+		switch {
+		case unicode.IsSpace(character):
+			index++
+		case character == '"' || character == '\'':
+			end := index + 1
+			var text strings.Builder
+			for end < len(runes) && runes[end] != character {
+				if runes[end] == '\\' && end+1 < len(runes) {
+					end++
+				}
+				text.WriteRune(runes[end])
+				end++
+			}
+			if end >= len(runes) {
+				return append(tokens, filterToken{"error", "unterminated string"})
+			}
+			tokens = append(tokens, filterToken{"string", text.String()})
+			index = end + 1
+		case unicode.IsDigit(character):
+			end := index
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, filterToken{"number", string(runes[index:end])})
+			index = end
+		case unicode.IsLetter(character) || character == '_':
+			end := index
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_') {
+				end++
+			}
+			tokens = append(tokens, filterToken{"ident", string(runes[index:end])})
+			index = end
+		default:
+			operator := string(character)
+			if index+1 < len(runes) {
+				switch pair := string(runes[index : index+2]); pair {
+				case "==", "!=", "<=", ">=", "=~", "!~", "&&", "||":
+					operator = pair
+				}
+			}
+			tokens = append(tokens, filterToken{"op", operator})
+			index += len([]rune(operator))
+		}
+	}
+	return tokens
+}
+
+// filterParser is a recursive-descent parser that compiles straight to
+// closures over lookupResult.
+type filterParser struct {
+	tokens   []filterToken
+	position int
+}
+
+func (p *filterParser) accept(operator string) bool {
+	if p.position < len(p.tokens) && p.tokens[p.position].kind == "op" && p.tokens[p.position].text == operator {
+		p.position++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (func(lookupResult) any, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		var right func(lookupResult) any
+		if right, err = p.parseAnd(); err == nil {
+			first, second := left, right
+			left = func(r lookupResult) any { return truthy(first(r)) || truthy(second(r)) }
+		}
+	}
+	return left, err
+}
+
+func (p *filterParser) parseAnd() (func(lookupResult) any, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.accept("&&") {
+		var right func(lookupResult) any
+		if right, err = p.parseUnary(); err == nil {
+			first, second := left, right
+			left = func(r lookupResult) any { return truthy(first(r)) && truthy(second(r)) }
+		}
+	}
+	return left, err
+}
+
+func (p *filterParser) parseUnary() (func(lookupResult) any, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(r lookupResult) any { return !truthy(operand(r)) }, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (func(lookupResult) any, error) {
+	left, err := p.parseOperand()
+	if err != nil || p.position >= len(p.tokens) || p.tokens[p.position].kind != "op" {
+		return left, err
+	}
+	operator := p.tokens[p.position].text
+	switch operator {
+	case "==", "!=", "<", "<=", ">", ">=", "=~", "!~":
+		p.position++
+	default:
+		return left, nil
+	}
+	if operator == "=~" || operator == "!~" {
+		if p.position >= len(p.tokens) || p.tokens[p.position].kind != "string" {
+			return nil, fmt.Errorf("%s needs a string pattern", operator)
+		}
+		pattern, err := regexp.Compile(p.tokens[p.position].text)
+		if err != nil {
+			return nil, err
+		}
+		p.position++
+		negate := operator == "!~"
+		return func(r lookupResult) any { return pattern.MatchString(fmt.Sprint(left(r))) != negate }, nil
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return func(r lookupResult) any { return compareFilterValues(left(r), operator, right(r)) }, nil
+}
+
+func (p *filterParser) parseOperand() (func(lookupResult) any, error) {
+	if p.position >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	token := p.tokens[p.position]
+	p.position++
+	switch token.kind {
+	case "string":
+		return func(lookupResult) any { return token.text }, nil
+	case "number":
+		number, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", token.text)
+		}
+		return func(lookupResult) any { return number }, nil
+	case "ident":
+		switch token.text {
+		case "true", "false":
+			value := token.text == "true"
+			return func(lookupResult) any { return value }, nil
+		}
+		field, ok := filterFields[token.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", token.text)
+		}
+		return field, nil
+	case "op":
+		if token.text == "(" {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.accept(")") {
+				return nil, fmt.Errorf("missing )")
+			}
+			return inner, nil
+		}
+	case "error":
+		return nil, fmt.Errorf("%s", token.text)
+	}
+	return nil, fmt.Errorf("unexpected %q", token.text)
+}
+
+// compareFilterValues compares numbers numerically and everything else as
+// case-insensitive strings.
+func compareFilterValues(left any, operator string, right any) bool {
+	order := 0
+	leftNumber, leftIsNumber := left.(float64)
+	rightNumber, rightIsNumber := right.(float64)
+	if leftIsNumber && rightIsNumber {
+		switch {
+		case leftNumber < rightNumber:
+			order = -1
+		case leftNumber > rightNumber:
+			order = 1
+		}
+	} else {
+		order = strings.Compare(strings.ToLower(fmt.Sprint(left)), strings.ToLower(fmt.Sprint(right)))
+	}
+	switch operator {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
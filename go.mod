@@ -2,7 +2,10 @@ module github.com/hookster007/rdap-test
 
 go 1.25
 
-require github.com/openrdap/rdap v0.9.1
+require (
+	github.com/openrdap/rdap v0.9.1
+	golang.org/x/term v0.37.0
+)
 
 require (
 	github.com/alecthomas/kingpin/v2 v2.4.0 // indirect
@@ -10,4 +13,5 @@ require (
 	github.com/mitchellh/go-homedir v1.1.0 // indirect
 	github.com/xhit/go-str2duration/v2 v2.1.0 // indirect
 	golang.org/x/crypto v0.45.0 // indirect
+	golang.org/x/sys v0.38.0 // indirect
 )
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	rdap "github.com/openrdap/rdap"
+)
+
+// resultGroup is one line of -group-by output.
+type resultGroup struct {
+	Key     string
+	Members []string // distinct targets, in input order
+}
+
+// groupKey returns the value of result used to group it under -group-by.
+// Failed lookups are gathered under "(not found)" or "(error)".
+func groupKey(result lookupResult, groupBy string) string {
+	switch resultClass(result.Err) {
+	case classNotFound:
+		return localize("(not found)")
+	case classError:
+		return localize("(error)")
+	}
+	var key string
+	switch groupBy {
+	case "org":
+		key = result.Name
+	case "country":
+		key = result.country()
+	case "registry":
+		key = result.registry()
+	}
+	if key == "" {
+		return localize("(unknown)")
+	}
+	return key
+}
+
+// groupResults collapses results into groups, dropping repeated targets
+// within a group, ordered by descending member count and then by key.
+func groupResults(results []lookupResult, groupBy string) []resultGroup {
+	byKey := map[string]*resultGroup{}
+	var groups []*resultGroup
+	for _, result := range results {
+		key := groupKey(result, groupBy)
+		group, ok := byKey[key]
+		if !ok {
+			group = &resultGroup{Key: key}
+			byKey[key] = group
+			groups = append(groups, group)
+		}
+		if !slices.Contains(group.Members, result.Target) {
+			group.Members = append(group.Members, result.Target)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if len(groups[i].Members) != len(groups[j].Members) {
+			return len(groups[i].Members) > len(groups[j].Members)
+		}
+		return groups[i].Key < groups[j].Key
+	})
+	sorted := make([]resultGroup, len(groups))
+	for index, group := range groups {
+		sorted[index] = *group
+	}
+	return sorted
+}
+
+func writeGroups(output io.Writer, groups []resultGroup) {
+	for _, group := range groups {
+		fmt.Fprintf(output, "%s (%d): %s\n", group.Key, len(group.Members), strings.Join(group.Members, ", "))
+	}
+}
+
+// country is the autnum's country, or the country of its registrant's
+// address when the registry leaves the top-level field empty (as ARIN does).
+// Network lookups report the network's country the same way.
+func (r lookupResult) country() string {
+	if r.Network != nil {
+		return r.Network.Country
+	}
+	if r.Whois != nil {
+		return r.Whois.Country
+	}
+	if r.Fetch == nil || r.Fetch.Record == nil {
+		return ""
+	}
+	return autnumCountry(r.Fetch.Record)
+}
+
+func autnumCountry(autnumRecord *rdap.Autnum) string {
+	if country := strings.TrimSpace(autnumRecord.Country); country != "" {
+		return strings.ToUpper(country)
+	}
+	for _, entity := range autnumRecord.Entities {
+		if entity.VCard != nil && slices.Contains(entity.Roles, "registrant") {
+			if country := strings.TrimSpace(entity.VCard.Country()); country != "" {
+				return country
+			}
+		}
+	}
+	return ""
+}
+
+// registry names the RIR that answered, from the final RDAP URL queried or
+// the WHOIS server of a -whois-fallback answer.
+func (r lookupResult) registry() string {
+	if r.Whois != nil {
+		return whoisRegistry(r.Whois.Server)
+	}
+	if r.Fetch == nil {
+		return ""
+	}
+	return registryForRawURL(r.Fetch.URL)
+}
+
+// registryForRawURL is registryForURL for an unparsed URL; it returns ""
+// when rawURL is empty or invalid.
+func registryForRawURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if rawURL == "" || err != nil {
+		return ""
+	}
+	return registryForURL(parsed)
+}
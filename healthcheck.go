@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// hostHealth accumulates findings for one RDAP hostname.
+type hostHealth struct {
+	Host     string
+	IPv4     []string
+	IPv6     []string
+	Notes    []string
+	Problems []string
+}
+
+func runHealthcheck(args []string) int {
+	flagSet := newFlagSet("healthcheck")
+	samples := flagSet.Int("samples", 3, "number of resolutions per host used to check consistency")
+	expectIPv6 := flagSet.Bool("expect-ipv6", true, "treat a missing AAAA record as an anomaly")
+	checkDNSSEC := flagSet.Bool("dnssec", false, "ask a validating resolver whether each hostname passes DNSSEC validation")
+	dnssecResolver := flagSet.String("dnssec-resolver", "1.1.1.1:53", "validating resolver used for --dnssec")
+	timeout := flagSet.Duration("timeout", 5*time.Second, "timeout per DNS query")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . healthcheck [flags] [hostname-or-url...]")
+		fmt.Fprintln(flagSet.Output(), "Checks DNS health of RDAP endpoint hostnames (default: the RIR RDAP servers).")
+		flagSet.PrintDefaults()
+	}
+	targets, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	if len(targets) == 0 {
+		for host := range knownRegistries {
+			targets = append(targets, host)
+		}
+		sort.Strings(targets)
+	}
+
+	anomalies := 0
+	for _, target := range targets {
+		health := checkHostDNS(hostnameOf(target), *samples, *expectIPv6, *timeout)
+		if *checkDNSSEC {
+			checkHostDNSSEC(health, *dnssecResolver, *timeout)
+		}
+		verdict := "OK  "
+		if len(health.Problems) > 0 {
+			verdict = "WARN"
+			anomalies++
+		}
+		fmt.Printf("%s %s  A=%v AAAA=%v\n", verdict, health.Host, health.IPv4, health.IPv6)
+		for _, note := range health.Notes {
+			fmt.Printf("       %s\n", note)
+		}
+		for _, problem := range health.Problems {
+			fmt.Printf("       ! %s\n", problem)
+		}
+	}
+	if anomalies > 0 {
+		return 1
+	}
+	return 0
+}
+
+// hostnameOf accepts either a bare hostname or an RDAP base URL.
+func hostnameOf(target string) string {
+	if parsed, err := url.Parse(target); err == nil && parsed.Host != "" {
+		return parsed.Hostname()
+	}
+	return target
+}
+
+// checkHostDNS resolves host repeatedly and reports missing record types and
+// answers that differ between resolutions.
+func checkHostDNS(host string, samples int, expectIPv6 bool, timeout time.Duration) *hostHealth {
+	health := &hostHealth{Host: host}
+	var firstAnswer string
+	for sample := 0; sample < max(samples, 1); sample++ {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		cancel()
+		if err != nil {
+			health.Problems = append(health.Problems, fmt.Sprintf("resolution %d failed: %v", sample+1, err))
+			continue
+		}
+		var ipv4, ipv6 []string
+		for _, address := range addresses {
+			if address.IP.To4() != nil {
+				ipv4 = append(ipv4, address.IP.String())
+			} else {
+				ipv6 = append(ipv6, address.IP.String())
+			}
+		}
+		sort.Strings(ipv4)
+		sort.Strings(ipv6)
+		answer := strings.Join(ipv4, ",") + "|" + strings.Join(ipv6, ",")
+		if firstAnswer == "" {
+			firstAnswer = answer
+			health.IPv4, health.IPv6 = ipv4, ipv6
+		} else if answer != firstAnswer {
+			// Round-robin pools legitimately rotate; only flag disjoint answers.
+			if !sharesAddress(health.IPv4, ipv4) && !sharesAddress(health.IPv6, ipv6) {
+				health.Problems = append(health.Problems, fmt.Sprintf("inconsistent answers: %s vs %s", firstAnswer, answer))
+			} else {
+				health.Notes = append(health.Notes, "answers rotate between resolutions")
+			}
+		}
+	}
+	if firstAnswer == "" {
+		return health
+	}
+	if len(health.IPv4) == 0 {
+		health.Problems = append(health.Problems, "no A record")
+	}
+	if len(health.IPv6) == 0 && expectIPv6 {
+		health.Problems = append(health.Problems, "no AAAA record")
+	}
+	return health
+}
+
+func sharesAddress(first, second []string) bool {
+	for _, address := range first {
+		if slices.Contains(second, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHostDNSSEC asks resolver for the host's A record with the DNSSEC OK bit
+// set and interprets the Authenticated Data flag and response code.
+func checkHostDNSSEC(health *hostHealth, resolver string, timeout time.Duration) {
+	authenticated, responseCode, err := queryAuthenticatedData(health.Host, resolver, timeout)
+	switch {
+	case err != nil:
+		health.Problems = append(health.Problems, fmt.Sprintf("DNSSEC check via %s failed: %v", resolver, err))
+	case responseCode == 2: // SERVFAIL from a validating resolver usually means bogus signatures
+		health.Problems = append(health.Problems, "DNSSEC validation failed (SERVFAIL from validating resolver)")
+	case authenticated:
+		health.Notes = append(health.Notes, "DNSSEC validated")
+	default:
+		health.Notes = append(health.Notes, "DNSSEC not validated (zone unsigned or resolver not validating)")
+	}
+}
+
+// queryAuthenticatedData sends a single UDP DNS query for the A record of
+// name with EDNS0 DO=1 and AD=1, returning the AD bit and RCODE of the reply.
+func queryAuthenticatedData(name, resolver string, timeout time.Duration) (bool, int, error) {
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return false, 0, err
+	}
+	queryID := binary.BigEndian.Uint16(idBytes[:])
+
+	message := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(message[0:], queryID)
+	binary.BigEndian.PutUint16(message[2:], 0x0120) // RD + AD
+	binary.BigEndian.PutUint16(message[4:], 1)      // QDCOUNT
+	binary.BigEndian.PutUint16(message[10:], 1)     // ARCOUNT (OPT)
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false, 0, fmt.Errorf("invalid hostname %q", name)
+		}
+		message = append(message, byte(len(label)))
+		message = append(message, label...)
+	}
+	message = append(message, 0, 0, 1, 0, 1) // root, QTYPE=A, QCLASS=IN
+	// OPT pseudo-record: root name, TYPE=41, UDP size 1232, extended RCODE 0,
+	// version 0, flags DO, no options.
+	message = append(message, 0, 0, 41, 0x04, 0xd0, 0, 0, 0x80, 0, 0, 0)
+
+	connection, err := net.DialTimeout("udp", resolver, timeout)
+	if err != nil {
+		return false, 0, err
+	}
+	defer connection.Close()
+	_ = connection.SetDeadline(time.Now().Add(timeout))
+	if _, err := connection.Write(message); err != nil {
+		return false, 0, err
+	}
+	reply := make([]byte, 4096)
+	length, err := connection.Read(reply)
+	if err != nil {
+		return false, 0, err
+	}
+	if length < 12 || binary.BigEndian.Uint16(reply[0:]) != queryID {
+		return false, 0, fmt.Errorf("malformed or mismatched DNS reply")
+	}
+	flags := binary.BigEndian.Uint16(reply[2:])
+	return flags&0x0020 != 0, int(flags & 0x000f), nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyEntry is one recorded lookup, stored as a JSON line.
+type historyEntry struct {
+	Time   time.Time `json:"time"`
+	Mode   string    `json:"mode"`
+	Target string    `json:"target"`
+	Result string    `json:"result,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// historyPath returns $XDG_DATA_HOME/rdaptester/history, defaulting to
+// ~/.local/share/rdaptester/history.
+func historyPath() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "rdaptester", "history"), nil
+}
+
+// recordHistory appends a lookup to the history file. Failures are reported
+// on stderr but never interrupt the lookup itself.
+func recordHistory(mode, target, result string, lookupErr error) {
+	entry := historyEntry{Time: time.Now().UTC(), Mode: mode, Target: target, Result: result}
+	if lookupErr != nil {
+		entry.Error = lookupErr.Error()
+	}
+	if err := appendHistory(entry); err != nil {
+		slog.Warn("history not recorded", "error", err)
+	}
+}
+
+func appendHistory(entry historyEntry) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+func loadHistory() ([]historyEntry, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry historyEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// runHistory implements "history" (list), "history export" and "history clear".
+func runHistory(args []string) int {
+	entries, err := loadHistory()
+	if err != nil {
+		fmt.Printf("history: %v\n", err)
+		return 1
+	}
+	if len(args) == 0 || args[0] == "list" {
+		for index, entry := range entries {
+			outcome := entry.Result
+			if entry.Error != "" {
+				outcome = "error: " + entry.Error
+			}
+			fmt.Printf("%5d  %s  %-6s %-12s %s\n", index+1, entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Mode, entry.Target, outcome)
+		}
+		return 0
+	}
+
+	switch args[0] {
+	case "export":
+		flagSet := newFlagSet("history export")
+		format := flagSet.String("format", "json", "export format: json or csv")
+		since := flagSet.Duration("since", 0, "only export entries newer than this (e.g. 6h)")
+		positional, err := parseInterspersed(flagSet, args[1:])
+		if err != nil {
+			return 2
+		}
+		if *since > 0 {
+			cutoff := time.Now().Add(-*since)
+			var recent []historyEntry
+			for _, entry := range entries {
+				if entry.Time.After(cutoff) {
+					recent = append(recent, entry)
+				}
+			}
+			entries = recent
+		}
+		var output io.Writer = os.Stdout
+		if len(positional) > 0 {
+			file, err := os.Create(positional[0])
+			if err != nil {
+				fmt.Printf("history: %v\n", err)
+				return 1
+			}
+			defer file.Close()
+			output = file
+		}
+		if err := exportHistory(output, entries, *format); err != nil {
+			fmt.Printf("history: %v\n", err)
+			return 1
+		}
+		return 0
+	case "clear":
+		path, err := historyPath()
+		if err == nil {
+			err = os.Remove(path)
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Printf("history: %v\n", err)
+			return 1
+		}
+		return 0
+	default:
+		fmt.Println("usage: go run . history [list | export [--format json|csv] [--since 6h] [file] | clear]")
+		return 2
+	}
+}
+
+func exportHistory(output io.Writer, entries []historyEntry, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		if entries == nil {
+			entries = []historyEntry{}
+		}
+		return encoder.Encode(entries)
+	case "csv":
+		writer := csv.NewWriter(output)
+		_ = writer.Write([]string{"time", "mode", "target", "result", "error"})
+		for _, entry := range entries {
+			_ = writer.Write([]string{entry.Time.Format(time.RFC3339), entry.Mode, entry.Target, entry.Result, entry.Error})
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// recallHistory resolves "!!" (last entry), "!N" (entry N) and "!-N" (N-th
+// most recent entry) to the recorded target.
+func recallHistory(reference string) (string, error) {
+	entries, err := loadHistory()
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("history is empty")
+	}
+	selector := strings.TrimPrefix(reference, "!")
+	index := len(entries) - 1
+	if selector != "!" {
+		number, err := strconv.Atoi(selector)
+		if err != nil {
+			return "", fmt.Errorf("invalid history reference %q", reference)
+		}
+		if number < 0 {
+			index = len(entries) + number
+		} else {
+			index = number - 1
+		}
+	}
+	if index < 0 || index >= len(entries) {
+		return "", fmt.Errorf("no history entry %s", reference)
+	}
+	return entries[index].Target, nil
+}
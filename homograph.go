@@ -0,0 +1,171 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// homographScripts are the scripts told apart when looking for mixed-script
+// names. Characters in none of them (digits, punctuation, spaces) are
+// neutral.
+var homographScripts = []struct {
+	name  string
+	table *unicode.RangeTable
+}{
+	{"Latin", unicode.Latin},
+	{"Cyrillic", unicode.Cyrillic},
+	{"Greek", unicode.Greek},
+	{"Armenian", unicode.Armenian},
+	{"Hebrew", unicode.Hebrew},
+	{"Arabic", unicode.Arabic},
+	{"Cherokee", unicode.Cherokee},
+	{"Han", unicode.Han},
+	{"Hiragana", unicode.Hiragana},
+	{"Katakana", unicode.Katakana},
+	{"Hangul", unicode.Hangul},
+	{"Bopomofo", unicode.Bopomofo},
+	{"Thai", unicode.Thai},
+	{"Devanagari", unicode.Devanagari},
+}
+
+// latinConfusables are non-Latin letters that render like Latin ones in
+// common fonts, after Unicode's confusables data for the most abused cases.
+var latinConfusables = map[rune]rune{
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p',
+	'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'i', 'ј': 'j', 'ԁ': 'd',
+	'ӏ': 'l', 'ԛ': 'q', 'ԝ': 'w', 'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M',
+	'Н': 'H', 'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T', 'Х': 'X', 'Ѕ': 'S', 'І': 'I',
+	'Ј': 'J', 'α': 'a', 'ο': 'o', 'ν': 'v', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'ι': 'i',
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M',
+	'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X', 'օ': 'o', 'ս': 'u',
+}
+
+// homographSuspect reports whether name (an organization name or domain)
+// could be impersonating another: it mixes scripts, is written entirely in
+// letters that look Latin without being Latin, or hides invisible or
+// direction-changing characters. The reason is empty when it is not
+// suspect. Punycode ("xn--") domain labels are decoded first.
+func homographSuspect(name string) (bool, string) {
+	name = decodePunycodeLabels(name)
+	var scripts []string
+	letters, confusable := 0, 0
+	for _, character := range name {
+		switch {
+		case unicode.In(character, unicode.Cf), unicode.Is(unicode.Bidi_Control, character):
+			return true, "invisible or bidirectional control character"
+		case !unicode.IsLetter(character):
+			continue
+		}
+		letters++
+		if _, ok := latinConfusables[character]; ok {
+			confusable++
+		}
+		for _, script := range homographScripts {
+			if unicode.Is(script.table, character) {
+				if !slices.Contains(scripts, script.name) {
+					scripts = append(scripts, script.name)
+				}
+				break
+			}
+		}
+	}
+	if len(scripts) > 1 && !allowedScriptMix(scripts) {
+		return true, "mixed scripts " + strings.Join(scripts, "+")
+	}
+	if letters > 0 && confusable == letters {
+		return true, "only Latin look-alike letters (" + strings.Join(scripts, "+") + ")"
+	}
+	return false, ""
+}
+
+// allowedScriptMixes are the combinations UTS #39 accepts at its "highly
+// restrictive" level, as ordinary CJK text mixes them with Latin.
+var allowedScriptMixes = [][]string{
+	{"Latin", "Han", "Hiragana", "Katakana"},
+	{"Latin", "Han", "Bopomofo"},
+	{"Latin", "Han", "Hangul"},
+}
+
+func allowedScriptMix(scripts []string) bool {
+	for _, allowed := range allowedScriptMixes {
+		if !slices.ContainsFunc(scripts, func(script string) bool { return !slices.Contains(allowed, script) }) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodePunycodeLabels decodes every "xn--" label of a domain name (RFC
+// 3492), leaving labels that fail to decode as they are.
+func decodePunycodeLabels(name string) string {
+	if !strings.Contains(strings.ToLower(name), "xn--") {
+		return name
+	}
+	labels := strings.Split(name, ".")
+	for index, label := range labels {
+		if len(label) > 4 && strings.EqualFold(label[:4], "xn--") {
+			if decoded, ok := decodePunycode(strings.ToLower(label[4:])); ok {
+				labels[index] = decoded
+			}
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+func decodePunycode(encoded string) (string, bool) {
+	const base, tMin, tMax, skew, damp = 36, 1, 26, 38, 700
+	var output []rune
+	if delimiter := strings.LastIndexByte(encoded, '-'); delimiter >= 0 {
+		output = []rune(encoded[:delimiter])
+		encoded = encoded[delimiter+1:]
+	}
+	n, i, bias := 128, 0, 72
+	for position := 0; position < len(encoded); {
+		oldI, weight := i, 1
+		for k := base; ; k += base {
+			if position >= len(encoded) {
+				return "", false
+			}
+			character := encoded[position]
+			position++
+			var digit int
+			switch {
+			case character >= 'a' && character <= 'z':
+				digit = int(character - 'a')
+			case character >= '0' && character <= '9':
+				digit = int(character-'0') + 26
+			default:
+				return "", false
+			}
+			i += digit * weight
+			threshold := min(max(k-bias, tMin), tMax)
+			if digit < threshold {
+				break
+			}
+			weight *= base - threshold
+		}
+		// Adapt the bias (RFC 3492 section 6.1).
+		delta := i - oldI
+		if oldI == 0 {
+			delta /= damp
+		} else {
+			delta /= 2
+		}
+		delta += delta / (len(output) + 1)
+		k := 0
+		for delta > ((base-tMin)*tMax)/2 {
+			delta /= base - tMin
+			k += base
+		}
+		bias = k + (base-tMin+1)*delta/(delta+skew)
+		n += i / (len(output) + 1)
+		i %= len(output) + 1
+		if n > unicode.MaxRune {
+			return "", false
+		}
+		output = slices.Insert(output, i, rune(n))
+		i++
+	}
+	return string(output), true
+}
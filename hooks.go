@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// resultHook is one rule of a -hook script: an action applied to every
+// result its condition (a -filter expression) matches.
+type resultHook struct {
+	action    string // drop, tag, annotate or name
+	key       string // tag, annotation key or new name
+	value     string // annotation value
+	condition resultFilter
+}
+
+// resultHooks are the rules of a -hook script, applied in order, so later
+// rules see the tags, annotations and names earlier ones set.
+type resultHooks []resultHook
+
+// loadHooks reads a hook script: one rule per line, an action optionally
+// followed by "if <filter expression>". Blank lines and lines starting
+// with # are ignored.
+//
+//	tag partner           if asn == 15169 || asn == 13335
+//	annotate risk=high    if country == "RU" && !(tags =~ "partner")
+//	name "Google (CDN)"   if asn == 15169
+//	drop                  if class == "notfound"
+func loadHooks(path string) (resultHooks, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var hooks resultHooks
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hook, err := parseHook(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, scanner.Err()
+}
+
+func parseHook(line string) (resultHook, error) {
+	action, condition, conditional := strings.Cut(line, " if ")
+	if !conditional && strings.HasPrefix(line, "if ") {
+		return resultHook{}, fmt.Errorf("missing action before %q", line)
+	}
+	var hook resultHook
+	if conditional {
+		if err := hook.condition.Set(strings.TrimSpace(condition)); err != nil {
+			return resultHook{}, err
+		}
+	}
+	verb, argument, _ := strings.Cut(strings.TrimSpace(action), " ")
+	argument = strings.TrimSpace(argument)
+	hook.action = verb
+	switch verb {
+	case "drop":
+		if argument != "" {
+			return resultHook{}, fmt.Errorf("drop takes no argument, got %q", argument)
+		}
+	case "tag":
+		if argument == "" || strings.ContainsAny(argument, " \t,") {
+			return resultHook{}, fmt.Errorf("tag takes one word, got %q", argument)
+		}
+		hook.key = argument
+	case "annotate":
+		key, value, ok := strings.Cut(argument, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return resultHook{}, fmt.Errorf("expected \"annotate key=value\", got %q", argument)
+		}
+		hook.key, hook.value = strings.TrimSpace(key), unquoteHookValue(strings.TrimSpace(value))
+	case "name":
+		if argument == "" {
+			return resultHook{}, fmt.Errorf("name takes the new name")
+		}
+		hook.key = unquoteHookValue(argument)
+	default:
+		return resultHook{}, fmt.Errorf("unknown action %q (want drop, tag, annotate or name)", verb)
+	}
+	return hook, nil
+}
+
+// unquoteHookValue strips the double quotes around a value that has them.
+func unquoteHookValue(value string) string {
+	if unquoted, err := strconv.Unquote(value); err == nil && strings.HasPrefix(value, `"`) {
+		return unquoted
+	}
+	return value
+}
+
+// apply runs the rules on result and reports whether it is kept.
+func (h resultHooks) apply(result *lookupResult) bool {
+	for _, hook := range h {
+		if !hook.condition.matches(*result) {
+			continue
+		}
+		switch hook.action {
+		case "drop":
+			return false
+		case "tag":
+			if !slices.Contains(result.Tags, hook.key) {
+				result.Tags = append(result.Tags, hook.key)
+			}
+		case "annotate":
+			if result.Annotations == nil {
+				result.Annotations = map[string]string{}
+			}
+			result.Annotations[hook.key] = hook.value
+		case "name":
+			result.Name = hook.key
+		}
+	}
+	return true
+}
+
+// hookNote renders the tags and annotations after the result line.
+func (r lookupResult) hookNote() string {
+	parts := slices.Clone(r.Tags)
+	keys := make([]string, 0, len(r.Annotations))
+	for key := range r.Annotations {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		parts = append(parts, key+"="+r.Annotations[key])
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(parts, ", ") + "]"
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// messageLanguage is the language of human-facing batch output, chosen with
+// -lang or from the locale environment. Machine formats (JSON, CSV, logs,
+// flag names) always stay in English.
+var messageLanguage = "en"
+
+// messageCatalogs translate English message formats, keyed by the exact
+// format string passed to localize. Missing entries fall back to English.
+var messageCatalogs = map[string]map[string]string{
+	"de": {
+		"usage: %s":                            "Aufruf: %s",
+		"%s: invalid ASN: %v":                  "%s: ungültige ASN: %v",
+		"%s: error: %v":                        "%s: Fehler: %v",
+		"%s: error [%s]: %v":                   "%s: Fehler [%s]: %v",
+		"%s: (no name found)":                  "%s: (kein Name gefunden)",
+		"%s: %s [override]":                    "%s: %s [überschrieben]",
+		"%s [historical: captured %s from %s]": "%s [historisch: erfasst %s aus %s]",
+		"%s [homograph suspect: %s]":           "%s [Homograph-Verdacht: %s]",
+		"%s: invalid domain name: %v":          "%s: ungültiger Domainname: %v",
+		"registrar %s":                         "Registrar %s",
+		"created %s":                           "angelegt %s",
+		"expires %s":                           "läuft ab %s",
+		"status %s":                            "Status %s",
+		"stale cache used":                     "veralteter Cache verwendet",
+		"name derived from a remark":           "Name aus einer Anmerkung abgeleitet",
+		"name derived from the record's name or handle":                "Name aus Namen oder Handle des Eintrags abgeleitet",
+		"response not strictly RFC 9083 compliant: no rdapConformance": "Antwort nicht streng RFC-9083-konform: kein rdapConformance",
+		"response not strictly RFC 9083 compliant: no objectClassName": "Antwort nicht streng RFC-9083-konform: kein objectClassName",
+		" [warnings: %s]":                        " [Warnungen: %s]",
+		"kind %s":                                "Art %s",
+		"roles %s":                               "Rollen %s",
+		"handle %s":                              "Handle %s",
+		"addresses %s":                           "Adressen %s",
+		"zone %s":                                "Zone %s",
+		"nameservers %s":                         "Nameserver %s",
+		"netname %s":                             "Netzname %s",
+		"country %s":                             "Land %s",
+		"origin %s":                              "Ursprung %s",
+		" [class %s]":                            " [Klasse %s]",
+		" [line %d]":                             " [Zeile %d]",
+		"registrant":                             "Inhaber",
+		"administrative":                         "Administrativ",
+		"technical":                              "Technisch",
+		"abuse":                                  "Missbrauch",
+		"%s: registered":                         "%s: registriert",
+		"%s: not found":                          "%s: nicht gefunden",
+		"%d registered, %d not found, %d errors": "%d registriert, %d nicht gefunden, %d Fehler",
+		" [%s, %s old]":                          " [%s, %s alt]",
+		"%s [succeeded after failed attempts: %s]": "%s [erfolgreich nach fehlgeschlagenen Versuchen: %s]",
+		"%s: skipped (skip list)":                  "%s: übersprungen (Ausschlussliste)",
+		"-watch takes exactly one ASN":             "-watch erwartet genau eine ASN",
+		"(not found)":                              "(nicht gefunden)",
+		"(error)":                                  "(Fehler)",
+		"(unknown)":                                "(unbekannt)",
+		"Run statistics:":                          "Laufstatistik:",
+		"lookups":                                  "Abfragen",
+		"http requests":                            "HTTP-Anfragen",
+		"%d (%d bootstrap downloads)":              "%d (%d Bootstrap-Downloads)",
+		"cache hits":                               "Cache-Treffer",
+		"%d lookups used the cached bootstrap registry": "%d Abfragen nutzten die zwischengespeicherte Bootstrap-Registry",
+		"per registry":   "je Registry",
+		"per country":    "je Land",
+		"latency":        "Latenz",
+		"bytes received": "Bytes empfangen",
+		"none":           "keine",
+	},
+	"es": {
+		"usage: %s":                            "uso: %s",
+		"%s: invalid ASN: %v":                  "%s: ASN no válido: %v",
+		"%s: error: %v":                        "%s: error: %v",
+		"%s: error [%s]: %v":                   "%s: error [%s]: %v",
+		"%s: (no name found)":                  "%s: (no se encontró nombre)",
+		"%s: %s [override]":                    "%s: %s [sustituido]",
+		"%s [historical: captured %s from %s]": "%s [histórico: capturado %s de %s]",
+		"%s [homograph suspect: %s]":           "%s [sospecha de homógrafo: %s]",
+		"%s: invalid domain name: %v":          "%s: nombre de dominio no válido: %v",
+		"registrar %s":                         "registrador %s",
+		"created %s":                           "creado %s",
+		"expires %s":                           "caduca %s",
+		"status %s":                            "estado %s",
+		"stale cache used":                     "se usó caché obsoleta",
+		"name derived from a remark":           "nombre derivado de una observación",
+		"name derived from the record's name or handle":                "nombre derivado del nombre o identificador del registro",
+		"response not strictly RFC 9083 compliant: no rdapConformance": "respuesta no estrictamente conforme a RFC 9083: sin rdapConformance",
+		"response not strictly RFC 9083 compliant: no objectClassName": "respuesta no estrictamente conforme a RFC 9083: sin objectClassName",
+		" [warnings: %s]":                        " [advertencias: %s]",
+		"kind %s":                                "tipo %s",
+		"roles %s":                               "roles %s",
+		"handle %s":                              "identificador %s",
+		"addresses %s":                           "direcciones %s",
+		"zone %s":                                "zona %s",
+		"nameservers %s":                         "servidores de nombres %s",
+		"netname %s":                             "nombre de red %s",
+		"country %s":                             "país %s",
+		"origin %s":                              "origen %s",
+		" [class %s]":                            " [clase %s]",
+		" [line %d]":                             " [línea %d]",
+		"registrant":                             "titular",
+		"administrative":                         "administrativo",
+		"technical":                              "técnico",
+		"abuse":                                  "abuso",
+		"%s: registered":                         "%s: registrado",
+		"%s: not found":                          "%s: no encontrado",
+		"%d registered, %d not found, %d errors": "%d registrados, %d no encontrados, %d errores",
+		" [%s, %s old]":                          " [%s, antigüedad %s]",
+		"%s [succeeded after failed attempts: %s]": "%s [correcto tras intentos fallidos: %s]",
+		"%s: skipped (skip list)":                  "%s: omitido (lista de exclusión)",
+		"-watch takes exactly one ASN":             "-watch requiere exactamente un ASN",
+		"(not found)":                              "(no encontrado)",
+		"(error)":                                  "(error)",
+		"(unknown)":                                "(desconocido)",
+		"Run statistics:":                          "Estadísticas de la ejecución:",
+		"lookups":                                  "consultas",
+		"http requests":                            "peticiones HTTP",
+		"%d (%d bootstrap downloads)":              "%d (%d descargas de bootstrap)",
+		"cache hits":                               "aciertos de caché",
+		"%d lookups used the cached bootstrap registry": "%d consultas usaron el registro bootstrap en caché",
+		"per registry":   "por registro",
+		"per country":    "por país",
+		"latency":        "latencia",
+		"bytes received": "bytes recibidos",
+		"none":           "ninguno",
+	},
+	"fr": {
+		"usage: %s":                            "utilisation : %s",
+		"%s: invalid ASN: %v":                  "%s : ASN invalide : %v",
+		"%s: error: %v":                        "%s : erreur : %v",
+		"%s: error [%s]: %v":                   "%s : erreur [%s] : %v",
+		"%s: (no name found)":                  "%s : (aucun nom trouvé)",
+		"%s: %s [override]":                    "%s : %s [remplacé]",
+		"%s [historical: captured %s from %s]": "%s [historique : capturé %s depuis %s]",
+		"%s [homograph suspect: %s]":           "%s [homographe suspect : %s]",
+		"%s: invalid domain name: %v":          "%s : nom de domaine invalide : %v",
+		"registrar %s":                         "bureau d'enregistrement %s",
+		"created %s":                           "créé %s",
+		"expires %s":                           "expire %s",
+		"status %s":                            "statut %s",
+		"stale cache used":                     "cache périmé utilisé",
+		"name derived from a remark":           "nom tiré d'une remarque",
+		"name derived from the record's name or handle":                "nom tiré du nom ou de l'identifiant de l'enregistrement",
+		"response not strictly RFC 9083 compliant: no rdapConformance": "réponse pas strictement conforme à la RFC 9083 : pas de rdapConformance",
+		"response not strictly RFC 9083 compliant: no objectClassName": "réponse pas strictement conforme à la RFC 9083 : pas d'objectClassName",
+		" [warnings: %s]":                        " [avertissements : %s]",
+		"kind %s":                                "type %s",
+		"roles %s":                               "rôles %s",
+		"handle %s":                              "identifiant %s",
+		"addresses %s":                           "adresses %s",
+		"zone %s":                                "zone %s",
+		"nameservers %s":                         "serveurs de noms %s",
+		"netname %s":                             "nom de réseau %s",
+		"country %s":                             "pays %s",
+		"origin %s":                              "origine %s",
+		" [class %s]":                            " [classe %s]",
+		" [line %d]":                             " [ligne %d]",
+		"registrant":                             "titulaire",
+		"administrative":                         "administratif",
+		"technical":                              "technique",
+		"abuse":                                  "abus",
+		"%s: registered":                         "%s : enregistré",
+		"%s: not found":                          "%s : introuvable",
+		"%d registered, %d not found, %d errors": "%d enregistrés, %d introuvables, %d erreurs",
+		" [%s, %s old]":                          " [%s, âge %s]",
+		"%s [succeeded after failed attempts: %s]": "%s [réussi après des tentatives échouées : %s]",
+		"%s: skipped (skip list)":                  "%s : ignoré (liste d'exclusion)",
+		"-watch takes exactly one ASN":             "-watch attend exactement un ASN",
+		"(not found)":                              "(introuvable)",
+		"(error)":                                  "(erreur)",
+		"(unknown)":                                "(inconnu)",
+		"Run statistics:":                          "Statistiques d'exécution :",
+		"lookups":                                  "requêtes",
+		"http requests":                            "requêtes HTTP",
+		"%d (%d bootstrap downloads)":              "%d (%d téléchargements bootstrap)",
+		"cache hits":                               "succès de cache",
+		"%d lookups used the cached bootstrap registry": "%d requêtes ont utilisé le registre bootstrap en cache",
+		"per registry":   "par registre",
+		"per country":    "par pays",
+		"latency":        "latence",
+		"bytes received": "octets reçus",
+		"none":           "aucun",
+	},
+}
+
+// localize returns the translation of an English message format in the
+// current language, or the format itself when there is none.
+func localize(format string) string {
+	if translated, ok := messageCatalogs[messageLanguage][format]; ok {
+		return translated
+	}
+	return format
+}
+
+// detectLanguage picks the message language from -lang, or else from
+// LC_ALL, LC_MESSAGES or LANG (e.g. "de_DE.UTF-8" selects "de"). Unsupported
+// languages fall back to English.
+func detectLanguage(flagValue string) string {
+	candidates := []string{flagValue, os.Getenv("LC_ALL"), os.Getenv("LC_MESSAGES"), os.Getenv("LANG")}
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		language := strings.ToLower(candidate)
+		if cut := strings.IndexAny(language, "_.@-"); cut >= 0 {
+			language = language[:cut]
+		}
+		if _, ok := messageCatalogs[language]; ok {
+			return language
+		}
+		// The first setting that is present decides, as with POSIX locales.
+		return "en"
+	}
+	return "en"
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// knownRegistries maps RDAP service hostnames to the RIR operating them.
+var knownRegistries = map[string]string{
+	"rdap.arin.net":    "ARIN",
+	"rdap.db.ripe.net": "RIPE",
+	"rdap.apnic.net":   "APNIC",
+	"rdap.lacnic.net":  "LACNIC",
+	"rdap.afrinic.net": "AFRINIC",
+}
+
+// bootstrapFilenames are the IANA bootstrap registry files.
+var bootstrapFilenames = []string{"asn.json", "ipv4.json", "ipv6.json", "dns.json", "object-tags.json"}
+
+// latencyPhases is the display order of phases in the report.
+var latencyPhases = []string{"bootstrap", "dns", "connect", "tls", "ttfb", "total"}
+
+// registryForURL names the RIR behind an RDAP URL, falling back to the hostname
+func registryForURL(rdapURL *url.URL) string {
+	if rdapURL == nil {
+		return "unknown"
+	}
+	host := strings.ToLower(rdapURL.Hostname())
+	if registry, ok := knownRegistries[host]; ok {
+		return registry
+	}
+	return host
+}
+
+// latencyRecorder collects request phase durations keyed by registry and phase.
+type latencyRecorder struct {
+	mutex   sync.Mutex
+	samples map[string]map[string][]time.Duration
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{samples: map[string]map[string][]time.Duration{}}
+}
+
+// observe is a clientOptions.Trace callback.
+func (l *latencyRecorder) observe(request *http.Request, timings requestTimings) {
+	l.observeFrom("", request, timings)
+}
+
+// observerFor returns a Trace callback that tags samples with a vantage point,
+// so the report separates e.g. "ARIN@eu-proxy" from "ARIN@us-proxy".
+func (l *latencyRecorder) observerFor(vantage string) func(*http.Request, requestTimings) {
+	return func(request *http.Request, timings requestTimings) {
+		l.observeFrom(vantage, request, timings)
+	}
+}
+
+func (l *latencyRecorder) observeFrom(vantage string, request *http.Request, timings requestTimings) {
+	suffix := ""
+	if vantage != "" {
+		suffix = "@" + vantage
+	}
+	registry := registryForURL(request.URL) + suffix
+	for _, filename := range bootstrapFilenames {
+		if strings.HasSuffix(request.URL.Path, "/"+filename) {
+			l.add("IANA bootstrap"+suffix, "bootstrap", timings.Total)
+			return
+		}
+	}
+	if timings.DNS > 0 {
+		l.add(registry, "dns", timings.DNS)
+	}
+	if timings.Connect > 0 {
+		l.add(registry, "connect", timings.Connect)
+	}
+	if timings.TLS > 0 {
+		l.add(registry, "tls", timings.TLS)
+	}
+	l.add(registry, "ttfb", timings.TTFB)
+	l.add(registry, "total", timings.Total)
+}
+
+func (l *latencyRecorder) add(registry, phase string, duration time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.samples[registry] == nil {
+		l.samples[registry] = map[string][]time.Duration{}
+	}
+	l.samples[registry][phase] = append(l.samples[registry][phase], duration)
+}
+
+// writeReport prints percentiles per registry and phase followed by a
+// histogram of total request latency per registry.
+func (l *latencyRecorder) writeReport(output io.Writer) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.samples) == 0 {
+		fmt.Fprintln(output, "\nlatency: no requests recorded")
+		return
+	}
+
+	registries := make([]string, 0, len(l.samples))
+	for registry := range l.samples {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+
+	fmt.Fprintln(output, "\nLatency by registry and phase:")
+	fmt.Fprintf(output, "  %-24s %-9s %6s %9s %9s %9s %9s\n", "registry", "phase", "n", "p50", "p90", "p99", "max")
+	for _, registry := range registries {
+		for _, phase := range latencyPhases {
+			durations := l.samples[registry][phase]
+			if len(durations) == 0 {
+				continue
+			}
+			sorted := sortedDurations(durations)
+			fmt.Fprintf(output, "  %-24s %-9s %6d %9s %9s %9s %9s\n", registry, phase, len(sorted),
+				formatMillis(percentile(sorted, 50)), formatMillis(percentile(sorted, 90)),
+				formatMillis(percentile(sorted, 99)), formatMillis(sorted[len(sorted)-1]))
+		}
+	}
+
+	for _, registry := range registries {
+		durations := l.samples[registry]["total"]
+		if len(durations) == 0 {
+			durations = l.samples[registry]["bootstrap"]
+		}
+		if len(durations) == 0 {
+			continue
+		}
+		fmt.Fprintf(output, "\n%s total latency histogram:\n", registry)
+		writeHistogram(output, durations)
+	}
+}
+
+// latencyBucketBounds are the upper bounds of the histogram buckets.
+var latencyBucketBounds = []time.Duration{
+	25 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+	500 * time.Millisecond, time.Second, 2 * time.Second, 5 * time.Second,
+}
+
+func writeHistogram(output io.Writer, durations []time.Duration) {
+	counts := make([]int, len(latencyBucketBounds)+1)
+	for _, duration := range durations {
+		bucket := sort.Search(len(latencyBucketBounds), func(i int) bool { return duration <= latencyBucketBounds[i] })
+		counts[bucket]++
+	}
+	largest := 0
+	for _, count := range counts {
+		largest = max(largest, count)
+	}
+	const barWidth = 40
+	for i, count := range counts {
+		label := "> " + latencyBucketBounds[len(latencyBucketBounds)-1].String()
+		if i < len(latencyBucketBounds) {
+			label = "<= " + latencyBucketBounds[i].String()
+		}
+		bar := strings.Repeat("#", count*barWidth/largest)
+		fmt.Fprintf(output, "  %9s | %-*s %d\n", label, barWidth, bar, count)
+	}
+}
+
+func sortedDurations(durations []time.Duration) []time.Duration {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// percentile uses the nearest-rank method on an ascending slice.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func formatMillis(duration time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(duration.Microseconds())/1000)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// responseLimits cap what a single upstream response may contain, so a broken
+// or malicious server cannot exhaust memory or CPU with a huge body, deeply
+// nested JSON or an endless entity list. Zero disables a limit.
+type responseLimits struct {
+	MaxBodySize byteSize // -max-response-size
+	MaxDepth    int      // -max-json-depth
+	MaxEntities int      // -max-entities
+}
+
+var defaultResponseLimits = responseLimits{MaxBodySize: 4 << 20, MaxDepth: 64, MaxEntities: 1000}
+
+// responseLimitError reports a response rejected by a limit. The HTTP client
+// prefixes it with the request URL.
+type responseLimitError struct {
+	Reason string
+}
+
+func (e *responseLimitError) Error() string { return "response rejected: " + e.Reason }
+
+// limitTransport buffers every successful response and rejects it when it
+// breaks a limit, before openrdap or the bootstrap client decodes it.
+type limitTransport struct {
+	base   http.RoundTripper
+	limits responseLimits
+}
+
+func (t *limitTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := t.base.RoundTrip(request)
+	if err != nil || response.StatusCode < 200 || response.StatusCode > 299 {
+		return response, err
+	}
+	reader := io.Reader(response.Body)
+	if t.limits.MaxBodySize > 0 {
+		reader = io.LimitReader(response.Body, int64(t.limits.MaxBodySize)+1)
+	}
+	body, err := io.ReadAll(reader)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if t.limits.MaxBodySize > 0 && int64(len(body)) > int64(t.limits.MaxBodySize) {
+		return nil, &responseLimitError{fmt.Sprintf("body larger than -max-response-size %s", t.limits.MaxBodySize.String())}
+	}
+	if reason := t.limits.check(body); reason != "" {
+		return nil, &responseLimitError{reason}
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	response.ContentLength = int64(len(body))
+	return response, nil
+}
+
+// check walks the JSON tokens of body and describes the first limit it
+// breaks, or returns "". Entities are counted across every "entities" array,
+// nested ones included. Malformed JSON is left for the decoder to report.
+func (l responseLimits) check(body []byte) string {
+	type container struct {
+		object    bool
+		expectKey bool // the next token of an object is a key
+		entities  bool // an "entities" array
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	var stack []container
+	entities, key := 0, ""
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		var top *container
+		if len(stack) > 0 {
+			top = &stack[len(stack)-1]
+		}
+		if token == json.Delim('}') || token == json.Delim(']') {
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 && stack[len(stack)-1].object {
+				stack[len(stack)-1].expectKey = true
+			}
+			continue
+		}
+		if top != nil && top.object && top.expectKey {
+			key, _ = token.(string)
+			top.expectKey = false
+			continue
+		}
+		if top != nil && top.entities {
+			if entities++; l.MaxEntities > 0 && entities > l.MaxEntities {
+				return fmt.Sprintf("more than -max-entities %d entities", l.MaxEntities)
+			}
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			if l.MaxDepth > 0 && len(stack) >= l.MaxDepth {
+				return fmt.Sprintf("JSON nested deeper than -max-json-depth %d", l.MaxDepth)
+			}
+			object := token == json.Delim('{')
+			stack = append(stack, container{object: object, expectKey: object,
+				entities: !object && top != nil && top.object && key == "entities"})
+		default:
+			if top != nil && top.object {
+				top.expectKey = true
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// installLogger routes all diagnostics through a log/slog handler on stderr.
+// format is "text" or "json"; level is debug, info, warn or error, or empty
+// to default to info (debug when any -v flag is given).
+func installLogger(format, level string, verbosity int) error {
+	handlerOptions := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if verbosity > 0 {
+		handlerOptions.Level = slog.LevelDebug
+	}
+	if level != "" {
+		var parsed slog.Level
+		if err := parsed.UnmarshalText([]byte(level)); err != nil {
+			return fmt.Errorf("invalid --log-level %q (want debug, info, warn or error)", level)
+		}
+		handlerOptions.Level = parsed
+	}
+	switch strings.ToLower(format) {
+	case "", "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(diagnosticOutput{}, handlerOptions)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(diagnosticOutput{}, handlerOptions)))
+	default:
+		return fmt.Errorf("invalid --log-format %q (want text or json)", format)
+	}
+	return nil
+}
+
+// diagnosticOutput writes diagnostics to stderr above the progress bar.
+type diagnosticOutput struct{}
+
+func (diagnosticOutput) Write(data []byte) (written int, err error) {
+	activeProgress.above(func() { written, err = os.Stderr.Write(data) })
+	return written, err
+}
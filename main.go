@@ -1,183 +1,925 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
 	"time"
 
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
 	rdap "github.com/openrdap/rdap"
-	"github.com/openrdap/rdap/bootstrap"
+	"golang.org/x/term"
 )
 
 func main() {
-	verbose := flag.Bool("v", false, "verbose: print full RDAP autnum JSON")
-	flag.Parse()
-	args := flag.Args()
-	if len(args) < 1 {
-		fmt.Println("usage: go run main.go [-v] <ASN> [ASN...]")
-		os.Exit(2)
+	run := runLookup
+	args := os.Args[1:]
+	if len(os.Args) > 1 {
+		if command, ok := subcommands[os.Args[1]]; ok {
+			run, args = command, os.Args[2:]
+		}
+	}
+	code := run(args)
+	for _, file := range flagFiles {
+		file.Close()
 	}
+	os.Exit(code)
+}
+
+// flagFiles are the files flag values opened, such as -dump-http=file and
+// -audit-log; they are kept open for the whole command and closed after
+// it.
+var flagFiles []io.Closer
 
-	for _, a := range args {
-		asn, err := strconv.ParseInt(a, 10, 64)
+// runLookup is the default batch mode: look up each ASN given on the command
+// line and print its organization name.
+func runLookup(commandLine []string) int {
+	flagSet := newFlagSet("rdap-test")
+	latencyReport := flagSet.Bool("latency", false, "print per-registry latency histograms and percentiles by query phase after the run")
+	var options clientOptions
+	options.registerFlags(flagSet)
+	watchInterval := flagSet.Duration("watch", 0, "re-query a single ASN at this interval, redrawing and highlighting changed fields")
+	var vantages vantageFlag
+	flagSet.Var(&vantages, "vantage", "relay lookups through a vantage point `name=socks5://host:port` (repeatable); results are tagged per vantage")
+	dryRun := flagSet.Bool("dry-run", false, "resolve the bootstrap decision and print the URLs that would be queried, without querying them")
+	explain := flagSet.Bool("explain", false, "explain the bootstrap decision (matched entry, chosen service URL, cache) for each target")
+	copyResult := flagSet.Bool("copy", false, "copy the result (the name, or all result lines) to the system clipboard")
+	copyJSON := flagSet.Bool("copy-json", false, "copy the full RDAP JSON of every result to the system clipboard")
+	var targetFiles targetFileFlag
+	flagSet.Var(&targetFiles, "f", "read targets from `file`, one or more per line (# starts a comment; - is stdin; repeatable). Without targets, they are read from stdin when it is not a terminal")
+	var lines lineRange
+	flagSet.IntVar(&lines.Start, "start-line", 0, "look up only the targets from this input `line` of the -f file or stdin on (1-based)")
+	flagSet.IntVar(&lines.End, "end-line", 0, "look up only the targets up to this input `line` of the -f file or stdin; reading stops after it")
+	paste := flagSet.Bool("paste", false, "read additional targets from the system clipboard")
+	overridesPath := flagSet.String("overrides", "", "`file` of \"ASN name\" lines whose names replace extracted ones (default ~/.config/rdap-tester/overrides if present)")
+	var skips skipList
+	flagSet.Var(&skips, "skip", "never query these ASNs or `ranges` (e.g. 64512-65534,4200000000-4294967294; repeatable)")
+	skipFile := flagSet.String("skip-file", "", "never query the ASNs and ranges listed in `file`, one per line")
+	labelSkipped := flagSet.Bool("label-skipped", false, "print a line for skipped targets instead of omitting them silently")
+	failOn := exitPolicy("never")
+	flagSet.Var(&failOn, "fail-on", "fail the run when any result is of this `class`: error, notfound (RDAP 404), any or never; a failed run exits with its lookups' error class status (3 not-found, 4 rate-limited, 5 timeout, 6 bootstrap-failure, 7 tls-error, 8 server-error, 9 network-error, 10 invalid-target) when they share one, else 1")
+	groupBy := flagSet.String("group-by", "", "collapse results into groups with counts and member lists: org, country or registry")
+	listNetworks := flagSet.Bool("list-networks", false, "list the networks each ASN originates, from ARIN's originas0 extension")
+	jsonOutput := flagSet.Bool("json", false, "emit one JSON object per result (asn, name, rir, handle, country, error, ...) instead of text lines")
+	rawOutput := flagSet.Bool("raw", false, "write the RDAP JSON of each result byte for byte as the server sent it, one document per result, instead of text lines")
+	jsonLines := flagSet.Bool("jsonl", false, "like -json, but write each result as soon as its lookup completes and flush it at once on every sink, for streaming consumers")
+	csvOutput := flagSet.Bool("csv", false, "emit results as CSV with a header row, one row per result")
+	csvFieldList := flagSet.String("fields", "", "with -csv, the comma-separated `columns`: asn, target, vantage, name, country, rir, handle, registered, error, error_class, http_status, error_code, source, class, server, port43, url, valid_until, line, attempts, failed_attempts, tags, warnings, abuse_email, abuse_phone (default "+defaultCSVFields+")")
+	formatText := flagSet.String("format", "", "render each result with this Go text/template `template`, e.g. '{{.ASN}} {{.Name}} {{.Country}}', over the fields of -json by their Go names")
+	includeRaw := flagSet.Bool("include-raw", false, "with -json, embed the untouched RDAP document of each result as \"raw\"")
+	statsFormat := flagSet.String("stats", "", "print end-of-run statistics as `human` text or json")
+	signingKey := flagSet.String("sign", "", "attach a detached signature over the SHA-256 of each raw RDAP response, signed with the PEM private key in `key.pem`")
+	ordered := flagSet.Bool("ordered", false, "guarantee exactly one output line per input target (per vantage), in input order, for joining results back by row")
+	var maxMemory byteSize
+	flagSet.Var(&maxMemory, "max-memory", "soft memory limit for the Go runtime, limit on the results held back for output in input order (workers wait for the earlier rows) and hard limit for results kept for -group-by/-copy, e.g. `512MiB`")
+	var sinkSpecs sinkFlag
+	flagSet.Var(&sinkSpecs, "sink", "send results to this `sink`: stdout, file:path or sqlite:path (repeatable; default stdout, or none with -group-by)")
+	sqlitePath := flagSet.String("sqlite", "", "also insert every result, with its raw RDAP JSON, into this SQLite database `file`, adding to earlier runs (needs the sqlite3 command)")
+	hookPath := flagSet.String("hook", "", "apply the tag, annotate, name and drop rules of this script `file` to every result before output")
+	var filter resultFilter
+	flagSet.Var(&filter, "filter", "only emit results matching this `expression`, e.g. 'country == \"RU\" || registry == \"ripe\"'")
+	followUps := flagSet.Int("follow-ups", rdaplookup.DefaultFollowUps, "dereference at most `n` linked entities per target, concurrently, when the record embeds no organization contact (0 disables)")
+	asOfText := flagSet.String("as-of", "", "answer from archive sources as of this `date` (2006-01-02 or RFC 3339) instead of querying registries live")
+	archives := archiveFlag{}
+	flagSet.Var(&archives, "archive", "archive `source` for -as-of: snapshots or an http(s) URL template with {asn}, {date}, {time} (repeatable; default snapshots)")
+	var classes classList
+	flagSet.Var(&classes, "classes", "try each target as these RDAP object `classes` in priority order, e.g. autnum,entity,ip, reporting which matched (autnum, ip, domain, nameserver, entity)")
+	domainMode := flagSet.Bool("domain", false, "look up domain names instead of ASNs: registrant organization, registrar, creation and expiry dates and status")
+	entityServerText := flagSet.String("entity-server", "", "ask this RDAP base `URL` for entity:handle targets instead of the server the object tag bootstrap registry names for the handle")
+	nsMode := flagSet.Bool("ns", false, "look up nameserver objects (handle, IP addresses, status) instead of ASNs; single targets can be written ns:name instead")
+	reverse := flagSet.Bool("reverse", false, "look up the reverse DNS domain (in-addr.arpa or ip6.arpa zone) of IP targets: its delegated nameservers and managing organization")
+	dedupWindow := flagSet.Duration("dedup-window", 0, "answer identical lookups starting within this `window` (e.g. 2s) of each other with one upstream request (0 disables)")
+	cacheTTL := flagSet.Duration("cache-ttl", defaultCacheTTL, "answer from the on-disk response cache when the cached answer is younger than this `age`")
+	noCache := flagSet.Bool("no-cache", false, "neither read nor write the on-disk response cache")
+	whoisFallbackMode := flagSet.Bool("whois-fallback", false, "when RDAP says an ASN, IP address or prefix does not exist or names no holder, ask port-43 WHOIS (the record's port43 server, the registry's or the one IANA refers to) and report its OrgName, org-name, descr or as-name")
+	offline := flagSet.Bool("offline", false, "answer from the on-disk response cache only, however old the answers, and send no request at all (e.g. with a bundle from \"cache import\")")
+	concurrency := flagSet.Int("concurrency", 1, "look up at most `n` targets at once; output stays in input order unless -unordered")
+	unordered := flagSet.Bool("unordered", false, "with -concurrency, write each result as soon as its lookup completes instead of in input order")
+	spread := flagSet.Duration("spread", 0, "start the batch's queries evenly across this `window` (e.g. 2h) instead of as fast as allowed")
+	quiet := flagSet.Bool("quiet", false, "do not show the progress bar of targets read from -f files or stdin (shown on stderr when it is a terminal)")
+	checkpointPath := flagSet.String("checkpoint", "", "record each target whose results are written in `file`, for -resume")
+	resume := flagSet.Bool("resume", false, "skip the targets the -checkpoint file records as done and add to its file sinks, continuing a crashed or interrupted run")
+	language := flagSet.String("lang", "", "language of human-facing output: en, de, es or fr (default from LC_ALL, LC_MESSAGES or LANG)")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . [flags] <ASN|start-end|IP|prefix|!!|!N> ...\n       go run . <command> [flags] ...")
+		flagSet.PrintDefaults()
+	}
+	args, err := parseInterspersed(flagSet, commandLine)
+	if err != nil {
+		return 2
+	}
+	messageLanguage = detectLanguage(*language)
+	if err := options.validate(); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if *skipFile != "" {
+		if err := skips.loadFile(*skipFile); err != nil {
+			fmt.Printf("-skip-file: %v\n", err)
+			return 2
+		}
+	}
+	overridesOptional := *overridesPath == ""
+	if overridesOptional {
+		*overridesPath, _ = defaultOverridesPath()
+	}
+	overrides, err := loadOverrides(*overridesPath, overridesOptional)
+	if err != nil {
+		fmt.Printf("-overrides: %v\n", err)
+		return 2
+	}
+	var hooks resultHooks
+	if *hookPath != "" {
+		if hooks, err = loadHooks(*hookPath); err != nil {
+			fmt.Printf("-hook: %v\n", err)
+			return 2
+		}
+	}
+	var signer *provenanceSigner
+	if *signingKey != "" {
+		if signer, err = loadProvenanceSigner(*signingKey); err != nil {
+			fmt.Printf("-sign: %v\n", err)
+			return 2
+		}
+	}
+	var asOf time.Time
+	if *asOfText != "" {
+		if asOf, err = parseAsOf(*asOfText); err != nil {
+			fmt.Println(err)
+			return 2
+		}
+		if len(archives) == 0 {
+			archives = archiveFlag{"snapshots"}
+		}
+	}
+	if *jsonLines {
+		if *ordered {
+			fmt.Println("-jsonl writes results as they complete and cannot be combined with -ordered")
+			return 2
+		}
+		*jsonOutput, *unordered = true, true
+	}
+	if *rawOutput && (*jsonOutput || *csvOutput || *formatText != "" || *dryRun || *explain) {
+		fmt.Println("-raw cannot be combined with -json, -jsonl, -csv, -format, -dry-run or -explain")
+		return 2
+	}
+	if *includeRaw && !*jsonOutput {
+		fmt.Println("-include-raw requires -json")
+		return 2
+	}
+	if *csvFieldList != "" && !*csvOutput {
+		fmt.Println("-fields requires -csv")
+		return 2
+	}
+	var csvFields []string
+	if *csvOutput {
+		if *csvFieldList == "" {
+			*csvFieldList = defaultCSVFields
+		}
+		if *jsonOutput || *dryRun || *explain {
+			fmt.Println("-csv cannot be combined with -json, -dry-run or -explain")
+			return 2
+		}
+		if csvFields, err = parseCSVFields(*csvFieldList); err != nil {
+			fmt.Println(err)
+			return 2
+		}
+	}
+	var resultTemplate *template.Template
+	if *formatText != "" {
+		if *jsonOutput || *csvOutput || *dryRun || *explain {
+			fmt.Println("-format cannot be combined with -json, -csv, -dry-run or -explain")
+			return 2
+		}
+		if resultTemplate, err = parseResultTemplate(*formatText); err != nil {
+			fmt.Println(err)
+			return 2
+		}
+	}
+	if *jsonOutput && (*dryRun || *explain) {
+		fmt.Println("-json cannot be combined with -dry-run or -explain, which print plans rather than results")
+		return 2
+	}
+	if *cacheTTL <= 0 {
+		fmt.Println("-cache-ttl must be positive; use -no-cache to bypass the cache")
+		return 2
+	}
+	if *offline && *noCache {
+		fmt.Println("-offline answers from the response cache and cannot be combined with -no-cache")
+		return 2
+	}
+	if *offline && *whoisFallbackMode {
+		fmt.Println("-whois-fallback queries WHOIS servers and cannot be combined with -offline")
+		return 2
+	}
+	options.Offline = *offline
+	if *concurrency < 1 {
+		fmt.Println("-concurrency must be at least 1")
+		return 2
+	}
+	if *concurrency > 1 && (*dryRun || *explain) {
+		fmt.Println("-concurrency cannot be combined with -dry-run or -explain, which print as they go")
+		return 2
+	}
+	if *unordered && *ordered {
+		fmt.Println("-unordered cannot be combined with -ordered")
+		return 2
+	}
+	if *resume && *checkpointPath == "" {
+		fmt.Println("-resume needs the -checkpoint file of the run to resume")
+		return 2
+	}
+	if *checkpointPath != "" && (*dryRun || *explain || *watchInterval > 0) {
+		fmt.Println("-checkpoint cannot be combined with -dry-run, -explain or -watch")
+		return 2
+	}
+	appendToFileSinks = *resume
+	if *spread > 0 && (*dryRun || *explain || *watchInterval > 0) {
+		fmt.Println("-spread cannot be combined with -dry-run, -explain or -watch")
+		return 2
+	}
+	if len(classes) > 0 && (*domainMode || *dryRun || *explain || *asOfText != "" || *watchInterval > 0) {
+		fmt.Println("-classes cannot be combined with -domain, -dry-run, -explain, -as-of or -watch")
+		return 2
+	}
+	var entityServer *url.URL
+	if *entityServerText != "" {
+		if entityServer, err = url.Parse(*entityServerText); err != nil || (entityServer.Scheme != "http" && entityServer.Scheme != "https") || entityServer.Host == "" {
+			fmt.Printf("-entity-server: invalid base URL %q\n", *entityServerText)
+			return 2
+		}
+		if !strings.HasSuffix(entityServer.Path, "/") {
+			entityServer.Path += "/"
+		}
+	}
+	if *nsMode && (*domainMode || len(classes) > 0 || *reverse || *dryRun || *explain || *asOfText != "" || *watchInterval > 0) {
+		fmt.Println("-ns cannot be combined with -domain, -classes, -reverse, -dry-run, -explain, -as-of or -watch")
+		return 2
+	}
+	if *reverse && (*domainMode || len(classes) > 0 || *dryRun || *explain || *asOfText != "" || *watchInterval > 0) {
+		fmt.Println("-reverse cannot be combined with -domain, -classes, -dry-run, -explain, -as-of or -watch")
+		return 2
+	}
+	if *domainMode && (*dryRun || *explain || *asOfText != "" || *watchInterval > 0) {
+		fmt.Println("-domain cannot be combined with -dry-run, -explain, -as-of or -watch, which look up ASNs only")
+		return 2
+	}
+	if *ordered {
+		if filter.evaluate != nil || *hookPath != "" || *groupBy != "" || *dryRun || *explain {
+			fmt.Println("-ordered cannot be combined with -filter, -hook, -group-by, -dry-run or -explain, which drop or add lines")
+			return 2
+		}
+		*labelSkipped = true
+	}
+	switch *statsFormat {
+	case "", "human", "json":
+	default:
+		fmt.Printf("-stats: unknown format %q (want human or json)\n", *statsFormat)
+		return 2
+	}
+	switch *groupBy {
+	case "", "org", "country", "registry":
+	default:
+		fmt.Printf("-group-by: unknown grouping %q (want org, country or registry)\n", *groupBy)
+		return 2
+	}
+	if lines.Start < 0 || lines.End < 0 || (lines.End > 0 && lines.End < lines.Start) {
+		fmt.Println("-start-line and -end-line must be positive, with -end-line not before -start-line")
+		return 2
+	}
+	readsStdin := len(args) == 0 && len(targetFiles) == 0 && !*paste && !term.IsTerminal(int(os.Stdin.Fd()))
+	if lines.set() && !(len(targetFiles) == 1 || readsStdin) {
+		fmt.Println("-start-line and -end-line need the targets of exactly one -f file or stdin")
+		return 2
+	}
+	// targetLines holds the input line each target was read from, 0 for
+	// targets given as arguments or pasted.
+	targetLines := make([]int, len(args))
+	commandLineTargets := len(args)
+	for _, path := range targetFiles {
+		fileTargets, fileLines, err := readTargetLinesFile(path, lines)
 		if err != nil {
-			fmt.Printf("%s: invalid ASN: %v\n", a, err)
-			continue
+			fmt.Printf("-f: %v\n", err)
+			return 2
+		}
+		args = append(args, fileTargets...)
+		targetLines = append(targetLines, fileLines...)
+	}
+	if readsStdin {
+		if args, targetLines, err = readTargetLines(os.Stdin, lines); err != nil {
+			fmt.Printf("stdin: %v\n", err)
+			return 2
 		}
-		name, err := rdapASNLookup(asn, *verbose)
+	}
+	if *paste {
+		text, err := readClipboard()
 		if err != nil {
-			fmt.Printf("AS%d: error: %v\n", asn, err)
-			continue
+			fmt.Printf("-paste: %v\n", err)
+			return 2
 		}
-		if name == "" {
-			fmt.Printf("AS%d: (no name found)\n", asn)
-		} else {
-			fmt.Printf("AS%d: %s\n", asn, name)
+		pasted, ignored := clipboardTargets(text)
+		if ignored > 0 {
+			slog.Info("ignored clipboard tokens that are not ASNs", "count", ignored)
 		}
+		args = append(args, pasted...)
+		targetLines = append(targetLines, make([]int, len(pasted))...)
 	}
-}
-
-func rdapASNLookup(asn int64, verbose bool) (string, error) {
-	if asn <= 0 {
-		return "", fmt.Errorf("invalid ASN: %d", asn)
+	// Only targets given as arguments recall the history; a "!" in a file
+	// or on stdin is taken as written.
+	for index, arg := range args[:commandLineTargets] {
+		if strings.HasPrefix(arg, "!") {
+			target, err := recallHistory(arg)
+			if err != nil {
+				fmt.Printf("%s: %v\n", arg, err)
+				return 2
+			}
+			slog.Info("recalled from history", "reference", arg, "target", target)
+			args[index] = target
+		}
 	}
-	// Skip private ASN range (RFC 6996)
-	if asn >= 64512 && asn <= 65535 {
-		return "Private ASN", nil
+	if !*domainMode {
+		if args, targetLines, err = expandASNRanges(args, targetLines); err != nil {
+			fmt.Println(err)
+			return 2
+		}
+	}
+	if *resume {
+		done, err := loadCheckpoint(*checkpointPath)
+		if err != nil {
+			fmt.Printf("-resume: %v\n", err)
+			return 2
+		}
+		remaining, remainingLines := args[:0:0], targetLines[:0:0]
+		for index, target := range args {
+			if !done[target] {
+				remaining, remainingLines = append(remaining, target), append(remainingLines, targetLines[index])
+			}
+		}
+		if len(remaining) == 0 && len(args) > 0 {
+			fmt.Printf("-resume: all %d targets are done according to %s\n", len(args), *checkpointPath)
+			return 0
+		}
+		slog.Info("resuming", "checkpoint", *checkpointPath, "done", len(args)-len(remaining), "remaining", len(remaining))
+		args, targetLines = remaining, remainingLines
+	}
+	if len(args) < 1 {
+		fmt.Printf(localize("usage: %s")+"\n", "go run . [-lang code] [-v|-vv|-vvv] [-latency] [-dry-run] [-explain] [-copy|-copy-json] [-f file] [-paste] [-skip ranges] [-skip-file file] [-fail-on class] [-group-by org|country|registry] [-stats human|json] [-4|-6] [-watch interval] <ASN> [ASN...]")
+		return 2
 	}
 
-	httpClient := &http.Client{Timeout: 6 * time.Second}
-	client := &rdap.Client{HTTP: httpClient, Bootstrap: &bootstrap.Client{}}
+	if *watchInterval > 0 {
+		if !asOf.IsZero() {
+			fmt.Println("-watch cannot be combined with -as-of")
+			return 2
+		}
+		if len(args) != 1 {
+			fmt.Println(localize("-watch takes exactly one ASN"))
+			return 2
+		}
+		asn, err := parseASN(args[0])
+		if err != nil {
+			fmt.Printf(localize("%s: invalid ASN: %v")+"\n", args[0], err)
+			return 2
+		}
+		watchTarget(newRDAPClient(options), asn, *watchInterval)
+		return 0
+	}
 
-	// Try both "AS12345" and "12345" formats
-	queryFormats := []string{"AS" + strconv.FormatInt(asn, 10), strconv.FormatInt(asn, 10)}
-	var lastErr error
+	var latencies *latencyRecorder
+	var tracer func(vantage string) func(*http.Request, requestTimings)
+	if *latencyReport {
+		latencies = newLatencyRecorder()
+		tracer = func(vantage string) func(*http.Request, requestTimings) {
+			if len(vantages) == 0 {
+				return latencies.observe
+			}
+			return latencies.observerFor(vantage)
+		}
+	}
+	var stats *runStats
+	if *statsFormat != "" {
+		stats = newRunStats()
+		options.Stats = stats
+	}
+	clients := newVantageClients(options, vantages, tracer)
+	whois := newWhoisClient(options)
+	if len(sinkSpecs) == 0 && *groupBy == "" {
+		sinkSpecs = sinkFlag{"stdout"}
+	}
+	if *sqlitePath != "" {
+		sinkSpecs = append(sinkSpecs, "sqlite:"+*sqlitePath)
+	}
+	format := sinkFormat{JSON: *jsonOutput, Stream: *jsonLines, Raw: *rawOutput, IncludeRaw: *includeRaw, CSVFields: csvFields, Template: resultTemplate, Contacts: options.Verbosity >= verboseExtraction}
+	sinks, err := openSinks(sinkSpecs, format)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
 
-	for _, queryString := range queryFormats {
-		autnumRecord, err := client.QueryAutnum(queryString)
+	applyMemoryLimit(maxMemory)
+	retention := &resultRetention{keepRawBodies: *copyJSON, limit: maxMemory}
+	retainResults := *groupBy != "" || *copyResult || *copyJSON
+	// failed is set by errors other than lookups failing -fail-on, whose
+	// error classes are collected in failedClasses.
+	failed := false
+	failedClasses := map[string]bool{}
+	// Targets given as arguments are few; dry runs and explanations print
+	// as they go.
+	var progress *progressBar
+	if (len(targetFiles) > 0 || readsStdin || *paste) && len(args) > 1 && !*dryRun && !*explain {
+		progress = startProgress(len(args), *quiet)
+	}
+	// writeToSinks sends result to every sink. A result that is not
+	// rendered or written fails the run, which also stops -checkpoint from
+	// listing this and later rows as done.
+	writeToSinks := func(result lookupResult) {
+		converted, err := sinkResult(result, format)
 		if err != nil {
-			lastErr = err
-			continue
+			slog.Error("result not rendered", "target", result.Target, "error", err)
+			failed = true
+			return
 		}
-		if autnumRecord == nil {
-			lastErr = fmt.Errorf("nil RDAP autnum response for %s", queryString)
-			continue
+		var errs []error
+		progress.above(func() {
+			for _, opened := range sinks {
+				if err := opened.Write(converted); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		})
+		// Logged outside above, which the logger itself goes through.
+		for _, err := range errs {
+			slog.Error("sink write failed", "target", result.Target, "error", err)
+			failed = true
 		}
-
-		if verbose {
-			if jsonBytes, err := json.MarshalIndent(autnumRecord, "", "  "); err == nil {
-				fmt.Printf("RDAP autnum for %s:\n%s\n", queryString, string(jsonBytes))
+		if *rawOutput && (result.Fetch == nil || len(result.Fetch.RawBody) == 0) {
+			slog.Warn("no RDAP response to pass through", "result", result.line())
+		}
+	}
+	output := newOrderedOutput(writeToSinks)
+	coalescer := newLookupCoalescer(*dedupWindow)
+	responses := newResponseCache(*cacheTTL, *noCache, *offline)
+	schedule := newSpreadSchedule(*spread, len(args))
+	// SIGINT or SIGTERM cancels in-flight queries and stops dispatching
+	// rows; the rows completed so far are still written and flushed. A
+	// second signal kills the process as usual.
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	context.AfterFunc(ctx, stopSignals)
+	// recordDone checkpoints a row whose results are written. Rows cut short
+	// by an interrupt are left for -resume.
+	recordDone := func(row int) {}
+	// recordFlushed checkpoints the rows a batched sink committed only with
+	// the final flush.
+	recordFlushed := func() {}
+	if *checkpointPath != "" {
+		done, err := openCheckpoint(*checkpointPath, *resume, sinks)
+		if err != nil {
+			fmt.Printf("-checkpoint: %v\n", err)
+			flushSinks(sinks)
+			return 2
+		}
+		defer done.Close()
+		recordDone = func(row int) {
+			if ctx.Err() != nil || failed {
+				return
+			}
+			if err := done.record(args[row]); err != nil {
+				slog.Error("checkpoint not updated", "target", args[row], "error", err)
+				failed = true
 			}
 		}
-
-		if organizationName := extractAutnumName(autnumRecord); organizationName != "" {
-			return organizationName, nil
+		output.released = recordDone
+		recordFlushed = func() {
+			if failed {
+				return
+			}
+			if err := done.recordHeld(); err != nil {
+				slog.Error("checkpoint not updated", "error", err)
+				failed = true
+			}
 		}
-		return "", nil
 	}
-
-	if lastErr != nil {
-		return "", lastErr
+	// lookupRow looks up one input row on every vantage point. It runs on
+	// up to -concurrency workers at once, so it reports whether the row
+	// fails the run and which results to retain instead of recording them.
+	lookupRow := func(clients []vantageClient, row int, a string) (rowResults, retained []lookupResult, rowFailures []string) {
+		reject := func(result lookupResult) ([]lookupResult, []lookupResult, []string) {
+			return []lookupResult{result}, nil, failOn.failures(result)
+		}
+		var asn int64
+		var target string
+		var network, nameserver, entity bool
+		var err error
+		if handle, prefixed := entityTarget(a); prefixed {
+			if _, ok := classQuery("entity", handle); !ok {
+				return reject(lookupResult{Target: a, Label: a, Err: fmt.Errorf("%q is not an entity handle", handle)})
+			}
+			target, entity = handle, true
+		} else if name, prefixed := nameserverTarget(a); *nsMode || prefixed {
+			if !prefixed {
+				name = a
+			}
+			if target, err = parseDomainName(name); err != nil {
+				return reject(lookupResult{Target: a, Label: a, Err: &invalidTargetError{Err: err, Domain: true}})
+			}
+			nameserver = true
+		} else if *domainMode {
+			if target, err = parseDomainName(a); err != nil {
+				return reject(lookupResult{Target: a, Label: a, Err: &invalidTargetError{Err: err, Domain: true}})
+			}
+		} else if *reverse {
+			if target, network = parseNetworkTarget(a); !network {
+				return reject(lookupResult{Target: a, Label: a, Err: fmt.Errorf("%q is not an IP address or prefix", a)})
+			}
+		} else if len(classes) > 0 {
+			// Each class parses the target itself; see classQuery.
+			target = a
+			if parsed, err := parseASN(a); err == nil && skips.contains(parsed) {
+				if *labelSkipped {
+					rowResults = append(rowResults, lookupResult{Target: target, Label: target, Skipped: true})
+				}
+				return rowResults, nil, nil
+			}
+		} else if target, network = parseNetworkTarget(a); !network {
+			if asn, err = parseASN(a); err != nil {
+				return reject(lookupResult{Target: a, Label: a, Err: &invalidTargetError{Err: err}})
+			}
+			target = fmt.Sprintf("AS%d", asn)
+			if skips.contains(asn) {
+				if *labelSkipped {
+					rowResults = append(rowResults, lookupResult{Target: target, Label: target, Skipped: true})
+				}
+				return rowResults, nil, nil
+			}
+		}
+		schedule.wait(ctx, row)
+		for _, vantage := range clients {
+			label := target
+			if len(vantages) > 0 {
+				label += " [" + vantage.Vantage + "]"
+			}
+			if (network || nameserver || entity) && (*explain || *dryRun || !asOf.IsZero()) {
+				result := lookupResult{Target: target, Label: label, Vantage: vantage.Vantage,
+					Err: fmt.Errorf("-explain, -dry-run and -as-of support ASN targets only")}
+				rowResults = append(rowResults, result)
+				rowFailures = append(rowFailures, failOn.failures(result)...)
+				continue
+			}
+			if *explain {
+				if err := explainBootstrap(vantage, asn, label); err != nil {
+					fmt.Printf(localize("%s: error: %v")+"\n", label, err)
+					continue
+				}
+			}
+			if *dryRun {
+				if err := printDryRun(vantage, asn, label); err != nil {
+					fmt.Printf(localize("%s: error: %v")+"\n", label, err)
+				}
+				continue
+			}
+			var bootstrapDownloads int
+			if stats != nil {
+				bootstrapDownloads = stats.bootstrapDownloadCount()
+			}
+			lookupStart := time.Now()
+			var result lookupResult
+			if asOf.IsZero() {
+				key := vantage.Vantage + "|" + target
+				if nameserver {
+					key = vantage.Vantage + "|ns:" + target
+				}
+				if entity {
+					key = vantage.Vantage + "|entity:" + target
+				}
+				result = coalescer.do(key, func() lookupResult {
+					switch {
+					case entity:
+						return responses.lookup(vantage.Vantage, "entity", target, func() lookupResult {
+							return rdapEntityLookup(ctx, vantage.Client, target, entityServer)
+						})
+					case nameserver:
+						return responses.lookup(vantage.Vantage, "nameserver", target, func() lookupResult {
+							return rdapNameserverLookup(ctx, vantage.Client, target)
+						})
+					case len(classes) > 0:
+						return lookupClasses(target, classes, func(class, query string) lookupResult {
+							return responses.lookup(vantage.Vantage, class, query, func() lookupResult {
+								return lookupClass(ctx, vantage.Client, class, query, options.Verbosity, *followUps)
+							})
+						})
+					case *reverse:
+						return responses.lookup(vantage.Vantage, "reverse", target, func() lookupResult {
+							return rdapReverseLookup(ctx, vantage.Client, target)
+						})
+					case *domainMode:
+						return responses.lookup(vantage.Vantage, "domain", target, func() lookupResult {
+							return rdapDomainLookup(ctx, vantage.Client, target)
+						})
+					case network:
+						result := responses.lookup(vantage.Vantage, "ip", target, func() lookupResult {
+							return rdapNetworkLookup(ctx, vantage.Client, target)
+						})
+						if *whoisFallbackMode && needsWhoisFallback(result) {
+							result = responses.lookup(vantage.Vantage, "whois", target, func() lookupResult {
+								return whoisFallback(ctx, whois, result, target)
+							})
+						}
+						return result
+					default:
+						result := responses.lookup(vantage.Vantage, "autnum", strconv.FormatInt(asn, 10), func() lookupResult {
+							name, fetch, err := rdapASNLookup(ctx, vantage.Client, asn, options.Verbosity, *followUps)
+							return lookupResult{Name: name, Fetch: fetch, Err: err}
+						})
+						if *whoisFallbackMode && needsWhoisFallback(result) {
+							result = responses.lookup(vantage.Vantage, "whois", target, func() lookupResult {
+								return whoisFallback(ctx, whois, result, target)
+							})
+						}
+						return result
+					}
+				})
+			} else {
+				result.Name, result.Fetch, result.Historical, result.Err = historicalLookup(ctx, vantage.Client.HTTP, archives, asn, asOf)
+			}
+			if ctx.Err() != nil {
+				// Interrupted: the row is dropped rather than reported as failed.
+				return nil, nil, nil
+			}
+			// Only targets given as arguments or pasted are recorded, not
+			// every row of a -f file or stdin batch.
+			if asOf.IsZero() && targetLines[row] == 0 {
+				recordHistory("lookup", target, result.Name, result.Err)
+			}
+			lookupDuration := time.Since(lookupStart)
+			result.Target, result.Label, result.Vantage, result.Attempts = target, label, vantage.Vantage, result.Fetch.attempts()
+			if *listNetworks && asn != 0 && result.Err == nil && result.Fetch != nil {
+				if result.OriginNetworks, err = listOriginNetworks(ctx, vantage.Client.HTTP, result.Fetch, asn); err != nil {
+					slog.Warn("listing originated networks failed", "target", result.Target, "error", err)
+				}
+			}
+			if override, ok := overrides[result.Target]; ok && result.Err == nil {
+				result.Name, result.Overridden = override, true
+			}
+			if signer != nil && result.Err == nil && result.Fetch != nil && result.Fetch.URL != "" {
+				var signErr error
+				if result.Provenance, signErr = signer.sign(result.Target, result.Fetch.URL, result.Fetch.RawBody, lookupStart); signErr != nil {
+					slog.Error("signing failed", "target", result.Target, "error", signErr)
+				}
+			}
+			if stats != nil {
+				stats.addLookup(result, lookupDuration, stats.bootstrapDownloadCount() > bootstrapDownloads)
+			}
+			rowFailures = append(rowFailures, failOn.failures(result)...)
+			if !hooks.apply(&result) || !filter.matches(result) {
+				continue
+			}
+			rowResults = append(rowResults, result)
+			if retainResults {
+				retained = append(retained, result)
+			}
+		}
+		return rowResults, retained, rowFailures
 	}
-	return "", nil
-}
 
-func extractAutnumName(autnumRecord *rdap.Autnum) string {
-	if autnumRecord == nil {
-		return ""
+	if *concurrency > 1 && !*offline {
+		warmBootstrap(clients, args, *domainMode || *nsMode)
 	}
-
-	// Step 1: Look for an organization vCard with kind="org" and extract its formatted name (fn)
-	for _, entity := range autnumRecord.Entities {
-		if organizationName := getOrgNameFromVCard(entity.VCard); organizationName != "" {
-			return shortenTo40Chars(organizationName)
+	var collect sync.Mutex
+	// emitted is signaled when ordered output was released or the run
+	// stopped.
+	emitted := sync.NewCond(&collect)
+	stopped := false
+	// finish records a looked-up row and hands it to the output, in input
+	// order or, with -unordered, as soon as it completes.
+	finish := func(row int, rowResults, retained []lookupResult, rowFailures []string) {
+		collect.Lock()
+		defer collect.Unlock()
+		if stopped {
+			return
 		}
+		for _, class := range rowFailures {
+			failedClasses[class] = true
+		}
+		progress.add(rowResults)
+		for _, result := range retained {
+			if err := retention.retain(result); err != nil {
+				fmt.Println(err)
+				failed, stopped = true, true
+				emitted.Broadcast()
+				return
+			}
+		}
+		if *unordered {
+			for _, result := range rowResults {
+				writeToSinks(result)
+			}
+			recordDone(row)
+			return
+		}
+		output.complete(row, rowResults)
+		emitted.Broadcast()
 	}
-
-	// Step 2: Fall back to remarks with title "description" (common in APNIC records)
-	for _, remark := range autnumRecord.Remarks {
-		if strings.EqualFold(strings.TrimSpace(remark.Title), "description") && len(remark.Description) > 0 {
-			if description := strings.TrimSpace(remark.Description[0]); description != "" {
-				return shortenTo40Chars(description)
+	// admit holds the next row back while the results waiting for an
+	// earlier row exceed -max-memory, so a slow row cannot make the output
+	// buffer grow without bound, and reports whether rows may still start.
+	// The earlier rows were all dispatched, so the wait ends.
+	admit := func() bool {
+		collect.Lock()
+		defer collect.Unlock()
+		for maxMemory > 0 && output.buffered > int64(maxMemory) && !stopped {
+			emitted.Wait()
+		}
+		return !stopped
+	}
+	rows := make(chan int)
+	var workers sync.WaitGroup
+	for range max(*concurrency, 1) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			clients := clients
+			if *concurrency > 1 {
+				clients = workerClients(clients)
+			}
+			for row := range rows {
+				rowResults, retained, rowFailures := lookupRow(clients, row, args[row])
+				for index := range rowResults {
+					rowResults[index].Line = targetLines[row]
+				}
+				for index := range retained {
+					retained[index].Line = targetLines[row]
+				}
+				finish(row, rowResults, retained, rowFailures)
 			}
+		}()
+	}
+dispatch:
+	for row := range args {
+		if !admit() {
+			break
+		}
+		select {
+		case rows <- row:
+		case <-ctx.Done():
+			break dispatch
 		}
 	}
+	close(rows)
+	workers.Wait()
+	progress.finish()
+	interrupted := ctx.Err() != nil
+	if interrupted {
+		slog.Warn("interrupted; writing the results completed so far")
+	}
 
-	// Step 3: Try any remark description as a fallback
-	for _, remark := range autnumRecord.Remarks {
-		if len(remark.Description) > 0 {
-			if description := strings.TrimSpace(remark.Description[0]); description != "" {
-				return shortenTo40Chars(description)
-			}
+	if err := flushSinks(sinks); err != nil {
+		slog.Error("sink flush failed", "error", err)
+		failed = true
+	}
+	recordFlushed()
+	if *groupBy != "" {
+		writeGroups(os.Stdout, groupResults(retention.results, *groupBy))
+	}
+	if *copyResult || *copyJSON {
+		text, err := clipboardText(retention.results, *copyJSON)
+		if err == nil {
+			err = copyToClipboard(text)
+		}
+		if err != nil {
+			slog.Error("copy to clipboard failed", "error", err)
 		}
 	}
 
-	// Step 4: Last resorts - use the RDAP name field or handle
-	if name := strings.TrimSpace(autnumRecord.Name); name != "" {
-		return shortenTo40Chars(name)
+	if latencies != nil {
+		latencies.writeReport(os.Stdout)
+	}
+	if stats != nil {
+		stats.writeStats(os.Stdout, *statsFormat)
+	}
+	if interrupted {
+		return 130
+	}
+	if failed {
+		return 1
 	}
-	if handle := strings.TrimSpace(autnumRecord.Handle); handle != "" {
-		return shortenTo40Chars(handle)
+	if len(failedClasses) > 0 {
+		return failureExitCode(failedClasses)
 	}
+	return 0
+}
 
-	return ""
+// subcommands maps the first command-line argument to the handler for that mode.
+// Each handler parses its own flags and returns the process exit code.
+var subcommands = map[string]func(args []string) int{
+	"canary":           runCanary,
+	"mock-server":      runMockServer,
+	"capabilities":     runCapabilities,
+	"matrix":           runMatrix,
+	"conformance":      runConformance,
+	"healthcheck":      runHealthcheck,
+	"tui":              runTUI,
+	"history":          runHistory,
+	"diff":             runDiff,
+	"budget":           runBudget,
+	"self-update":      runSelfUpdate,
+	"report":           runReport,
+	"profile":          runProfile,
+	"pipeline":         runPipeline,
+	"cache":            runCache,
+	"sweep":            runSweep,
+	"conformance-diff": runConformanceDiff,
+	"audit":            runAudit,
+	"plan":             runPlan,
+	"serve":            runServe,
+	"selftest":         runSelftest,
+	"watch":            runWatch,
 }
 
-func getOrgNameFromVCard(vcard *rdap.VCard) string {
-	if vcard == nil || len(vcard.Properties) == 0 {
-		return ""
+// parseASN accepts "15169", "AS15169" or "as15169" and returns the numeric ASN
+func parseASN(text string) (int64, error) {
+	text = strings.TrimSpace(text)
+	if len(text) > 2 && strings.EqualFold(text[:2], "AS") {
+		text = text[2:]
 	}
+	return strconv.ParseInt(text, 10, 64)
+}
 
-	// First pass: Check if this vCard represents an organization (kind="org")
-	isOrganization := false
-	for _, property := range vcard.Properties {
-		if strings.EqualFold(property.Name, "kind") {
-			values := property.Values()
-			if len(values) > 0 {
-				kindValue := strings.ToLower(strings.TrimSpace(values[len(values)-1]))
-				if strings.Contains(kindValue, "org") {
-					isOrganization = true
-					break
-				}
-			}
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments (e.g. "canary AS15169 --interval 1h") and returns the
+// positional arguments in order. Flags not on the command line then take
+// their config file or environment value.
+func parseInterspersed(flagSet *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flagSet.Parse(args); err != nil {
+			return nil, err
 		}
+		args = flagSet.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
-
-	// If this isn't an organization vCard, skip it
-	if !isOrganization {
-		return ""
+	if err := applyConfigDefaults(flagSet); err != nil {
+		fmt.Fprintln(flagSet.Output(), err)
+		return nil, err
 	}
+	return positional, nil
+}
 
-	// Second pass: Extract the formatted name (fn) from the organization vCard
-	for _, property := range vcard.Properties {
-		if strings.EqualFold(property.Name, "fn") {
-			values := property.Values()
-			// Search from the end of values array backwards for the first non-empty value
-			for i := len(values) - 1; i >= 0; i-- {
-				if formattedName := strings.TrimSpace(values[i]); formattedName != "" {
-					return formattedName
-				}
-			}
+// rdapASNLookup returns the extracted name for asn together with the last
+// HTTP exchange made (nil if none happened, e.g. for private ASNs), through
+// rdaplookup with the CLI's custom endpoints, verbosity and snapshots. When
+// the record has no organization vCard, up to followUps referenced entities
+// are dereferenced concurrently before falling back to remarks.
+func rdapASNLookup(ctx context.Context, client *rdap.Client, asn int64, verbosity, followUps int) (string, *autnumFetch, error) {
+	ctx, chain := withRedirectChain(ctx)
+	ctx, retried := withAttemptLog(ctx)
+	lookup := &rdaplookup.Client{RDAP: client, FollowUps: followUps, Route: routeRequest}
+	if verbosity >= verboseExtraction {
+		lookup.Logger = slog.Default()
+	}
+	result, err := lookup.Lookup(ctx, asn)
+	if result == nil || result.Private {
+		return resultName(result), nil, err
+	}
+	if verbosity >= verboseExtraction && chain.redirectCount() > 0 {
+		chain.logHops(fmt.Sprintf("AS%d", asn))
+	}
+	var fetch *autnumFetch
+	if result.Exchange != nil {
+		fetch = &autnumFetch{Record: result.Record, RawBody: result.RawBody, URL: result.URL, Redirects: chain,
+			Attempts: retried.merge(result.Attempts), ValidUntil: validUntil(result.Exchange, time.Now())}
+	}
+	if err != nil {
+		return "", fetch, err
+	}
+	if verbosity >= verboseHTTP {
+		if jsonBytes, err := json.Marshal(result.Record); err == nil {
+			slog.Debug("rdap autnum", "asn", asn, "record", json.RawMessage(jsonBytes))
 		}
 	}
-
-	return ""
+	saveSnapshot(fmt.Sprintf("AS%d", asn), result.RawBody)
+	return result.Name, fetch, nil
 }
 
-// shortenTo40Chars trims whitespace and limits the string to 40 Unicode characters (runes)
-func shortenTo40Chars(text string) string {
-	runes := []rune(text)
-	if len(runes) > 40 {
-		return string(runes[:40])
-	}
-	text = strings.TrimSpace(text)
-	if text == "" {
-		return text
+func resultName(result *rdaplookup.Result) string {
+	if result == nil {
+		return ""
 	}
-	return text
+	return result.Name
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultMatrixEndpoints are the RIR /help URLs exercised when no endpoints are given.
+var defaultMatrixEndpoints = []string{
+	"https://rdap.arin.net/registry/help",
+	"https://rdap.db.ripe.net/help",
+	"https://rdap.apnic.net/help",
+	"https://rdap.lacnic.net/rdap/help",
+	"https://rdap.afrinic.net/rdap/help",
+}
+
+// transportCombination is one cell of the protocol/transport matrix.
+type transportCombination struct {
+	Protocol string // "HTTP/1.1" or "HTTP/2"
+	Family   string // "IPv4" or "IPv6"
+}
+
+var transportMatrix = []transportCombination{
+	{"HTTP/1.1", "IPv4"},
+	{"HTTP/1.1", "IPv6"},
+	{"HTTP/2", "IPv4"},
+	{"HTTP/2", "IPv6"},
+}
+
+func runMatrix(args []string) int {
+	flagSet := newFlagSet("matrix")
+	timeout := flagSet.Duration("timeout", 10*time.Second, "timeout per request")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . matrix [flags] [endpoint-url...]")
+		fmt.Fprintln(flagSet.Output(), "Requests each URL over HTTP/1.1 and HTTP/2, IPv4 and IPv6. Defaults to the RIR /help endpoints.")
+		flagSet.PrintDefaults()
+	}
+	endpoints, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	if len(endpoints) == 0 {
+		endpoints = defaultMatrixEndpoints
+	}
+
+	failures := 0
+	for _, endpoint := range endpoints {
+		fmt.Printf("%s\n", endpoint)
+		for _, combination := range transportMatrix {
+			outcome, latency, err := probeTransport(endpoint, combination, *timeout)
+			if err != nil {
+				failures++
+				fmt.Printf("  %-8s %-4s  FAIL  %s\n", combination.Protocol, combination.Family, err)
+				continue
+			}
+			fmt.Printf("  %-8s %-4s  ok    %-28s %s\n", combination.Protocol, combination.Family, outcome, formatMillis(latency))
+		}
+	}
+	if failures > 0 {
+		return 1
+	}
+	return 0
+}
+
+// probeTransport fetches rdapURL over a fresh transport restricted to one
+// protocol and address family. outcome describes the status and negotiated
+// protocol; a protocol mismatch is reported as an error.
+func probeTransport(rdapURL string, combination transportCombination, timeout time.Duration) (string, time.Duration, error) {
+	network := "tcp4"
+	if combination.Family == "IPv6" {
+		network = "tcp6"
+	}
+	transport := &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		DialContext:       dialerForNetwork(network),
+		DisableKeepAlives: true,
+		Protocols:         new(http.Protocols),
+	}
+	if combination.Protocol == "HTTP/2" {
+		transport.Protocols.SetHTTP2(true)
+		if strings.HasPrefix(rdapURL, "http://") {
+			transport.Protocols.SetUnencryptedHTTP2(true)
+		}
+	} else {
+		transport.Protocols.SetHTTP1(true)
+	}
+	httpClient := &http.Client{Transport: transport, Timeout: timeout}
+
+	start := time.Now()
+	request, err := http.NewRequest(http.MethodGet, rdapURL, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	request.Header.Set("Accept", "application/rdap+json, application/json")
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return "", 0, err
+	}
+	_, _ = io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+	latency := time.Since(start)
+
+	expectedMajor := 1
+	if combination.Protocol == "HTTP/2" {
+		expectedMajor = 2
+	}
+	if response.ProtoMajor != expectedMajor {
+		return "", latency, fmt.Errorf("negotiated %s instead of %s", response.Proto, combination.Protocol)
+	}
+	return fmt.Sprintf("HTTP %d via %s", response.StatusCode, response.Proto), latency, nil
+}
+
+// dialerForNetwork returns a DialContext that always dials the given network
+// ("tcp", "tcp4" or "tcp6"), regardless of what the transport asks for.
+func dialerForNetwork(network string) func(ctx context.Context, _, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return func(ctx context.Context, _, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+}
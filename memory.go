@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// byteSize is a flag.Value for sizes such as "512MiB", "2G" or "1048576".
+type byteSize int64
+
+var byteSizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+	{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"B", 1},
+}
+
+func (s *byteSize) String() string {
+	if s == nil || *s == 0 {
+		return ""
+	}
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}} {
+		if int64(*s)%unit.size == 0 {
+			return fmt.Sprintf("%d%s", int64(*s)/unit.size, unit.suffix)
+		}
+	}
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *byteSize) Set(value string) error {
+	text := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(text, unit.suffix) {
+			text, multiplier = strings.TrimSpace(strings.TrimSuffix(text, unit.suffix)), unit.multiplier
+			break
+		}
+	}
+	number, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || number < 0 {
+		return fmt.Errorf("invalid size %q (e.g. 512MiB, 2G)", value)
+	}
+	*s = byteSize(number * multiplier)
+	return nil
+}
+
+// resultRetention keeps the results that end-of-run output (-group-by,
+// -copy) needs, within -max-memory. Results are compacted before they are
+// kept: only what the end-of-run output reads survives.
+type resultRetention struct {
+	keepRawBodies bool     // -copy-json exports the raw responses
+	limit         byteSize // 0 is unlimited
+	used          int64
+	results       []lookupResult
+}
+
+// applyMemoryLimit installs limit as the Go runtime's soft memory limit, so
+// the garbage collector works harder before the process grows past it.
+func applyMemoryLimit(limit byteSize) {
+	if limit > 0 {
+		debug.SetMemoryLimit(int64(limit))
+	}
+}
+
+// retain keeps result, or returns an error once the kept results would
+// exceed the limit (the hard limit: the run stops instead of growing).
+func (r *resultRetention) retain(result lookupResult) error {
+	if result.Fetch != nil {
+		compact := &autnumFetch{URL: result.Fetch.URL, Record: result.Fetch.Record, ValidUntil: result.Fetch.ValidUntil}
+		if r.keepRawBodies {
+			compact.RawBody = result.Fetch.RawBody
+		}
+		result.Fetch = compact
+	}
+	result.Attempts = nil
+	size := result.estimatedSize()
+	if r.limit > 0 && r.used+size > int64(r.limit) {
+		return fmt.Errorf("-max-memory %s reached after %d retained results; send results to a -sink instead of -group-by/-copy for runs this large", r.limit.String(), len(r.results))
+	}
+	r.used += size
+	r.results = append(r.results, result)
+	if r.limit > 0 && r.used > int64(r.limit)/10*9 && r.used-size <= int64(r.limit)/10*9 {
+		slog.Warn("retained results are close to -max-memory", "used_bytes", r.used, "limit", r.limit.String())
+	}
+	return nil
+}
+
+// estimatedSize approximates the memory a retained result holds. Decoded
+// records are assumed to take about twice their JSON size, or 4 KiB when the
+// raw body was not kept.
+func (r lookupResult) estimatedSize() int64 {
+	size := int64(256 + len(r.Target) + len(r.Label) + len(r.Name))
+	if r.Fetch != nil {
+		size += int64(len(r.Fetch.URL) + len(r.Fetch.RawBody))
+		if r.Fetch.Record != nil {
+			if len(r.Fetch.RawBody) > 0 {
+				size += 2 * int64(len(r.Fetch.RawBody))
+			} else {
+				size += 4 << 10
+			}
+		}
+	}
+	return size
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the upstream latency
+// histograms: Prometheus' default buckets.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// serveMetrics counts the lookups serve answers and times the requests it
+// sends upstream, for /metrics in the Prometheus text format.
+type serveMetrics struct {
+	mutex   sync.Mutex
+	queries map[[2]string]int // by class and registry
+	cache   map[string]int    // by hit, stale or miss
+	errors  map[string]int    // by result class
+	latency map[string]*latencyHistogram
+}
+
+// latencyHistogram counts durations per bucket of latencyBuckets; counts
+// are not cumulative, the exposition adds them up.
+type latencyHistogram struct {
+	counts []int
+	sum    float64
+	count  int
+}
+
+func newServeMetrics() *serveMetrics {
+	return &serveMetrics{queries: map[[2]string]int{}, cache: map[string]int{}, errors: map[string]int{}, latency: map[string]*latencyHistogram{}}
+}
+
+// observeLookup records one answered lookup of class. cached tells whether
+// the response cache was consulted.
+func (m *serveMetrics) observeLookup(class string, result lookupResult, cached bool) {
+	registry := "unknown"
+	if result.Fetch != nil && result.Fetch.URL != "" {
+		if parsed, err := url.Parse(result.Fetch.URL); err == nil {
+			registry = registryForURL(parsed)
+		}
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.queries[[2]string{class, registry}]++
+	if cached {
+		switch result.source() {
+		case sourceCache:
+			m.cache["hit"]++
+		case sourceStaleCache:
+			m.cache["stale"]++
+		default:
+			m.cache["miss"]++
+		}
+	}
+	if result.Err != nil {
+		m.errors[resultClass(result.Err)]++
+	}
+}
+
+// observeRequest is the Trace hook timing every RDAP request sent upstream
+// by its base URL; bootstrap registry downloads are not counted.
+func (m *serveMetrics) observeRequest(request *http.Request, timings requestTimings) {
+	if isBootstrapRequest(request) {
+		return
+	}
+	endpoint := rdapBaseURL(request.URL)
+	seconds := timings.Total.Seconds()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	histogram := m.latency[endpoint]
+	if histogram == nil {
+		histogram = &latencyHistogram{counts: make([]int, len(latencyBuckets))}
+		m.latency[endpoint] = histogram
+	}
+	for index, bound := range latencyBuckets {
+		if seconds <= bound {
+			histogram.counts[index]++
+			break
+		}
+	}
+	histogram.sum += seconds
+	histogram.count++
+}
+
+func (m *serveMetrics) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(writer)
+}
+
+// write renders the metrics in the Prometheus text exposition format, in a
+// stable order.
+func (m *serveMetrics) write(output io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	fmt.Fprintln(output, "# HELP rdap_tester_queries_total Lookups answered, by object class and registry.")
+	fmt.Fprintln(output, "# TYPE rdap_tester_queries_total counter")
+	queryKeys := make([][2]string, 0, len(m.queries))
+	for key := range m.queries {
+		queryKeys = append(queryKeys, key)
+	}
+	sort.Slice(queryKeys, func(i, j int) bool {
+		return queryKeys[i][0] < queryKeys[j][0] || (queryKeys[i][0] == queryKeys[j][0] && queryKeys[i][1] < queryKeys[j][1])
+	})
+	for _, key := range queryKeys {
+		fmt.Fprintf(output, "rdap_tester_queries_total{class=%s,registry=%s} %d\n", metricLabel(key[0]), metricLabel(key[1]), m.queries[key])
+	}
+
+	fmt.Fprintln(output, "# HELP rdap_tester_cache_lookups_total Response cache lookups, by hit, stale (served past its TTL) or miss.")
+	fmt.Fprintln(output, "# TYPE rdap_tester_cache_lookups_total counter")
+	for _, outcome := range []string{"hit", "stale", "miss"} {
+		fmt.Fprintf(output, "rdap_tester_cache_lookups_total{outcome=%s} %d\n", metricLabel(outcome), m.cache[outcome])
+	}
+
+	fmt.Fprintln(output, "# HELP rdap_tester_errors_total Failed lookups, by error class.")
+	fmt.Fprintln(output, "# TYPE rdap_tester_errors_total counter")
+	for _, class := range sortedKeys(m.errors) {
+		fmt.Fprintf(output, "rdap_tester_errors_total{class=%s} %d\n", metricLabel(class), m.errors[class])
+	}
+
+	fmt.Fprintln(output, "# HELP rdap_tester_upstream_request_duration_seconds Time to the response headers of RDAP requests, by base URL.")
+	fmt.Fprintln(output, "# TYPE rdap_tester_upstream_request_duration_seconds histogram")
+	endpoints := make([]string, 0, len(m.latency))
+	for endpoint := range m.latency {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		histogram, label := m.latency[endpoint], metricLabel(endpoint)
+		cumulative := 0
+		for index, bound := range latencyBuckets {
+			cumulative += histogram.counts[index]
+			fmt.Fprintf(output, "rdap_tester_upstream_request_duration_seconds_bucket{base_url=%s,le=\"%g\"} %d\n", label, bound, cumulative)
+		}
+		fmt.Fprintf(output, "rdap_tester_upstream_request_duration_seconds_bucket{base_url=%s,le=\"+Inf\"} %d\n", label, histogram.count)
+		fmt.Fprintf(output, "rdap_tester_upstream_request_duration_seconds_sum{base_url=%s} %g\n", label, histogram.sum)
+		fmt.Fprintf(output, "rdap_tester_upstream_request_duration_seconds_count{base_url=%s} %d\n", label, histogram.count)
+	}
+}
+
+// rdapObjectPaths are the path segments starting an RDAP query below a
+// server's base URL.
+var rdapObjectPaths = []string{"/autnum/", "/ip/", "/domain/", "/nameserver/", "/entity/", "/help"}
+
+// rdapBaseURL returns the base URL of the server an RDAP query URL was sent
+// to, e.g. https://rdap.verisign.com/com/v1/ for a .com domain query.
+func rdapBaseURL(query *url.URL) string {
+	cut := len(query.Path)
+	for _, segment := range rdapObjectPaths {
+		if index := strings.Index(query.Path, segment); index >= 0 && index < cut {
+			cut = index
+		}
+	}
+	return query.Scheme + "://" + query.Host + query.Path[:cut] + "/"
+}
+
+// metricLabelEscaper escapes label values as the text format requires.
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricLabel quotes a label value.
+func metricLabel(value string) string {
+	return `"` + metricLabelEscaper.Replace(value) + `"`
+}
+
+// sortedKeys returns the keys of counts in order.
+func sortedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mockServer serves canned RDAP responses from a fixtures directory. A request
+// for /autnum/15169 is answered with the file <fixtures>/autnum/15169.json.
+type mockServer struct {
+	fixturesDir string
+	latency     time.Duration
+	jitter      time.Duration
+	errorRate   float64
+	rateLimit   int // requests per second; 0 disables limiting
+
+	mutex       sync.Mutex
+	windowStart time.Time
+	windowCount int
+}
+
+func runMockServer(args []string) int {
+	flagSet := newFlagSet("mock-server")
+	fixturesDir := flagSet.String("fixtures", "fixtures", "directory of canned responses laid out by RDAP path (autnum/15169.json, help.json, ...)")
+	listen := flagSet.String("listen", ":9090", "address to listen on")
+	latency := flagSet.Duration("latency", 0, "fixed delay added to every response")
+	jitter := flagSet.Duration("jitter", 0, "random extra delay up to this duration")
+	errorRate := flagSet.Float64("error-rate", 0, "fraction of requests (0-1) answered with HTTP 503")
+	rateLimit := flagSet.Int("rate-limit", 0, "requests per second before answering HTTP 429 (0 = unlimited)")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . mock-server [flags]")
+		flagSet.PrintDefaults()
+	}
+	if _, err := parseInterspersed(flagSet, args); err != nil {
+		return 2
+	}
+	if info, err := os.Stat(*fixturesDir); err != nil || !info.IsDir() {
+		fmt.Printf("mock-server: fixtures directory %q not found\n", *fixturesDir)
+		return 1
+	}
+
+	server := &mockServer{
+		fixturesDir: *fixturesDir,
+		latency:     *latency,
+		jitter:      *jitter,
+		errorRate:   *errorRate,
+		rateLimit:   *rateLimit,
+	}
+	fmt.Printf("mock-server: serving %s on %s (bootstrap at /bootstrap/asn.json)\n", *fixturesDir, *listen)
+	if err := http.ListenAndServe(*listen, server); err != nil {
+		fmt.Printf("mock-server: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func (m *mockServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	delay := m.latency
+	if m.jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(m.jitter)))
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	if !m.allow() {
+		writer.Header().Set("Retry-After", "1")
+		writeRDAPError(writer, http.StatusTooManyRequests, "Too Many Requests", "mock rate limit exceeded")
+		return
+	}
+	if m.errorRate > 0 && rand.Float64() < m.errorRate {
+		writeRDAPError(writer, http.StatusServiceUnavailable, "Service Unavailable", "mock injected error")
+		return
+	}
+
+	requestPath := path.Clean("/" + request.URL.Path)
+	fixturePath := filepath.Join(m.fixturesDir, filepath.FromSlash(strings.TrimPrefix(requestPath, "/")+".json"))
+	if data, err := os.ReadFile(fixturePath); err == nil {
+		writer.Header().Set("Content-Type", "application/rdap+json")
+		_, _ = writer.Write(data)
+		return
+	}
+
+	// Without a bootstrap fixture, point every ASN at this server so clients
+	// can bootstrap against the mock as well.
+	if requestPath == "/bootstrap/asn.json" {
+		m.writeSelfBootstrap(writer, request)
+		return
+	}
+	writeRDAPError(writer, http.StatusNotFound, "Not Found", "no fixture for "+requestPath)
+}
+
+// allow implements a fixed one-second window request counter.
+func (m *mockServer) allow() bool {
+	if m.rateLimit <= 0 {
+		return true
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(m.windowStart) >= time.Second {
+		m.windowStart = now
+		m.windowCount = 0
+	}
+	m.windowCount++
+	return m.windowCount <= m.rateLimit
+}
+
+func (m *mockServer) writeSelfBootstrap(writer http.ResponseWriter, request *http.Request) {
+	baseURL := "http://" + request.Host + "/"
+	bootstrapFile := map[string]any{
+		"version":     "1.0",
+		"publication": time.Now().UTC().Format(time.RFC3339),
+		"description": "rdap-test mock-server bootstrap",
+		"services": [][][]string{
+			{{"1-4294967295"}, {baseURL}},
+		},
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(bootstrapFile)
+}
+
+// writeRDAPError writes an RFC 9083 error response object.
+func writeRDAPError(writer http.ResponseWriter, statusCode int, title, description string) {
+	writer.Header().Set("Content-Type", "application/rdap+json")
+	writer.WriteHeader(statusCode)
+	_ = json.NewEncoder(writer).Encode(map[string]any{
+		"rdapConformance": []string{"rdap_level_0"},
+		"errorCode":       statusCode,
+		"title":           title,
+		"description":     []string{description},
+	})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+	rdap "github.com/openrdap/rdap"
+	"github.com/openrdap/rdap/bootstrap"
+)
+
+// nameserverDetails are the facts of a nameserver lookup (-ns or ns:name).
+type nameserverDetails struct {
+	Handle string   `json:"handle,omitempty"`
+	IPv4   []string `json:"ipv4,omitempty"`
+	IPv6   []string `json:"ipv6,omitempty"`
+	Status []string `json:"status,omitempty"`
+}
+
+// nameserverTarget returns the nameserver named by an "ns:name" target, or
+// ok false for other targets.
+func nameserverTarget(text string) (name string, ok bool) {
+	return prefixedTarget(text, "ns:")
+}
+
+// prefixedTarget returns the rest of a target starting with prefix, in any
+// case, or ok false for targets without it.
+func prefixedTarget(text, prefix string) (rest string, ok bool) {
+	text = strings.TrimSpace(text)
+	if len(text) < len(prefix) || !strings.EqualFold(text[:len(prefix)], prefix) {
+		return "", false
+	}
+	return text[len(prefix):], true
+}
+
+// nameserverServer returns the base URL of the registry of a nameserver's
+// domain: from -endpoints, or else the DNS bootstrap registry, which
+// openrdap consults for domains only.
+func nameserverServer(client *rdap.Client, name string) (*url.URL, error) {
+	if override := customEndpoints.forDomain(name); override != nil {
+		// Request.URL clears the server URL's query in place, so hand out a copy.
+		server := *override.BaseURL
+		return &server, nil
+	}
+	return bootstrapServer(client, bootstrap.DNS, name)
+}
+
+// bootstrapServer returns a copy of the first server the bootstrap registry
+// lists for query.
+func bootstrapServer(client *rdap.Client, registry bootstrap.RegistryType, query string) (*url.URL, error) {
+	answer, err := client.Bootstrap.Lookup(&bootstrap.Question{RegistryType: registry, Query: query})
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap: %v", err)
+	}
+	if len(answer.URLs) == 0 {
+		return nil, fmt.Errorf("no RDAP server for %s in the bootstrap registry", query)
+	}
+	server := *answer.URLs[0]
+	return &server, nil
+}
+
+// rdapNameserverLookup queries the registry of a nameserver's domain for the
+// nameserver object. The result's Name is the organization of an entity
+// the registry lists, typically the sponsoring registrar.
+func rdapNameserverLookup(ctx context.Context, client *rdap.Client, name string) lookupResult {
+	server, err := nameserverServer(client, name)
+	if err != nil {
+		return lookupResult{Err: err}
+	}
+	ctx, chain := withRedirectChain(ctx)
+	ctx, retried := withAttemptLog(ctx)
+	response, err := doRDAP(client, rdap.NewNameserverRequest(name).WithServer(server).WithContext(ctx))
+	var result lookupResult
+	if lastExchange := lastHTTPExchange(response); lastExchange != nil {
+		result.Fetch = &autnumFetch{URL: lastExchange.URL, RawBody: lastExchange.Body, Redirects: chain, Attempts: retried.merge(rdaplookup.AttemptsFrom(name, response)),
+			ValidUntil: validUntil(lastExchange, time.Now())}
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	switch object := response.Object.(type) {
+	case *rdap.Nameserver:
+		result.Nameserver = &nameserverDetails{Handle: object.Handle, Status: object.Status}
+		if object.IPAddresses != nil {
+			result.Nameserver.IPv4, result.Nameserver.IPv6 = object.IPAddresses.V4, object.IPAddresses.V6
+		}
+		for _, entity := range object.Entities {
+			if entity.VCard != nil {
+				if result.Name = registrantOrganization(entity.VCard); result.Name != "" {
+					break
+				}
+			}
+		}
+	case *rdap.Error:
+		result.Err = fmt.Errorf("server returned error code %d, title=%q, description=%q",
+			object.ErrorCode, object.Title, strings.Join(object.Description, " "))
+	default:
+		result.Err = fmt.Errorf("unexpected RDAP response type %T for %s", response.Object, name)
+	}
+	return result
+}
+
+// line renders the nameserver facts after the organization name.
+func (n *nameserverDetails) line() string {
+	var parts []string
+	if n.Handle != "" {
+		parts = append(parts, fmt.Sprintf(localize("handle %s"), n.Handle))
+	}
+	if addresses := append(append([]string{}, n.IPv4...), n.IPv6...); len(addresses) > 0 {
+		parts = append(parts, fmt.Sprintf(localize("addresses %s"), strings.Join(addresses, ", ")))
+	}
+	if len(n.Status) > 0 {
+		parts = append(parts, fmt.Sprintf(localize("status %s"), strings.Join(n.Status, ", ")))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, "; ") + ")"
+}
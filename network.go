@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+	rdap "github.com/openrdap/rdap"
+)
+
+// networkDetails are the facts of an IP address or prefix lookup.
+type networkDetails struct {
+	Handle  string `json:"handle,omitempty"`
+	NetName string `json:"netname,omitempty"`
+	Range   string `json:"range,omitempty"` // "start - end" as registered
+	Country string `json:"country,omitempty"`
+	// OriginASNs come from ARIN's originas0 extension, when present.
+	OriginASNs []int64 `json:"origin_asns,omitempty"`
+}
+
+// parseNetworkTarget recognizes an IPv4 or IPv6 address or CIDR prefix and
+// returns it in canonical form (prefixes masked), reporting ok = false for
+// anything else.
+func parseNetworkTarget(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if prefix, err := netip.ParsePrefix(text); err == nil {
+		return prefix.Masked().String(), true
+	}
+	if addr, err := netip.ParseAddr(text); err == nil {
+		return addr.WithZone("").String(), true
+	}
+	return "", false
+}
+
+// rdapNetworkLookup queries the registry of an IP address or prefix. The
+// result's Name is the owning organization, found like an autnum's: the
+// registrant, then any organization entity, then the network name.
+func rdapNetworkLookup(ctx context.Context, client *rdap.Client, query string) lookupResult {
+	ctx, chain := withRedirectChain(ctx)
+	ctx, retried := withAttemptLog(ctx)
+	response, err := doRDAP(client, rdap.NewRequest(rdap.IPRequest, query).WithContext(ctx))
+	var result lookupResult
+	if lastExchange := lastHTTPExchange(response); lastExchange != nil {
+		result.Fetch = &autnumFetch{URL: lastExchange.URL, RawBody: lastExchange.Body, Redirects: chain, Attempts: retried.merge(rdaplookup.AttemptsFrom(query, response)),
+			ValidUntil: validUntil(lastExchange, time.Now())}
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	switch object := response.Object.(type) {
+	case *rdap.IPNetwork:
+		result.Network = &networkDetails{
+			Handle:  object.Handle,
+			NetName: object.Name,
+			Country: strings.ToUpper(strings.TrimSpace(object.Country)),
+		}
+		if object.StartAddress != "" {
+			result.Network.Range = object.StartAddress + " - " + object.EndAddress
+		}
+		if result.Fetch != nil {
+			result.Network.OriginASNs = originAutnums(result.Fetch.RawBody)
+		}
+		result.Name = networkOrganization(object)
+		if result.Network.Country == "" {
+			for _, entity := range object.Entities {
+				if entity.VCard != nil && slices.Contains(entity.Roles, "registrant") {
+					result.Network.Country = strings.TrimSpace(entity.VCard.Country())
+				}
+			}
+		}
+	case *rdap.Error:
+		result.Err = fmt.Errorf("server returned error code %d, title=%q, description=%q",
+			object.ErrorCode, object.Title, strings.Join(object.Description, " "))
+	default:
+		result.Err = fmt.Errorf("unexpected RDAP response type %T for %s", response.Object, query)
+	}
+	return result
+}
+
+// networkOrganization names the organization holding network.
+func networkOrganization(network *rdap.IPNetwork) string {
+	for _, entity := range network.Entities {
+		if entity.VCard != nil && slices.Contains(entity.Roles, "registrant") {
+			if name := registrantOrganization(entity.VCard); name != "" {
+				return name
+			}
+		}
+	}
+	for _, entity := range network.Entities {
+		if name := rdaplookup.OrgNameFromVCard(entity.VCard); name != "" {
+			return name
+		}
+	}
+	return network.Name
+}
+
+// line renders the network facts after the organization name.
+func (n *networkDetails) line() string {
+	var parts []string
+	if n.NetName != "" {
+		parts = append(parts, fmt.Sprintf(localize("netname %s"), n.NetName))
+	}
+	if n.Range != "" {
+		parts = append(parts, n.Range)
+	}
+	if n.Country != "" {
+		parts = append(parts, fmt.Sprintf(localize("country %s"), n.Country))
+	}
+	if len(n.OriginASNs) > 0 {
+		origins := make([]string, len(n.OriginASNs))
+		for index, asn := range n.OriginASNs {
+			origins[index] = fmt.Sprintf("AS%d", asn)
+		}
+		parts = append(parts, fmt.Sprintf(localize("origin %s"), strings.Join(origins, ", ")))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, "; ") + ")"
+}
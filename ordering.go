@@ -0,0 +1,51 @@
+package main
+
+// orderedOutput releases rows to emit strictly in input order. Row i is
+// written only after rows 0..i-1, however the lookups behind them finish;
+// a row may hold several results (one per vantage point), written together
+// in vantage order.
+type orderedOutput struct {
+	next    int
+	pending map[int][]lookupResult
+	// buffered is the estimated size of the pending results, which
+	// -max-memory bounds.
+	buffered int64
+	emit     func(lookupResult)
+	// released, when set, is called with each row once its results are
+	// emitted.
+	released func(row int)
+}
+
+func newOrderedOutput(emit func(lookupResult)) *orderedOutput {
+	return &orderedOutput{pending: map[int][]lookupResult{}, emit: emit}
+}
+
+// complete hands over the finished results of row and emits every row that
+// is now due.
+func (o *orderedOutput) complete(row int, results []lookupResult) {
+	o.pending[row] = results
+	o.buffered += resultsSize(results)
+	for {
+		due, ok := o.pending[o.next]
+		if !ok {
+			return
+		}
+		delete(o.pending, o.next)
+		o.buffered -= resultsSize(due)
+		for _, result := range due {
+			o.emit(result)
+		}
+		if o.released != nil {
+			o.released(o.next)
+		}
+		o.next++
+	}
+}
+
+func resultsSize(results []lookupResult) int64 {
+	var size int64
+	for _, result := range results {
+		size += result.estimatedSize()
+	}
+	return size
+}
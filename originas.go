@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// originNetwork is one network registered as originated by an ASN, from
+// ARIN's originas0 extension.
+type originNetwork struct {
+	Handle       string   `json:"handle"`
+	Name         string   `json:"name,omitempty"`
+	StartAddress string   `json:"start_address"`
+	EndAddress   string   `json:"end_address"`
+	CIDRs        []string `json:"cidrs,omitempty"`
+}
+
+// originNetworksURL returns the originas0 network search URL for the
+// autnum in fetch: the extension's own link when the record carries one,
+// otherwise the search path next to the autnum path of a registry that
+// declares arin_originas0 conformance. ok is false for other registries.
+func originNetworksURL(fetch *autnumFetch, asn int64) (string, bool) {
+	if fetch == nil || fetch.Record == nil {
+		return "", false
+	}
+	for _, link := range fetch.Record.Links {
+		if strings.Contains(link.Href, "arin_originas0_networksbyoriginas") {
+			return link.Href, true
+		}
+	}
+	base, _, found := strings.Cut(fetch.URL, "/autnum/")
+	if !found || !slices.Contains(fetch.Record.Conformance, "arin_originas0") {
+		return "", false
+	}
+	return base + "/arin_originas0_networksbyoriginas/" + strconv.FormatInt(asn, 10), true
+}
+
+// listOriginNetworks enumerates the networks registered as originated by
+// the autnum in fetch (--list-networks).
+func listOriginNetworks(ctx context.Context, httpClient *http.Client, fetch *autnumFetch, asn int64) ([]originNetwork, error) {
+	searchURL, ok := originNetworksURL(fetch, asn)
+	if !ok {
+		return nil, fmt.Errorf("the registry does not support the arin_originas0 extension")
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, searchURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/rdap+json")
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", searchURL, response.Status)
+	}
+	var search struct {
+		Results []struct {
+			Handle       string `json:"handle"`
+			Name         string `json:"name"`
+			StartAddress string `json:"startAddress"`
+			EndAddress   string `json:"endAddress"`
+			CIDRs        []struct {
+				V4Prefix string `json:"v4prefix"`
+				V6Prefix string `json:"v6prefix"`
+				Length   int    `json:"length"`
+			} `json:"cidr0_cidrs"`
+		} `json:"arin_originas0_networkSearchResults"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&search); err != nil {
+		return nil, fmt.Errorf("%s: %v", searchURL, err)
+	}
+	networks := make([]originNetwork, 0, len(search.Results))
+	for _, result := range search.Results {
+		network := originNetwork{Handle: result.Handle, Name: result.Name, StartAddress: result.StartAddress, EndAddress: result.EndAddress}
+		for _, cidr := range result.CIDRs {
+			prefix := cidr.V4Prefix + cidr.V6Prefix
+			network.CIDRs = append(network.CIDRs, fmt.Sprintf("%s/%d", prefix, cidr.Length))
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// line renders the network below its ASN's result line.
+func (n originNetwork) line() string {
+	prefixes := strings.Join(n.CIDRs, ", ")
+	if prefixes == "" {
+		prefixes = n.StartAddress + " - " + n.EndAddress
+	}
+	return fmt.Sprintf("  network: %s %s (%s)", prefixes, n.Name, n.Handle)
+}
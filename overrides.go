@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// nameOverrides maps an ASN (canonical "AS15169" form) to the display name
+// that replaces the extracted one.
+type nameOverrides map[string]string
+
+// defaultOverridesPath returns $XDG_CONFIG_HOME/rdap-tester/overrides,
+// defaulting to ~/.config/rdap-tester/overrides.
+func defaultOverridesPath() (string, error) {
+	return configFilePath("overrides")
+}
+
+// configFilePath returns $XDG_CONFIG_HOME/rdap-tester/name, defaulting to
+// ~/.config/rdap-tester/name.
+func configFilePath(name string) (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "rdap-tester", name), nil
+}
+
+// loadOverrides reads an overrides file with one "ASN name" pair per line,
+// e.g. "AS15169 Google (partner: search)". Blank lines and lines starting
+// with # are ignored. A missing file is not an error when optional is set.
+func loadOverrides(path string, optional bool) (nameOverrides, error) {
+	file, err := os.Open(path)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		return nameOverrides{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	overrides := nameOverrides{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		target := strings.Fields(line)[0]
+		name := strings.TrimSpace(strings.TrimPrefix(line, target))
+		asn, err := parseASN(target)
+		if err != nil || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected \"ASN name\", got %q", path, lineNumber, line)
+		}
+		overrides[fmt.Sprintf("AS%d", asn)] = name
+	}
+	return overrides, scanner.Err()
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pipeline is a named batch run: where its targets come from and the batch
+// flags (enrichers, filters, sinks, output format) applied to them.
+type pipeline struct {
+	Name string
+	// Inputs are "file:path" specs or literal targets, in order.
+	Inputs []string
+	// Flags are the batch flags as "-name=value", in file order.
+	Flags []string
+}
+
+// defaultPipelinesPath returns $XDG_CONFIG_HOME/rdap-tester/pipelines,
+// defaulting to ~/.config/rdap-tester/pipelines.
+func defaultPipelinesPath() (string, error) {
+	return configFilePath("pipelines")
+}
+
+// loadPipelines reads a pipelines file of [name] sections holding
+// "key = value" lines. The key input adds targets (file:path or literal
+// targets separated by spaces); every other key is a batch flag name
+// without its dash, repeated for repeatable flags. Blank lines and lines
+// starting with # are ignored.
+//
+//	[nightly-enrichment]
+//	input = file:/srv/asns.txt
+//	follow-ups = 8
+//	filter = country != "US"
+//	json = true
+//	sink = file:/srv/enriched.jsonl
+func loadPipelines(path string) (map[string]*pipeline, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no pipelines defined (%s does not exist)", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	pipelines := map[string]*pipeline{}
+	var current *pipeline
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" || pipelines[name] != nil {
+				return nil, fmt.Errorf("%s:%d: empty or duplicate pipeline name %q", path, lineNumber, name)
+			}
+			current = &pipeline{Name: name}
+			pipelines[name] = current
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || current == nil {
+			return nil, fmt.Errorf("%s:%d: expected \"[name]\" or \"key = value\", got %q", path, lineNumber, line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "input" {
+			if strings.HasPrefix(value, "file:") {
+				current.Inputs = append(current.Inputs, value)
+			} else {
+				current.Inputs = append(current.Inputs, strings.Fields(value)...)
+			}
+			continue
+		}
+		current.Flags = append(current.Flags, "-"+strings.TrimPrefix(key, "-")+"="+value)
+	}
+	return pipelines, scanner.Err()
+}
+
+// targets expands the pipeline's inputs, reading input files with one or
+// more targets per line (# starts a comment).
+func (p *pipeline) targets() ([]string, error) {
+	var targets []string
+	for _, input := range p.Inputs {
+		path, isFile := strings.CutPrefix(input, "file:")
+		if !isFile {
+			targets = append(targets, input)
+			continue
+		}
+		fileTargets, err := readTargetsFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline %s: %v", p.Name, err)
+		}
+		targets = append(targets, fileTargets...)
+	}
+	return targets, nil
+}
+
+// commandLine renders the equivalent batch invocation for a POSIX shell.
+func (p *pipeline) commandLine() string {
+	words := []string{"go", "run", "."}
+	for _, flag := range p.Flags {
+		name, value, _ := strings.Cut(flag, "=")
+		words = append(words, name+"="+shellQuote(value))
+	}
+	for _, input := range p.Inputs {
+		if path, isFile := strings.CutPrefix(input, "file:"); isFile {
+			input = "$(sed 's/#.*//' " + shellQuote(path) + ")"
+		}
+		words = append(words, input)
+	}
+	return strings.Join(words, " ")
+}
+
+// shellQuote quotes value for a POSIX shell when it needs it.
+func shellQuote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\"'$`\\!*?&|;<>()[]{}~#") {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func runPipeline(args []string) int {
+	flagSet := newFlagSet("pipeline")
+	path := flagSet.String("file", "", "pipelines `file` (default ~/.config/rdap-tester/pipelines)")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . pipeline [--file pipelines] list | show <name> | run <name>")
+		flagSet.PrintDefaults()
+	}
+	positional, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) == 0 || (positional[0] != "list" && len(positional) != 2) {
+		flagSet.Usage()
+		return 2
+	}
+	if *path == "" {
+		if *path, err = defaultPipelinesPath(); err != nil {
+			fmt.Printf("pipeline: %v\n", err)
+			return 1
+		}
+	}
+	pipelines, err := loadPipelines(*path)
+	if err != nil {
+		fmt.Printf("pipeline: %v\n", err)
+		return 1
+	}
+
+	if positional[0] == "list" {
+		names := make([]string, 0, len(pipelines))
+		for name := range pipelines {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%-24s %s\n", name, pipelines[name].commandLine())
+		}
+		return 0
+	}
+	selected, ok := pipelines[positional[1]]
+	if !ok {
+		fmt.Printf("pipeline: no pipeline named %s in %s\n", strconv.Quote(positional[1]), *path)
+		return 2
+	}
+	switch positional[0] {
+	case "show":
+		fmt.Println(selected.commandLine())
+		return 0
+	case "run":
+		targets, err := selected.targets()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		return runLookup(append(append([]string{}, selected.Flags...), targets...))
+	default:
+		flagSet.Usage()
+		return 2
+	}
+}
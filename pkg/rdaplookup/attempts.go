@@ -0,0 +1,74 @@
+package rdaplookup
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	rdap "github.com/openrdap/rdap"
+)
+
+// Attempt is one HTTP request made while looking up a target: which query
+// format went to which endpoint, and how it ended.
+type Attempt struct {
+	Query    string        `json:"query"`
+	URL      string        `json:"url"`
+	Status   int           `json:"status,omitempty"` // 0 when no response arrived
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// AttemptsFrom lists the HTTP exchanges of one RDAP response as attempts.
+func AttemptsFrom(query string, response *rdap.Response) []Attempt {
+	if response == nil {
+		return nil
+	}
+	attempts := make([]Attempt, 0, len(response.HTTP))
+	for _, exchange := range response.HTTP {
+		attempt := Attempt{Query: query, URL: exchange.URL, Duration: exchange.Duration}
+		if exchange.Response != nil {
+			attempt.Status = exchange.Response.StatusCode
+		}
+		switch {
+		case exchange.Error != nil:
+			attempt.Error = exchange.Error.Error()
+		case attempt.Status < 200 || attempt.Status > 299:
+			attempt.Error = http.StatusText(attempt.Status)
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts
+}
+
+// Transient reports whether the attempt failed in a way a retry or another
+// endpoint can fix: no response at all, 429 or a 5xx status. A 404 for one
+// query format is an answer, not a failure.
+func (a Attempt) Transient() bool {
+	return a.Error != "" && (a.Status == 0 || a.Status == http.StatusTooManyRequests || a.Status >= 500)
+}
+
+// Reason is a short description of a failed attempt, e.g. "503" or
+// "connection refused".
+func (a Attempt) Reason() string {
+	if a.Status != 0 {
+		return fmt.Sprint(a.Status)
+	}
+	// Keep the innermost cause of wrapped transport errors.
+	if index := strings.LastIndex(a.Error, ": "); index >= 0 {
+		return a.Error[index+2:]
+	}
+	return a.Error
+}
+
+// TransientFailures returns the attempts of a successful lookup that failed
+// transiently before it succeeded.
+func TransientFailures(attempts []Attempt) []Attempt {
+	var failures []Attempt
+	for _, attempt := range attempts {
+		if attempt.Transient() {
+			failures = append(failures, attempt)
+		}
+	}
+	return failures
+}
@@ -0,0 +1,101 @@
+package rdaplookup
+
+import (
+	"fmt"
+	"strings"
+
+	rdap "github.com/openrdap/rdap"
+)
+
+// ExtractName returns the organization name of an autnum record: the name
+// of an organization vCard, else a remark, else the name or handle.
+func ExtractName(autnumRecord *rdap.Autnum) string {
+	organizationName, _ := ExplainName(autnumRecord)
+	return organizationName
+}
+
+// ExplainName returns the extracted name together with a description of
+// the step that produced it.
+func ExplainName(autnumRecord *rdap.Autnum) (string, string) {
+	if autnumRecord == nil {
+		return "", "no record"
+	}
+
+	// Step 1: Look for an organization vCard with kind="org" and extract its formatted name (fn)
+	for _, entity := range autnumRecord.Entities {
+		if organizationName := OrgNameFromVCard(entity.VCard); organizationName != "" {
+			return organizationName, fmt.Sprintf("step 1: org vCard fn of entity %s", entity.Handle)
+		}
+	}
+
+	// Step 2: Fall back to remarks with title "description" (common in APNIC records)
+	for _, remark := range autnumRecord.Remarks {
+		if strings.EqualFold(strings.TrimSpace(remark.Title), "description") && len(remark.Description) > 0 {
+			if description := strings.TrimSpace(remark.Description[0]); description != "" {
+				return description, "step 2: \"description\" remark"
+			}
+		}
+	}
+
+	// Step 3: Try any remark description as a fallback
+	for _, remark := range autnumRecord.Remarks {
+		if len(remark.Description) > 0 {
+			if description := strings.TrimSpace(remark.Description[0]); description != "" {
+				return description, fmt.Sprintf("step 3: first remark with a description (%q)", remark.Title)
+			}
+		}
+	}
+
+	// Step 4: Last resorts - use the RDAP name field or handle
+	if name := strings.TrimSpace(autnumRecord.Name); name != "" {
+		return name, "step 4: autnum name field"
+	}
+	if handle := strings.TrimSpace(autnumRecord.Handle); handle != "" {
+		return handle, "step 4: autnum handle"
+	}
+
+	return "", "no org vCard, remark, name or handle"
+}
+
+// OrgNameFromVCard returns the formatted name (fn) of an organization
+// vCard (kind "org"), or "" for any other vCard.
+func OrgNameFromVCard(vcard *rdap.VCard) string {
+	if vcard == nil || len(vcard.Properties) == 0 {
+		return ""
+	}
+
+	// First pass: Check if this vCard represents an organization (kind="org")
+	isOrganization := false
+	for _, property := range vcard.Properties {
+		if strings.EqualFold(property.Name, "kind") {
+			values := property.Values()
+			if len(values) > 0 {
+				kindValue := strings.ToLower(strings.TrimSpace(values[len(values)-1]))
+				if strings.Contains(kindValue, "org") {
+					isOrganization = true
+					break
+				}
+			}
+		}
+	}
+
+	// If this isn't an organization vCard, skip it
+	if !isOrganization {
+		return ""
+	}
+
+	// Second pass: Extract the formatted name (fn) from the organization vCard
+	for _, property := range vcard.Properties {
+		if strings.EqualFold(property.Name, "fn") {
+			values := property.Values()
+			// Search from the end of values array backwards for the first non-empty value
+			for i := len(values) - 1; i >= 0; i-- {
+				if formattedName := strings.TrimSpace(values[i]); formattedName != "" {
+					return formattedName
+				}
+			}
+		}
+	}
+
+	return ""
+}
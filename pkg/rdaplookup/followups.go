@@ -0,0 +1,104 @@
+package rdaplookup
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	rdap "github.com/openrdap/rdap"
+)
+
+const (
+	// DefaultFollowUps is the per-target follow-up budget of Lookup.
+	DefaultFollowUps = 4
+	// FollowUpTimeout bounds all follow-up requests of one target together.
+	FollowUpTimeout = 10 * time.Second
+)
+
+// EntityReference is an entity the registry embedded without contact data,
+// only with a "self" link to its full object.
+type EntityReference struct {
+	Entity *rdap.Entity
+	URL    *url.URL
+}
+
+// EntityReferences lists the entities of record, nested ones included, that
+// need dereferencing before their vCards can be read.
+func EntityReferences(record *rdap.Autnum) []EntityReference {
+	var references []EntityReference
+	var walk func([]rdap.Entity)
+	walk = func(entities []rdap.Entity) {
+		for index := range entities {
+			entity := &entities[index]
+			if entity.VCard == nil {
+				for _, link := range entity.Links {
+					if !strings.EqualFold(link.Rel, "self") {
+						continue
+					}
+					if target, err := url.Parse(link.Href); err == nil && target.IsAbs() {
+						references = append(references, EntityReference{Entity: entity, URL: target})
+					}
+					break
+				}
+			}
+			walk(entity.Entities)
+		}
+	}
+	walk(record.Entities)
+	return references
+}
+
+// DereferenceEntities fetches the full objects of at most budget referenced
+// entities of record concurrently, under one shared deadline, and merges
+// their contact data into record in place. It returns how many succeeded;
+// failures only leave the embedded entity as it was.
+func (c *Client) DereferenceEntities(ctx context.Context, record *rdap.Autnum, budget int) int {
+	logger := c.logger()
+	references := EntityReferences(record)
+	if len(references) > budget {
+		logger.Debug("follow-up budget exhausted", "referenced_entities", len(references), "budget", budget)
+		references = references[:budget]
+	}
+	if len(references) == 0 {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(ctx, FollowUpTimeout)
+	defer cancel()
+
+	fetched := make([]*rdap.Entity, len(references))
+	var wait sync.WaitGroup
+	for index, reference := range references {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			response, err := c.rdapClient().Do(rdap.NewRawRequest(reference.URL).WithContext(ctx))
+			if err != nil {
+				logger.Debug("entity follow-up failed", "url", reference.URL.String(), "error", err)
+				return
+			}
+			if entity, ok := response.Object.(*rdap.Entity); ok {
+				fetched[index] = entity
+			}
+		}()
+	}
+	wait.Wait()
+
+	succeeded := 0
+	for index, entity := range fetched {
+		if entity == nil {
+			continue
+		}
+		// Keep the roles as embedded: they describe the entity's relation to
+		// this autnum, which the standalone object does not know.
+		target := references[index].Entity
+		target.VCard = entity.VCard
+		if len(target.Entities) == 0 {
+			target.Entities = entity.Entities
+		}
+		succeeded++
+	}
+	logger.Debug("entity follow-ups", "requested", len(references), "succeeded", succeeded)
+	return succeeded
+}
@@ -0,0 +1,256 @@
+// Package rdaplookup finds the organization behind an autonomous system
+// number over RDAP: it bootstraps the responsible registry, tries the query
+// formats registries disagree on, dereferences linked entities when the
+// record embeds no contact data and extracts the organization name.
+//
+//	result, err := rdaplookup.Lookup(ctx, 15169)
+//	if err == nil {
+//		fmt.Println(result.Name) // Google LLC
+//	}
+package rdaplookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+
+	rdap "github.com/openrdap/rdap"
+)
+
+// Client looks up ASNs. The zero value is ready to use, with no follow-ups;
+// Lookup uses a Client with DefaultFollowUps.
+type Client struct {
+	// RDAP sends the queries; nil uses a default openrdap client.
+	RDAP *rdap.Client
+	// FollowUps is the number of linked entities dereferenced, at most,
+	// when the record embeds no organization contact (0 disables).
+	FollowUps int
+	// Route, when set, may point a request at a custom server (with
+	// Request.WithServer) instead of the bootstrapped one.
+	Route func(*rdap.Request) *rdap.Request
+	// Logger receives debug records explaining failed attempts and name
+	// extraction; nil discards them.
+	Logger *slog.Logger
+}
+
+// Result is the outcome of an ASN lookup.
+type Result struct {
+	ASN  int64
+	Name string
+	// Source describes the extraction step that produced Name, e.g.
+	// "step 1: org vCard fn of entity ORG-1".
+	Source string
+	// Private is set for RFC 6996 private-use ASNs, which are never queried.
+	Private bool
+	// Record is the decoded autnum, with dereferenced entities merged in.
+	Record *rdap.Autnum
+	// URL and RawBody are the final RDAP URL queried and the body it
+	// returned; Exchange is that last HTTP exchange in full.
+	URL      string
+	RawBody  []byte
+	Exchange *rdap.HTTPResponse
+	// Attempts lists every HTTP request made, across query formats and
+	// bootstrap endpoints, in order.
+	Attempts []Attempt
+}
+
+// Lookup looks up asn with a default Client that dereferences up to
+// DefaultFollowUps linked entities.
+func Lookup(ctx context.Context, asn int64) (*Result, error) {
+	return (&Client{FollowUps: DefaultFollowUps}).Lookup(ctx, asn)
+}
+
+// Lookup returns the extracted organization name of asn. On error the
+// result is still returned, without a Name, whenever a request reached an
+// RDAP server, so callers can report what was tried.
+func (c *Client) Lookup(ctx context.Context, asn int64) (*Result, error) {
+	if asn <= 0 || asn > 4294967295 {
+		return nil, fmt.Errorf("invalid ASN: %d", asn)
+	}
+	// Skip private ASN range (RFC 6996)
+	if asn >= 64512 && asn <= 65535 {
+		return &Result{ASN: asn, Name: "Private ASN", Private: true}, nil
+	}
+
+	logger := c.logger()
+	result := &Result{ASN: asn}
+	var lastErr error
+	formats := QueryFormats(asn)
+	if server := c.routedServer(asn); server != "" && queryFormats.learned(server) == 1 {
+		formats[0], formats[1] = formats[1], formats[0]
+	}
+	for index, query := range formats {
+		response, err := c.Do(rdap.NewRequest(rdap.AutnumRequest, query).WithContext(ctx))
+		attempts := AttemptsFrom(query, response)
+		for _, attempt := range TransientFailures(attempts) {
+			logger.Debug("attempt failed", "url", attempt.URL, "status", attempt.Status, "error", attempt.Error)
+		}
+		result.Attempts = append(result.Attempts, attempts...)
+		var server string
+		if response != nil && len(response.HTTP) > 0 {
+			result.Exchange = response.HTTP[len(response.HTTP)-1]
+			result.URL, result.RawBody = result.Exchange.URL, result.Exchange.Body
+			server = serverOf(result.URL)
+		}
+		if err == nil {
+			switch object := response.Object.(type) {
+			case *rdap.Autnum:
+				result.Record = object
+			case *rdap.Error:
+				err = fmt.Errorf("server returned error code %d, title=%q, description=%q",
+					object.ErrorCode, object.Title, strings.Join(object.Description, " "))
+			default:
+				err = fmt.Errorf("unexpected RDAP response type %T for %s", response.Object, query)
+			}
+		}
+		if err != nil {
+			logger.Debug("query failed", "query", query, "error", err)
+			lastErr = err
+			// Only a 404 can mean the server wants the other format, and
+			// not from a server known to take this one.
+			var clientError *rdap.ClientError
+			if !errors.As(err, &clientError) || clientError.Type != rdap.ObjectDoesNotExist ||
+				(server != "" && queryFormats.learned(server) == formatIndex(query)) {
+				break
+			}
+			continue
+		}
+		if server != "" {
+			queryFormats.learn(server, formatIndex(query))
+		}
+		if index > 0 {
+			logger.Debug("query format learned", "server", server, "query", query)
+		}
+
+		result.Name, result.Source = ExplainName(result.Record)
+		if !strings.HasPrefix(result.Source, "step 1") && c.FollowUps > 0 && c.DereferenceEntities(ctx, result.Record, c.FollowUps) > 0 {
+			result.Name, result.Source = ExplainName(result.Record)
+		}
+		logger.Debug("name extracted", "asn", asn, "name", result.Name, "source", result.Source)
+		return result, nil
+	}
+	return result, lastErr
+}
+
+// routedServer returns the server Route sends autnum queries for asn to,
+// or "" when they go through the bootstrap registry.
+func (c *Client) routedServer(asn int64) string {
+	if c.Route == nil {
+		return ""
+	}
+	request := c.Route(rdap.NewRequest(rdap.AutnumRequest, strconv.FormatInt(asn, 10)))
+	if request.Server == nil {
+		return ""
+	}
+	return serverOf(request.URL().String())
+}
+
+// Do sends request to the server Route picks or, failing that, through the
+// bootstrap registry. Errors name why the last server failed (e.g. a refused
+// connection) rather than only "no RDAP servers responded successfully";
+// failures before any server was asked are BootstrapErrors.
+func (c *Client) Do(request *rdap.Request) (*rdap.Response, error) {
+	if c.Route != nil {
+		request = c.Route(request)
+	}
+	response, err := c.rdapClient().Do(request)
+	if err == nil {
+		return response, nil
+	}
+	if response == nil || len(response.HTTP) == 0 {
+		var clientError *rdap.ClientError
+		if errors.As(err, &clientError) && clientError.Type == rdap.InputError {
+			return response, err
+		}
+		return response, &BootstrapError{Err: err}
+	}
+	if lastExchange := response.HTTP[len(response.HTTP)-1]; lastExchange.Error != nil {
+		return response, fmt.Errorf("%w: %w", err, lastExchange.Error)
+	}
+	return response, err
+}
+
+// BootstrapError is a request that failed before any RDAP server was
+// asked: the bootstrap registry could not be downloaded, or has no entry
+// for the query.
+type BootstrapError struct {
+	Err error
+}
+
+func (e *BootstrapError) Error() string { return "bootstrap failed: " + e.Err.Error() }
+func (e *BootstrapError) Unwrap() error { return e.Err }
+
+// QueryFormats lists the query strings for asn, in the order tried: the
+// RFC 9082 form "12345", which every RIR accepts, then "AS12345", which
+// some other servers want instead. The second is only sent after a 404 for
+// the first, and not to a server that answered the first form before.
+func QueryFormats(asn int64) []string {
+	return []string{strconv.FormatInt(asn, 10), "AS" + strconv.FormatInt(asn, 10)}
+}
+
+// formatIndex returns the index in QueryFormats of query's form.
+func formatIndex(query string) int {
+	if strings.HasPrefix(query, "AS") {
+		return 1
+	}
+	return 0
+}
+
+// formatMemory remembers, per server base URL, the index in QueryFormats
+// of the form the server last answered.
+type formatMemory struct {
+	mutex   sync.Mutex
+	formats map[string]int
+}
+
+// queryFormats is shared by every Client: a server takes the same form
+// whichever client asks.
+var queryFormats = &formatMemory{formats: map[string]int{}}
+
+// learned returns the index of the form server answered, or -1 if it has
+// not answered yet.
+func (m *formatMemory) learned(server string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if index, ok := m.formats[server]; ok {
+		return index
+	}
+	return -1
+}
+
+func (m *formatMemory) learn(server string, index int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.formats[server] = index
+}
+
+// serverOf returns the base URL of an autnum query URL.
+func serverOf(queryURL string) string {
+	if index := strings.LastIndex(queryURL, "/autnum/"); index >= 0 {
+		return queryURL[:index+1]
+	}
+	return queryURL
+}
+
+// defaultRDAPClient serves Clients without their own, sharing its cached
+// bootstrap registry between lookups.
+var defaultRDAPClient = &rdap.Client{}
+
+func (c *Client) rdapClient() *rdap.Client {
+	if c.RDAP == nil {
+		return defaultRDAPClient
+	}
+	return c.RDAP
+}
+
+func (c *Client) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return c.Logger
+}
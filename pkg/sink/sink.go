@@ -0,0 +1,156 @@
+// Package sink defines the destinations batch results are sent to. The
+// stdout and file sinks are built in; programs embedding the lookups, and
+// optional files of rdap-test itself, add more with RegisterSink, so that
+// e.g. a Kafka or Postgres sink and its dependencies are only compiled in
+// when asked for:
+//
+//	//go:build kafka
+//
+//	func init() { sink.RegisterSink("kafka", openKafkaSink) }
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Result is one batch result as sinks receive it.
+type Result struct {
+	// Target is the target as looked up, e.g. "AS15169".
+	Target string
+	// Line is the input line the target was read from, 0 for targets
+	// given as arguments.
+	Line int
+	// Record is the result as the JSON object -json writes, with its asn,
+	// name, rir, error, error_class, attempts and other fields.
+	Record json.RawMessage
+	// Raw is the RDAP document as the server sent it; nil without one.
+	Raw []byte
+	// Text is the result rendered in the output format of the run (text
+	// lines, a JSON or CSV line, a -format line or the raw document),
+	// ending in a newline; empty when the format has nothing for it.
+	Text []byte
+}
+
+// Sink receives every emitted batch result.
+type Sink interface {
+	Write(result Result) error
+	// Flush is called once after the last result and must release any
+	// resources the sink holds.
+	Flush() error
+}
+
+// factories maps a sink name to the function opening it.
+var factories = map[string]func(argument string) (Sink, error){
+	"stdout": func(string) (Sink, error) { return Stdout(), nil },
+	"file": func(path string) (Sink, error) {
+		if path == "" {
+			return nil, fmt.Errorf("file sink needs a path (file:results.txt)")
+		}
+		return OpenFile(path, false)
+	},
+}
+
+// RegisterSink makes a sink available under name, replacing any sink
+// registered under it before. It is meant to be called from init
+// functions; open receives the text after "name:" in a -sink spec.
+func RegisterSink(name string, open func(argument string) (Sink, error)) {
+	factories[name] = open
+}
+
+// Names returns the names of the registered sinks, sorted.
+func Names() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open opens the sink registered under name with argument.
+func Open(name, argument string) (Sink, error) {
+	open, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown sink %q (known: %s)", name, strings.Join(Names(), ", "))
+	}
+	return open(argument)
+}
+
+// Writer is the built-in sink writing the Text of every result, after
+// Header, to standard output or a file.
+type Writer struct {
+	// Header is written before the first result, e.g. a CSV header row,
+	// unless the file already had content.
+	Header []byte
+	// Stream flushes every result as it is written. Standard output is
+	// always flushed per result; files otherwise at the end.
+	Stream bool
+
+	writer  *bufio.Writer
+	closer  io.Closer
+	started bool // set once Header was written or skipped
+}
+
+// Stdout returns a Writer to standard output.
+func Stdout() *Writer {
+	return &Writer{writer: bufio.NewWriter(os.Stdout)}
+}
+
+// OpenFile returns a Writer to the file at path, replacing it, or adding
+// to it after its content with appendTo.
+func OpenFile(path string, appendTo bool) (*Writer, error) {
+	if !appendTo {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		return &Writer{writer: bufio.NewWriter(file), closer: file}, nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o666)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Writer{writer: bufio.NewWriter(file), closer: file, started: info.Size() > 0}, nil
+}
+
+func (w *Writer) Write(result Result) error {
+	if !w.started {
+		w.started = true
+		if _, err := w.writer.Write(w.Header); err != nil {
+			return err
+		}
+	}
+	if _, err := w.writer.Write(result.Text); err != nil {
+		return err
+	}
+	if w.closer == nil || w.Stream {
+		return w.writer.Flush()
+	}
+	return nil
+}
+
+// FlushBuffer writes out the buffered results, keeping the file open.
+func (w *Writer) FlushBuffer() error {
+	return w.writer.Flush()
+}
+
+func (w *Writer) Flush() error {
+	err := w.writer.Flush()
+	if w.closer != nil {
+		if closeErr := w.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openrdap/rdap/bootstrap"
+)
+
+// plannedServer is the share of a planned batch sent to one RDAP server.
+type plannedServer struct {
+	Registry string
+	Server   string
+	Queries  int
+}
+
+// planBudget is the effect of one -budget on a planned batch: queries over
+// what is left of the current window are refused, or with -budget-defer
+// wait for later windows.
+type planBudget struct {
+	Budget    registryBudget
+	Remaining int
+	Refused   int           // queries refused (without -budget-defer)
+	Wait      time.Duration // time spent waiting for windows (with -budget-defer)
+}
+
+// evaluate applies the budget to queries sent to its registry from now.
+func (b registryBudget) evaluate(queries int, deferQueries bool, now time.Time) planBudget {
+	used, _ := registryQueryCounter.used(b.Registry, b.Period, now)
+	plan := planBudget{Budget: b, Remaining: max(b.Limit-used, 0)}
+	over := queries - plan.Remaining
+	switch {
+	case over <= 0:
+	case !deferQueries || b.Limit == 0:
+		plan.Refused = over
+	default:
+		period := budgetPeriods[b.Period]
+		windows := (over + b.Limit - 1) / b.Limit
+		plan.Wait = now.UTC().Truncate(period).Add(period).Sub(now) + time.Duration(windows-1)*period
+	}
+	return plan
+}
+
+// resolveServer returns the base URL of the server a target's query would
+// go to, from -endpoints or the bootstrap registry, without querying it.
+func resolveServer(client vantageClient, target string, domainMode bool) (string, error) {
+	request, registry, ok := targetRequest(target, domainMode)
+	if !ok {
+		return "", fmt.Errorf("not a valid target")
+	}
+	if registry == bootstrap.ASN {
+		if asn, _ := parseASN(target); asn >= 64512 && asn <= 65535 {
+			return "", fmt.Errorf("private ASN, never queried")
+		}
+	}
+	if override := customEndpoints.forRequest(request); override != nil {
+		return override.BaseURL.String(), nil
+	}
+	answer, err := client.Client.Bootstrap.Lookup(&bootstrap.Question{RegistryType: registry, Query: request.Query})
+	if err != nil {
+		return "", fmt.Errorf("bootstrap: %v", err)
+	}
+	if len(answer.URLs) == 0 {
+		return "", fmt.Errorf("no RDAP server in the bootstrap registry")
+	}
+	return answer.URLs[0].String(), nil
+}
+
+func runPlan(args []string) int {
+	flagSet := newFlagSet("plan")
+	var options clientOptions
+	options.registerFlags(flagSet)
+	var targetFiles targetFileFlag
+	flagSet.Var(&targetFiles, "f", "read targets from `file`, one or more per line (# starts a comment; - is stdin; repeatable)")
+	domainMode := flagSet.Bool("domain", false, "plan domain name lookups instead of ASNs")
+	concurrency := flagSet.Int("concurrency", 1, "the -concurrency the batch will run with")
+	queryTime := flagSet.Duration("query-time", 500*time.Millisecond, "assumed average `duration` of one query")
+	spread := flagSet.Duration("spread", 0, "the -spread `window` the batch will run with")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . plan [-rate-limit n] [-budget registry=N/period] [-concurrency n] [-domain] -f asns.txt [target ...]")
+		flagSet.PrintDefaults()
+	}
+	targets, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	if err := options.validate(); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if *concurrency < 1 || *queryTime <= 0 || *spread < 0 {
+		fmt.Println("plan: -concurrency must be at least 1, -query-time positive and -spread not negative")
+		return 2
+	}
+	for _, path := range targetFiles {
+		fileTargets, err := readTargetsFile(path)
+		if err != nil {
+			fmt.Printf("plan: %v\n", err)
+			return 2
+		}
+		targets = append(targets, fileTargets...)
+	}
+	if !*domainMode {
+		if targets, _, err = expandASNRanges(targets, nil); err != nil {
+			fmt.Printf("plan: %v\n", err)
+			return 2
+		}
+	}
+	if len(targets) == 0 {
+		flagSet.Usage()
+		return 2
+	}
+
+	// Only the bootstrap registries are downloaded; no RDAP server is asked.
+	client := newVantageClients(options, nil, nil)[0]
+	byServer := map[string]*plannedServer{}
+	unqueried := map[string]int{}
+	for _, target := range targets {
+		server, err := resolveServer(client, target, *domainMode)
+		if err != nil {
+			unqueried[err.Error()]++
+			continue
+		}
+		planned, ok := byServer[server]
+		if !ok {
+			planned = &plannedServer{Registry: registryForRawURL(server), Server: server}
+			byServer[server] = planned
+		}
+		planned.Queries++
+	}
+	servers := make([]*plannedServer, 0, len(byServer))
+	queriesByRegistry := map[string]int{}
+	total := 0
+	for _, planned := range byServer {
+		servers = append(servers, planned)
+		queriesByRegistry[planned.Registry] += planned.Queries
+		total += planned.Queries
+	}
+	sort.Slice(servers, func(i, j int) bool {
+		if servers[i].Queries != servers[j].Queries {
+			return servers[i].Queries > servers[j].Queries
+		}
+		return servers[i].Server < servers[j].Server
+	})
+
+	// The run takes as long as its slowest constraint: the workers working
+	// through every query, one server's rate limit, a budget's windows or
+	// the spread window.
+	estimate := time.Duration(math.Ceil(float64(total)/float64(*concurrency))) * *queryTime
+	bound := fmt.Sprintf("%d queries at %s each over %d workers", total, *queryTime, *concurrency)
+	if *spread > estimate {
+		estimate, bound = *spread, "the -spread window"
+	}
+	fmt.Printf("%-10s %-44s %8s %12s\n", "registry", "server", "queries", "rate-limited")
+	for _, planned := range servers {
+		limited := "-"
+		if options.RateLimit > 0 {
+			duration := time.Duration(float64(planned.Queries-1) / options.RateLimit * float64(time.Second)).Round(time.Second)
+			limited = duration.String()
+			if duration > estimate {
+				estimate, bound = duration, fmt.Sprintf("the -rate-limit of %s", planned.Server)
+			}
+		}
+		fmt.Printf("%-10s %-44s %8d %12s\n", planned.Registry, planned.Server, planned.Queries, limited)
+	}
+	now := time.Now()
+	for _, budget := range options.Budgets {
+		queries := 0
+		for registry, count := range queriesByRegistry {
+			if strings.EqualFold(registry, budget.Registry) {
+				queries += count
+			}
+		}
+		plan := budget.evaluate(queries, options.DeferOverBudget, now)
+		fmt.Printf("budget %s=%d/%s: %d queries, %d left this %s", budget.Registry, budget.Limit, budget.Period, queries, plan.Remaining, budget.Period)
+		switch {
+		case plan.Refused > 0:
+			fmt.Printf(", %d would be refused (see -budget-defer)\n", plan.Refused)
+		case plan.Wait > 0:
+			fmt.Printf(", deferring to later windows for %s\n", plan.Wait.Round(time.Second))
+			if plan.Wait > estimate {
+				estimate, bound = plan.Wait, fmt.Sprintf("the %s budget", budget.Registry)
+			}
+		default:
+			fmt.Println()
+		}
+	}
+	reasons := make([]string, 0, len(unqueried))
+	for reason := range unqueried {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Printf("not queried: %d (%s)\n", unqueried[reason], reason)
+	}
+	fmt.Printf("\n%d targets, %d queries to %d servers; estimated run time %s, bound by %s\n",
+		len(targets), total, len(servers), estimate.Round(time.Second), bound)
+	fmt.Println("(a lookup can take more queries: a second query format after 404, retries and entity follow-ups)")
+	return 0
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+	rdap "github.com/openrdap/rdap"
+)
+
+// profileDocument describes the delegation and hosting chain of a domain:
+// its registration, the addresses it resolves to, the networks holding
+// those addresses and the ASNs originating them.
+type profileDocument struct {
+	Domain    profileDomain    `json:"domain"`
+	Addresses []profileAddress `json:"addresses"`
+	Autnums   []profileAutnum  `json:"autnums"`
+	Generated time.Time        `json:"generated"`
+}
+
+type profileDomain struct {
+	Name        string   `json:"name"`
+	Handle      string   `json:"handle,omitempty"`
+	Registrar   string   `json:"registrar,omitempty"`
+	Status      []string `json:"status,omitempty"`
+	Nameservers []string `json:"nameservers,omitempty"`
+	Registered  string   `json:"registered,omitempty"`
+	Expires     string   `json:"expires,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	// ValidUntil is when to refresh the entry, from the registry's caching
+	// headers (see validUntil).
+	ValidUntil time.Time `json:"valid_until,omitzero"`
+	// Raw is the untouched RDAP document (--include-raw).
+	Raw json.RawMessage `json:"raw,omitempty"`
+	// HomographSuspect is set when the domain or registrar name mixes
+	// scripts or uses confusable letters (see homographSuspect).
+	HomographSuspect bool   `json:"homograph_suspect,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+type profileAddress struct {
+	Address      string          `json:"address"`
+	Network      *profileNetwork `json:"network,omitempty"`
+	OriginASNs   []int64         `json:"origin_asns,omitempty"`
+	OriginSource string          `json:"origin_source,omitempty"` // "rdap" (ARIN originas0) or "cymru"
+	Error        string          `json:"error,omitempty"`
+}
+
+type profileNetwork struct {
+	Handle       string          `json:"handle"`
+	Name         string          `json:"name,omitempty"`
+	StartAddress string          `json:"start_address"`
+	EndAddress   string          `json:"end_address"`
+	Country      string          `json:"country,omitempty"`
+	Registry     string          `json:"registry,omitempty"`
+	URL          string          `json:"url,omitempty"`
+	ValidUntil   time.Time       `json:"valid_until,omitzero"`
+	Raw          json.RawMessage `json:"raw,omitempty"`
+}
+
+type profileAutnum struct {
+	ASN        int64           `json:"asn"`
+	Name       string          `json:"name,omitempty"`
+	Handle     string          `json:"handle,omitempty"`
+	Country    string          `json:"country,omitempty"`
+	Registry   string          `json:"registry,omitempty"`
+	URL        string          `json:"url,omitempty"`
+	ValidUntil time.Time       `json:"valid_until,omitzero"`
+	Raw        json.RawMessage `json:"raw,omitempty"`
+	// HomographSuspect is set when the organization name mixes scripts or
+	// uses confusable letters.
+	HomographSuspect bool   `json:"homograph_suspect,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+func runProfile(args []string) int {
+	flagSet := newFlagSet("profile")
+	var options clientOptions
+	options.registerFlags(flagSet)
+	includeRaw := flagSet.Bool("include-raw", false, "embed the untouched RDAP document of each object as \"raw\" next to the normalized fields")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . profile [flags] <domain>")
+		flagSet.PrintDefaults()
+	}
+	targets, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	if err := options.validate(); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if len(targets) != 1 {
+		flagSet.Usage()
+		return 2
+	}
+
+	client := newRDAPClient(options)
+	document := profileDocument{Generated: time.Now().UTC()}
+	document.Domain = profileDomainLookup(client, strings.TrimSuffix(strings.ToLower(targets[0]), "."))
+
+	addresses, err := resolveProfileAddresses(targets[0], options.network())
+	if err != nil {
+		slog.Warn("address resolution failed", "domain", targets[0], "error", err)
+	}
+	var asns []int64
+	for _, address := range addresses {
+		entry := profileNetworkLookup(client, address)
+		document.Addresses = append(document.Addresses, entry)
+		for _, asn := range entry.OriginASNs {
+			if !slices.Contains(asns, asn) {
+				asns = append(asns, asn)
+			}
+		}
+	}
+	slices.Sort(asns)
+	for _, asn := range asns {
+		document.Autnums = append(document.Autnums, profileAutnumLookup(client, asn, options.Verbosity))
+	}
+
+	if !*includeRaw {
+		document.stripRaw()
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		fmt.Printf("profile: %v\n", err)
+		return 1
+	}
+	if document.Domain.Error != "" && len(document.Addresses) == 0 {
+		return 1
+	}
+	return 0
+}
+
+// stripRaw drops the raw documents kept by the lookups.
+func (d *profileDocument) stripRaw() {
+	d.Domain.Raw = nil
+	for _, address := range d.Addresses {
+		if address.Network != nil {
+			address.Network.Raw = nil
+		}
+	}
+	for index := range d.Autnums {
+		d.Autnums[index].Raw = nil
+	}
+}
+
+// rawDocument returns body for embedding as JSON, or nil when it is not
+// valid JSON.
+func rawDocument(body []byte) json.RawMessage {
+	if !json.Valid(body) {
+		return nil
+	}
+	return json.RawMessage(body)
+}
+
+func profileDomainLookup(client *rdap.Client, name string) profileDomain {
+	profile := profileDomain{Name: name}
+	response, err := doRDAP(client, rdap.NewRequest(rdap.DomainRequest, name))
+	if lastExchange := lastHTTPExchange(response); lastExchange != nil {
+		profile.URL, profile.ValidUntil = lastExchange.URL, validUntil(lastExchange, time.Now())
+		profile.Raw = rawDocument(lastExchange.Body)
+	}
+	if err != nil {
+		profile.Error = err.Error()
+		return profile
+	}
+	domain, ok := response.Object.(*rdap.Domain)
+	if !ok {
+		profile.Error = fmt.Sprintf("unexpected RDAP response type %T", response.Object)
+		return profile
+	}
+	details := domainDetailsOf(domain)
+	profile.Handle, profile.Status, profile.Registrar = domain.Handle, details.Status, details.Registrar
+	profile.Registered, profile.Expires = details.Created, details.Expires
+	for _, nameserver := range domain.Nameservers {
+		profile.Nameservers = append(profile.Nameservers, strings.ToLower(nameserver.LDHName))
+	}
+	for _, extracted := range []string{domain.UnicodeName, domain.LDHName, profile.Registrar} {
+		if suspect, _ := homographSuspect(extracted); suspect {
+			profile.HomographSuspect = true
+		}
+	}
+	return profile
+}
+
+// resolveProfileAddresses returns the A and AAAA records of name, honouring
+// -4 and -6.
+func resolveProfileAddresses(name, network string) ([]netip.Addr, error) {
+	ipNetwork := map[string]string{"tcp": "ip", "tcp4": "ip4", "tcp6": "ip6"}[network]
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupNetIP(ctx, ipNetwork, name)
+	for index := range addresses {
+		addresses[index] = addresses[index].Unmap()
+	}
+	slices.SortFunc(addresses, func(a, b netip.Addr) int { return a.Compare(b) })
+	return slices.Compact(addresses), err
+}
+
+func profileNetworkLookup(client *rdap.Client, address netip.Addr) profileAddress {
+	entry := profileAddress{Address: address.String()}
+	response, err := doRDAP(client, rdap.NewRequest(rdap.IPRequest, address.String()))
+	if err != nil {
+		entry.Error = err.Error()
+	} else if network, ok := response.Object.(*rdap.IPNetwork); ok {
+		entry.Network = &profileNetwork{
+			Handle:       network.Handle,
+			Name:         network.Name,
+			StartAddress: network.StartAddress,
+			EndAddress:   network.EndAddress,
+			Country:      network.Country,
+		}
+		if lastExchange := lastHTTPExchange(response); lastExchange != nil {
+			entry.Network.URL = lastExchange.URL
+			entry.Network.ValidUntil = validUntil(lastExchange, time.Now())
+			entry.Network.Raw = rawDocument(lastExchange.Body)
+			entry.Network.Registry = registryForRawURL(lastExchange.URL)
+			entry.OriginASNs = originAutnums(lastExchange.Body)
+		}
+	}
+	if len(entry.OriginASNs) > 0 {
+		entry.OriginSource = "rdap"
+		return entry
+	}
+	origins, cymruErr := cymruOriginASNs(address)
+	if cymruErr != nil {
+		slog.Debug("origin ASN lookup failed", "address", address, "error", cymruErr)
+		return entry
+	}
+	entry.OriginASNs, entry.OriginSource = origins, "cymru"
+	return entry
+}
+
+// originAutnums reads ARIN's originas0 extension, which lists the ASNs
+// originating a network, from a raw IP network response.
+func originAutnums(rawBody []byte) []int64 {
+	var extension struct {
+		OriginAutnums []int64 `json:"arin_originas0_originautnums"`
+	}
+	if json.Unmarshal(rawBody, &extension) != nil {
+		return nil
+	}
+	return extension.OriginAutnums
+}
+
+// cymruOriginASNs asks Team Cymru's IP-to-ASN DNS service which ASNs
+// originate the prefix covering address.
+func cymruOriginASNs(address netip.Addr) ([]int64, error) {
+	var query string
+	if address.Is4() {
+		octets := address.As4()
+		query = fmt.Sprintf("%d.%d.%d.%d.origin.asn.cymru.com", octets[3], octets[2], octets[1], octets[0])
+	} else {
+		hexDigits := fmt.Sprintf("%x", address.As16())
+		nibbles := make([]string, 0, len(hexDigits))
+		for index := len(hexDigits) - 1; index >= 0; index-- {
+			nibbles = append(nibbles, hexDigits[index:index+1])
+		}
+		query = strings.Join(nibbles, ".") + ".origin6.asn.cymru.com"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	records, err := net.DefaultResolver.LookupTXT(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	// Each record reads "15169 | 8.8.8.0/24 | US | arin | 1992-12-01"; the
+	// first field may list several ASNs separated by spaces.
+	var asns []int64
+	for _, record := range records {
+		asnField, _, _ := strings.Cut(record, "|")
+		for _, field := range strings.Fields(asnField) {
+			if asn, err := strconv.ParseInt(field, 10, 64); err == nil && !slices.Contains(asns, asn) {
+				asns = append(asns, asn)
+			}
+		}
+	}
+	return asns, nil
+}
+
+func profileAutnumLookup(client *rdap.Client, asn int64, verbosity int) profileAutnum {
+	profile := profileAutnum{ASN: asn}
+	name, fetch, err := rdapASNLookup(context.Background(), client, asn, verbosity, rdaplookup.DefaultFollowUps)
+	result := lookupResult{Target: fmt.Sprintf("AS%d", asn), Name: name, Fetch: fetch, Err: err}
+	profile.Name, profile.Country, profile.Registry = name, result.country(), result.registry()
+	profile.HomographSuspect, _ = homographSuspect(name)
+	if fetch != nil {
+		profile.URL, profile.ValidUntil, profile.Raw = fetch.URL, fetch.ValidUntil, rawDocument(fetch.RawBody)
+		if fetch.Record != nil {
+			profile.Handle = fetch.Record.Handle
+		}
+	}
+	if err != nil {
+		profile.Error = err.Error()
+	}
+	return profile
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// progressInterval is how often the progress bar is redrawn.
+const progressInterval = 250 * time.Millisecond
+
+// progressBarWidth is the width of the bar itself, in columns.
+const progressBarWidth = 24
+
+// progressBar shows how far a batch run is on stderr: the rows done of the
+// total, the rate, the estimated time left and the failed lookups. It is
+// drawn on one line rewritten in place; results and diagnostics written to
+// the terminal erase it and draw it again below them (see above). A nil
+// progressBar shows nothing.
+type progressBar struct {
+	mutex  sync.Mutex
+	total  int
+	done   int
+	errors int
+	start  time.Time
+	// width is the length of the line on screen; 0 when none is.
+	width    int
+	finished bool
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// activeProgress is the progress bar of the running batch, which the
+// diagnostic logger writes above.
+var activeProgress *progressBar
+
+// startProgress starts redrawing a progress bar for total rows, unless
+// quiet is set or stderr is not a terminal; it then returns nil.
+func startProgress(total int, quiet bool) *progressBar {
+	if quiet || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+	p := &progressBar{total: total, start: time.Now(), stop: make(chan struct{}), stopped: make(chan struct{})}
+	activeProgress = p
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.mutex.Lock()
+				p.draw()
+				p.mutex.Unlock()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// add counts a finished row and its failed lookups.
+func (p *progressBar) add(results []lookupResult) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.done++
+	for _, result := range results {
+		if result.Err != nil {
+			p.errors++
+		}
+	}
+}
+
+// above runs write, which prints results, with the bar erased, and draws
+// the bar again below them.
+func (p *progressBar) above(write func()) {
+	if p == nil {
+		write()
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.erase()
+	write()
+	p.draw()
+}
+
+// finish stops redrawing and erases the bar.
+func (p *progressBar) finish() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.stopped
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.erase()
+	p.finished = true
+}
+
+// draw rewrites the bar line. The caller holds the mutex.
+func (p *progressBar) draw() {
+	if p.finished {
+		return
+	}
+	line := p.line(time.Since(p.start))
+	if columns, _, err := term.GetSize(int(os.Stderr.Fd())); err == nil && columns > 0 && len(line) >= columns {
+		line = line[:max(columns-1, 0)]
+	}
+	// Spaces, not an erase sequence, clear the rest of a longer previous
+	// line, so dumb terminals show the bar too.
+	padding := strings.Repeat(" ", max(p.width-len(line), 0))
+	fmt.Fprint(os.Stderr, "\r"+line+padding+"\r")
+	p.width = len(line)
+}
+
+// erase clears the bar line. The caller holds the mutex.
+func (p *progressBar) erase() {
+	if p.width == 0 {
+		return
+	}
+	fmt.Fprint(os.Stderr, "\r"+strings.Repeat(" ", p.width)+"\r")
+	p.width = 0
+}
+
+// line renders the bar after elapsed, e.g.
+// "[######------] 120/5000 2% 3.1/s ETA 26m12s 7 errors". It is ASCII, so
+// its length in bytes is its width in columns.
+func (p *progressBar) line(elapsed time.Duration) string {
+	filled := progressBarWidth
+	if p.total > 0 {
+		filled = progressBarWidth * min(p.done, p.total) / p.total
+	}
+	var line strings.Builder
+	line.WriteString("[" + strings.Repeat("#", filled) + strings.Repeat("-", progressBarWidth-filled) + "]")
+	fmt.Fprintf(&line, " %d/%d", p.done, p.total)
+	if p.total > 0 {
+		fmt.Fprintf(&line, " %d%%", 100*p.done/p.total)
+	}
+	if p.done > 0 && elapsed > 0 {
+		rate := float64(p.done) / elapsed.Seconds()
+		fmt.Fprintf(&line, " %.1f/s", rate)
+		if left := p.total - p.done; left > 0 {
+			eta := time.Duration(float64(left) / rate * float64(time.Second))
+			fmt.Fprintf(&line, " ETA %s", eta.Round(time.Second))
+		}
+	}
+	if p.errors > 0 {
+		fmt.Fprintf(&line, " %d errors", p.errors)
+	}
+	return line.String()
+}
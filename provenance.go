@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"time"
+)
+
+// resultProvenance is the detached signature attached to a result by -sign.
+// The signature covers provenanceStatement, which binds the target, the URL
+// the answer came from, when it was retrieved and the SHA-256 of the raw
+// response body exactly as received.
+type resultProvenance struct {
+	Target      string    `json:"target"`
+	URL         string    `json:"url"`
+	RetrievedAt time.Time `json:"retrieved_at"`
+	SHA256      string    `json:"sha256"`
+	Algorithm   string    `json:"algorithm"` // ed25519, ecdsa-sha256 or rsa-pkcs1v15-sha256
+	KeyID       string    `json:"key_id"`    // first 16 hex digits of the SHA-256 of the public key (PKIX DER)
+	Signature   string    `json:"signature"` // base64
+}
+
+// provenanceStatement is the exact byte string that is signed.
+func provenanceStatement(target, rdapURL string, retrievedAt time.Time, digest string) []byte {
+	return fmt.Appendf(nil, "rdap-test provenance v1\ntarget: %s\nurl: %s\nretrieved: %s\nsha256: %s\n",
+		target, rdapURL, retrievedAt.UTC().Format(time.RFC3339), digest)
+}
+
+// provenanceSigner signs results with the private key given to -sign.
+type provenanceSigner struct {
+	key       crypto.Signer
+	algorithm string
+	keyID     string
+}
+
+// loadProvenanceSigner reads a PEM private key: PKCS#8 (Ed25519, ECDSA or
+// RSA), SEC 1 EC or PKCS#1 RSA.
+func loadProvenanceSigner(path string) (*provenanceSigner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+	var parsed any
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	signer := &provenanceSigner{}
+	switch key := parsed.(type) {
+	case ed25519.PrivateKey:
+		signer.key, signer.algorithm = key, "ed25519"
+	case *ecdsa.PrivateKey:
+		signer.key, signer.algorithm = key, "ecdsa-sha256"
+	case *rsa.PrivateKey:
+		signer.key, signer.algorithm = key, "rsa-pkcs1v15-sha256"
+	default:
+		return nil, fmt.Errorf("%s: unsupported key type %T", path, parsed)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(signer.key.Public())
+	if err != nil {
+		return nil, err
+	}
+	keyDigest := sha256.Sum256(publicKey)
+	signer.keyID = hex.EncodeToString(keyDigest[:8])
+	return signer, nil
+}
+
+// sign returns the provenance of a raw response retrieved from rdapURL.
+func (s *provenanceSigner) sign(target, rdapURL string, rawBody []byte, retrievedAt time.Time) (*resultProvenance, error) {
+	bodyDigest := sha256.Sum256(rawBody)
+	provenance := &resultProvenance{
+		Target:      target,
+		URL:         rdapURL,
+		RetrievedAt: retrievedAt.UTC().Truncate(time.Second),
+		SHA256:      hex.EncodeToString(bodyDigest[:]),
+		Algorithm:   s.algorithm,
+		KeyID:       s.keyID,
+	}
+	statement := provenanceStatement(target, rdapURL, provenance.RetrievedAt, provenance.SHA256)
+	var signature []byte
+	var err error
+	if s.algorithm == "ed25519" {
+		signature, err = s.key.Sign(rand.Reader, statement, crypto.Hash(0))
+	} else {
+		statementDigest := sha256.Sum256(statement)
+		signature, err = s.key.Sign(rand.Reader, statementDigest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, err
+	}
+	provenance.Signature = base64.StdEncoding.EncodeToString(signature)
+	return provenance, nil
+}
+
+// line renders the provenance below a result line, with every field needed
+// to rebuild and verify the signed statement.
+func (p *resultProvenance) line() string {
+	return fmt.Sprintf("  provenance: %s retrieved %s sha256:%s %s:%s key %s",
+		p.URL, p.RetrievedAt.Format(time.RFC3339), p.SHA256, p.Algorithm, p.Signature, p.KeyID)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+)
+
+// resultRecord is the structured form of a result emitted by -json, one
+// object per line. asn, name, rir, handle, country and error are always
+// present (empty, 0 or null when unknown); the rest only when they apply,
+// e.g. error_class, http_status and error_code for failed lookups.
+type resultRecord struct {
+	ASN              int64              `json:"asn"`
+	Target           string             `json:"target"`
+	Vantage          string             `json:"vantage,omitempty"`
+	Line             int                `json:"line,omitempty"`
+	Name             string             `json:"name"`
+	RIR              string             `json:"rir"`
+	Handle           string             `json:"handle"`
+	Country          string             `json:"country"`
+	Error            *string            `json:"error"`
+	ErrorClass       string             `json:"error_class,omitempty"`
+	HTTPStatus       int                `json:"http_status,omitempty"`
+	ErrorCode        int                `json:"error_code,omitempty"`
+	Skipped          bool               `json:"skipped,omitempty"`
+	Overridden       bool               `json:"overridden,omitempty"`
+	Source           string             `json:"source,omitempty"`
+	CacheAgeSeconds  *int64             `json:"cache_age_seconds,omitempty"`
+	HomographSuspect bool               `json:"homograph_suspect,omitempty"`
+	Domain           *domainDetails     `json:"domain,omitempty"`
+	Network          *networkDetails    `json:"network,omitempty"`
+	Nameserver       *nameserverDetails `json:"nameserver,omitempty"`
+	Entity           *entityDetails     `json:"entity,omitempty"`
+	Historical       *historicalAnswer  `json:"historical,omitempty"`
+	Whois            *whoisAnswer       `json:"whois,omitempty"`
+	Class            string             `json:"class,omitempty"`
+	Server           string             `json:"server,omitempty"`
+	Port43           string             `json:"port43,omitempty"`
+	URL              string             `json:"url,omitempty"`
+	ValidUntil       time.Time          `json:"valid_until,omitzero"`
+	Provenance       *resultProvenance  `json:"provenance,omitempty"`
+	Networks         []originNetwork    `json:"networks,omitempty"`
+	Tags             []string           `json:"tags,omitempty"`
+	Annotations      map[string]string  `json:"annotations,omitempty"`
+	Contacts         []contact          `json:"contacts,omitempty"`
+	Attempts         []attemptRecord    `json:"attempts,omitempty"`
+	// Redirects are the HTTP hops of a redirect followed to the answer.
+	Redirects []redirectRecord `json:"redirects,omitempty"`
+	Warnings  []string         `json:"warnings,omitempty"`
+	// Raw is the untouched RDAP document (-include-raw).
+	Raw json.RawMessage `json:"raw,omitempty"`
+}
+
+// record returns the structured form of the result, embedding the raw RDAP
+// document when includeRaw is set.
+func (r lookupResult) record(includeRaw bool) resultRecord {
+	record := resultRecord{
+		Target:      r.Target,
+		Vantage:     r.Vantage,
+		Line:        r.Line,
+		Name:        r.Name,
+		RIR:         r.registry(),
+		Country:     r.country(),
+		Skipped:     r.Skipped,
+		Overridden:  r.Overridden,
+		Source:      r.source(),
+		Domain:      r.Domain,
+		Network:     r.Network,
+		Nameserver:  r.Nameserver,
+		Entity:      r.Entity,
+		Historical:  r.Historical,
+		Whois:       r.Whois,
+		Provenance:  r.Provenance,
+		Networks:    r.OriginNetworks,
+		Tags:        r.Tags,
+		Annotations: r.Annotations,
+		Class:       r.Class,
+		Server:      r.server(),
+		Port43:      r.port43(),
+		Contacts:    r.contacts(),
+		Warnings:    r.warnings(),
+	}
+	if r.Domain == nil && r.Network == nil && r.Nameserver == nil && r.Entity == nil {
+		if asn, err := parseASN(r.Target); err == nil {
+			record.ASN = asn
+		}
+	}
+	record.Attempts = attemptRecords(r.attempts())
+	if r.Fetch != nil {
+		record.Redirects = redirectRecords(r.Fetch.Redirects.followed())
+	}
+	if r.Err != nil {
+		message := r.Err.Error()
+		record.Error = &message
+		details := r.errorDetails()
+		record.ErrorClass, record.HTTPStatus, record.ErrorCode = details.Class, details.Status, details.ErrorCode
+	}
+	if !r.CachedAt.IsZero() {
+		age := int64(r.cacheAge(time.Now()) / time.Second)
+		record.CacheAgeSeconds = &age
+	}
+	if !r.Overridden {
+		record.HomographSuspect, _ = homographSuspect(r.Name)
+	}
+	if r.Network != nil {
+		record.Handle = r.Network.Handle
+	}
+	if r.Nameserver != nil {
+		record.Handle = r.Nameserver.Handle
+	}
+	if r.Entity != nil {
+		record.Handle = r.Entity.Handle
+	}
+	if r.Whois != nil {
+		record.Handle = r.Whois.Handle
+	}
+	if r.Fetch != nil {
+		record.URL, record.ValidUntil = r.Fetch.URL, r.Fetch.ValidUntil
+		if r.Fetch.Record != nil {
+			record.Handle = r.Fetch.Record.Handle
+		}
+		if includeRaw {
+			record.Raw = rawDocument(r.Fetch.RawBody)
+		}
+	}
+	return record
+}
+
+// attemptRecord is one HTTP request of a lookup in structured output.
+type attemptRecord struct {
+	Format     string `json:"format"`   // query format, e.g. "15169" or "AS15169"
+	Endpoint   string `json:"endpoint"` // scheme and host of the server asked
+	Status     int    `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+func attemptRecords(attempts []rdaplookup.Attempt) []attemptRecord {
+	var records []attemptRecord
+	for _, attempt := range attempts {
+		records = append(records, attemptRecord{
+			Format:     attempt.Query,
+			Endpoint:   endpointOf(attempt.URL),
+			Status:     attempt.Status,
+			Error:      attempt.Error,
+			DurationMS: attempt.Duration.Milliseconds(),
+		})
+	}
+	return records
+}
+
+// redirectRecord is one HTTP hop of a redirect chain in a -json record.
+type redirectRecord struct {
+	URL       string `json:"url"`
+	Status    int    `json:"status"`
+	Location  string `json:"location,omitempty"` // where a 30x pointed
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+func redirectRecords(hops []redirectHop) []redirectRecord {
+	var records []redirectRecord
+	for _, hop := range hops {
+		records = append(records, redirectRecord{URL: hop.URL, Status: hop.StatusCode, Location: hop.Location, LatencyMS: hop.Latency.Milliseconds()})
+	}
+	return records
+}
+
+// attempts returns the attempts of the result, which lookups outside the
+// batch loop leave on the fetch only.
+func (r lookupResult) attempts() []rdaplookup.Attempt {
+	if len(r.Attempts) > 0 {
+		return r.Attempts
+	}
+	return r.Fetch.attempts()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// redirectHop is one HTTP exchange on the way to an RDAP answer.
+type redirectHop struct {
+	URL        string        `json:"url"`
+	StatusCode int           `json:"status"`
+	Location   string        `json:"location,omitempty"`
+	Latency    time.Duration `json:"latency_ns"`
+}
+
+// redirectChain collects the hops of every request issued under one context.
+type redirectChain struct {
+	mutex sync.Mutex
+	hops  []redirectHop
+}
+
+type redirectChainKey struct{}
+
+// withRedirectChain returns a context under which every HTTP hop made by a
+// client built with newRDAPClient is appended to the returned chain.
+func withRedirectChain(ctx context.Context) (context.Context, *redirectChain) {
+	chain := &redirectChain{}
+	return context.WithValue(ctx, redirectChainKey{}, chain), chain
+}
+
+func (c *redirectChain) add(hop redirectHop) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.hops = append(c.hops, hop)
+}
+
+// Hops returns a copy of the recorded hops in order.
+func (c *redirectChain) Hops() []redirectHop {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]redirectHop(nil), c.hops...)
+}
+
+// redirectCount is the number of 30x responses in the chain.
+func (c *redirectChain) redirectCount() int {
+	count := 0
+	for _, hop := range c.Hops() {
+		if isRedirectStatus(hop.StatusCode) {
+			count++
+		}
+	}
+	return count
+}
+
+// followed returns the hops of the first redirect followed: from the first
+// 30x response through the response it finally led to. Requests made
+// before it, such as bootstrap downloads, and after it, such as entity
+// follow-ups, are left out; no redirect returns nil.
+func (c *redirectChain) followed() []redirectHop {
+	hops := c.Hops()
+	for start, hop := range hops {
+		if !isRedirectStatus(hop.StatusCode) {
+			continue
+		}
+		for end := start + 1; end < len(hops); end++ {
+			if !isRedirectStatus(hops[end].StatusCode) {
+				return hops[start : end+1]
+			}
+		}
+		return hops[start:]
+	}
+	return nil
+}
+
+// problems flags chains with more than maxRedirects redirects or that visit
+// the same URL twice.
+func (c *redirectChain) problems(maxRedirects int) []string {
+	var problems []string
+	if count := c.redirectCount(); count > maxRedirects {
+		problems = append(problems, fmt.Sprintf("%d redirects (more than %d)", count, maxRedirects))
+	}
+	seen := map[string]bool{}
+	for _, hop := range c.Hops() {
+		if !isRedirectStatus(hop.StatusCode) {
+			continue
+		}
+		if seen[hop.URL] {
+			problems = append(problems, "redirect loop through "+hop.URL)
+			break
+		}
+		seen[hop.URL] = true
+	}
+	return problems
+}
+
+// logHops logs the chain one hop per record at debug level.
+func (c *redirectChain) logHops(query string) {
+	for index, hop := range c.Hops() {
+		slog.Debug("redirect hop", "query", query, "hop", index+1, "status", hop.StatusCode,
+			"url", hop.URL, "latency", hop.Latency, "location", hop.Location)
+	}
+}
+
+func isRedirectStatus(statusCode int) bool {
+	return statusCode >= 300 && statusCode <= 399
+}
+
+// hopTransport records each round trip into the redirect chain carried by the
+// request context, if any. http.Client calls the transport once per hop.
+type hopTransport struct {
+	base http.RoundTripper
+}
+
+func (t *hopTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	chain, _ := request.Context().Value(redirectChainKey{}).(*redirectChain)
+	start := time.Now()
+	response, err := t.base.RoundTrip(request)
+	if chain != nil && err == nil {
+		chain.add(redirectHop{
+			URL:        request.URL.String(),
+			StatusCode: response.StatusCode,
+			Location:   response.Header.Get("Location"),
+			Latency:    time.Since(start),
+		})
+	}
+	return response, err
+}
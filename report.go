@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+)
+
+// reportTemplates are the built-in report templates. A file named
+// <name>.tmpl in ~/.config/rdap-tester/templates replaces the built-in of the
+// same name, and --template also accepts a path to any template file.
+var reportTemplates = map[string]string{
+	"abuse": `To: {{if .AbuseEmails}}{{join .AbuseEmails ", "}}{{else}}[NO ABUSE CONTACT FOUND - check {{.RDAPURL}}]{{end}}
+Subject: Abuse report for {{.Target}} ({{.Name}})
+
+Hello {{if .AbuseName}}{{.AbuseName}}{{else}}{{.Name}} abuse team{{end}},
+
+We are reporting abusive traffic originating from your network:
+
+  Autonomous system: {{.Target}}
+  Organization:      {{.Name}}
+  Handle:            {{.Handle}}
+  Country:           {{.Country}}
+  Registry:          {{.Registry}}
+
+Evidence:
+{{- if .Evidence}}
+{{.Evidence}}
+{{- else}}
+  [DESCRIBE THE ABUSE: type, first and last seen (UTC), source addresses]
+  [ATTACH LOGS with timestamps and time zone]
+{{- end}}
+
+Please investigate and take appropriate action.
+
+Regards,
+[YOUR NAME]
+[YOUR ORGANIZATION / CONTACT]
+
+--
+Contact data from {{.RDAPURL}} on {{.Date}}.
+{{- if .AbusePhones}}
+Abuse phone: {{join .AbusePhones ", "}}
+{{- end}}
+`,
+}
+
+// reportData is what report templates can refer to.
+type reportData struct {
+	Target      string
+	Name        string
+	Handle      string
+	Country     string
+	Registry    string
+	RDAPURL     string
+	AbuseName   string
+	AbuseEmails []string
+	AbusePhones []string
+	Evidence    string
+	Date        string
+}
+
+func runReport(args []string) int {
+	flagSet := newFlagSet("report")
+	var options clientOptions
+	options.registerFlags(flagSet)
+	templateName := flagSet.String("template", "abuse", "template `name` (built-in: abuse, or ~/.config/rdap-tester/templates/<name>.tmpl) or path to a template file")
+	evidencePath := flagSet.String("evidence", "", "`file` whose contents fill the evidence section instead of placeholders")
+	outputPath := flagSet.String("output", "", "write the report to `file` instead of stdout")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . report [flags] <ASN> [--template abuse]")
+		flagSet.PrintDefaults()
+	}
+	targets, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	if err := options.validate(); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if len(targets) != 1 {
+		flagSet.Usage()
+		return 2
+	}
+	asn, err := parseASN(targets[0])
+	if err != nil {
+		fmt.Printf("%s: invalid ASN: %v\n", targets[0], err)
+		return 2
+	}
+	reportTemplate, err := loadReportTemplate(*templateName)
+	if err != nil {
+		fmt.Printf("report: %v\n", err)
+		return 2
+	}
+	data := reportData{Target: fmt.Sprintf("AS%d", asn), Date: time.Now().UTC().Format(time.RFC3339)}
+	if *evidencePath != "" {
+		evidence, err := os.ReadFile(*evidencePath)
+		if err != nil {
+			fmt.Printf("report: %v\n", err)
+			return 2
+		}
+		data.Evidence = strings.TrimRight(string(evidence), "\n")
+	}
+
+	name, fetch, err := rdapASNLookup(context.Background(), newRDAPClient(options), asn, options.Verbosity, rdaplookup.DefaultFollowUps)
+	if err != nil {
+		fmt.Printf("AS%d: error: %v\n", asn, err)
+		return 1
+	}
+	result := lookupResult{Target: data.Target, Name: name, Fetch: fetch}
+	data.Name, data.Country, data.Registry = name, result.country(), result.registry()
+	if fetch != nil && fetch.Record != nil {
+		data.Handle, data.RDAPURL = fetch.Record.Handle, fetch.URL
+		data.AbuseName, data.AbuseEmails, data.AbusePhones = abuseContact(contactsByHandle(fetch.Record.Entities))
+	}
+	if len(data.AbuseEmails) == 0 {
+		fmt.Fprintf(os.Stderr, "report: AS%d has no abuse contact in its RDAP record; fill in the recipient by hand\n", asn)
+	}
+
+	var output io.Writer = os.Stdout
+	if *outputPath != "" {
+		file, err := os.Create(*outputPath)
+		if err != nil {
+			fmt.Printf("report: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		output = file
+	}
+	if err := reportTemplate.Execute(output, data); err != nil {
+		fmt.Printf("report: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// abuseContact merges the contact data of every entity with the abuse role.
+func abuseContact(contacts map[string]entityContact) (name string, emails, phones []string) {
+	handles := make([]string, 0, len(contacts))
+	for handle := range contacts {
+		handles = append(handles, handle)
+	}
+	sort.Strings(handles)
+	for _, handle := range handles {
+		contact := contacts[handle]
+		if !slices.Contains(contact.Roles, "abuse") {
+			continue
+		}
+		if name == "" {
+			name = contact.Name
+		}
+		for _, email := range contact.Emails {
+			if !slices.Contains(emails, email) {
+				emails = append(emails, email)
+			}
+		}
+		for _, phone := range contact.Phones {
+			if !slices.Contains(phones, phone) {
+				phones = append(phones, phone)
+			}
+		}
+	}
+	return name, emails, phones
+}
+
+// loadReportTemplate resolves a template name to the user's template file,
+// the built-in of that name, or a template file path, in that order.
+func loadReportTemplate(name string) (*template.Template, error) {
+	functions := template.FuncMap{"join": strings.Join, "upper": strings.ToUpper}
+	source, ok := reportTemplates[name]
+	if userPath, err := configFilePath(filepath.Join("templates", name+".tmpl")); err == nil {
+		if data, err := os.ReadFile(userPath); err == nil {
+			source, ok = string(data), true
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if !ok {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("unknown template %q (built-in: abuse)", name)
+		}
+		source = string(data)
+	}
+	return template.New(name).Funcs(functions).Parse(source)
+}
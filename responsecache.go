@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultCacheTTL is how long cached answers are served without asking the
+// registry again, unless -cache-ttl says otherwise.
+const defaultCacheTTL = 24 * time.Hour
+
+// responseCache keeps the answers of successful lookups on disk, one file
+// per vantage point, query type and value, so repeated lookups across runs
+// do not hit the registries again while the answer is younger than ttl.
+// Older answers are kept and served as stale-cache when the registry
+// cannot be reached.
+type responseCache struct {
+	dir string
+	ttl time.Duration
+	// offline answers from the cache only, whatever the age of the answer
+	// (-offline).
+	offline bool
+}
+
+// errNotCached is the result error of a lookup -offline cannot answer.
+var errNotCached = errors.New("not in the response cache (-offline)")
+
+// offlineTransport is the base transport of -offline clients: nothing
+// reaches the network, not even bootstrap downloads.
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("%s %s: refused by -offline", request.Method, request.URL.Redacted())
+}
+
+// cachedResponse is one cache file: the answer as extracted when it was
+// fetched, since extraction may have dereferenced further entities, and the
+// raw RDAP document it came from.
+type cachedResponse struct {
+	FetchedAt  time.Time          `json:"fetched_at"`
+	ValidUntil time.Time          `json:"valid_until,omitzero"`
+	URL        string             `json:"url"`
+	Name       string             `json:"name"`
+	Domain     *domainDetails     `json:"domain,omitempty"`
+	Network    *networkDetails    `json:"network,omitempty"`
+	Nameserver *nameserverDetails `json:"nameserver,omitempty"`
+	Entity     *entityDetails     `json:"entity,omitempty"`
+	Whois      *whoisAnswer       `json:"whois,omitempty"` // a -whois-fallback answer
+	Body       json.RawMessage    `json:"body,omitempty"`
+}
+
+// cacheDir, when set by -cache-dir, replaces $XDG_CACHE_HOME/rdap-tester.
+var cacheDir string
+
+// cacheHomeDir returns -cache-dir, or $XDG_CACHE_HOME/rdap-tester,
+// defaulting to ~/.cache/rdap-tester.
+func cacheHomeDir() (string, error) {
+	if cacheDir != "" {
+		return cacheDir, nil
+	}
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		cacheHome = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(cacheHome, "rdap-tester"), nil
+}
+
+// responseCacheDir returns the responses directory under cacheHomeDir.
+func responseCacheDir() (string, error) {
+	home, err := cacheHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "responses"), nil
+}
+
+// newResponseCache returns the cache for ttl, or nil (every lookup goes to
+// the registry) when disabled or when the cache directory is unknown.
+func newResponseCache(ttl time.Duration, disabled, offline bool) *responseCache {
+	if disabled {
+		return nil
+	}
+	dir, err := responseCacheDir()
+	if err != nil {
+		slog.Warn("response cache disabled", "error", err)
+		return nil
+	}
+	return &responseCache{dir: dir, ttl: ttl, offline: offline}
+}
+
+// path returns the file caching value ("15169", "192.0.2.0/24",
+// "example.com") of kind ("autnum", "ip", "domain") seen from vantage.
+func (c *responseCache) path(vantage, kind, value string) string {
+	return filepath.Join(c.dir, url.PathEscape(vantage), kind, url.PathEscape(strings.ToLower(value))+".json")
+}
+
+// lookup answers from the cache while the cached answer is fresh, and
+// otherwise runs live and caches its answer when it succeeds. When live
+// fails for any reason but the object not existing, a stale cached answer
+// is returned instead, marked stale-cache.
+func (c *responseCache) lookup(vantage, kind, value string, live func() lookupResult) lookupResult {
+	if c == nil {
+		return live()
+	}
+	path := c.path(vantage, kind, value)
+	cached, err := loadCachedResponse(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("ignoring unreadable cache entry", "path", path, "error", err)
+	}
+	if cached != nil && time.Since(cached.FetchedAt) < c.ttl {
+		return cached.result(kind, sourceCache)
+	}
+	if c.offline {
+		if cached == nil {
+			return lookupResult{Err: errNotCached}
+		}
+		return cached.result(kind, sourceStaleCache)
+	}
+	result := live()
+	switch {
+	case result.Err == nil && (result.Fetch != nil || result.Whois != nil):
+		if err := c.store(path, result); err != nil {
+			slog.Warn("response not cached", "path", path, "error", err)
+		}
+	case cached != nil && resultClass(result.Err) == classError:
+		slog.Warn("serving stale cached answer", "target", value, "fetched_at", cached.FetchedAt, "error", result.Err)
+		return cached.result(kind, sourceStaleCache)
+	}
+	return result
+}
+
+func loadCachedResponse(path string) (*cachedResponse, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, err
+	}
+	return &cached, nil
+}
+
+func (c *responseCache) store(path string, result lookupResult) error {
+	cached := cachedResponse{
+		FetchedAt:  time.Now().UTC(),
+		Name:       result.Name,
+		Domain:     result.Domain,
+		Network:    result.Network,
+		Nameserver: result.Nameserver,
+		Entity:     result.Entity,
+		Whois:      result.Whois,
+	}
+	if result.Fetch != nil {
+		cached.ValidUntil, cached.URL, cached.Body = result.Fetch.ValidUntil, result.Fetch.URL, rawDocument(result.Fetch.RawBody)
+	}
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	return writeCacheEntry(path, data)
+}
+
+// writeCacheEntry replaces the cache file at path with data.
+func writeCacheEntry(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Workers may store the same entry at once; rename keeps files whole.
+	temporary, err := os.CreateTemp(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		return err
+	}
+	if _, err := temporary.Write(data); err != nil {
+		temporary.Close()
+		os.Remove(temporary.Name())
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		os.Remove(temporary.Name())
+		return err
+	}
+	return os.Rename(temporary.Name(), path)
+}
+
+// result rebuilds the lookup result of a cached answer of kind.
+func (r *cachedResponse) result(kind, source string) lookupResult {
+	if r.Whois != nil {
+		return lookupResult{Name: r.Name, Whois: r.Whois, Source: source, CachedAt: r.FetchedAt}
+	}
+	fetch := &autnumFetch{URL: r.URL, RawBody: r.Body, ValidUntil: r.ValidUntil}
+	if kind == "autnum" && len(r.Body) > 0 {
+		fetch.Record, _ = decodeAutnum(r.Body)
+	}
+	return lookupResult{Name: r.Name, Domain: r.Domain, Network: r.Network, Nameserver: r.Nameserver, Entity: r.Entity, Fetch: fetch, Source: source, CachedAt: r.FetchedAt}
+}
+
+// purgeResponseCache removes cached answers fetched before cutoff, or all
+// of them when cutoff is zero, and returns how many it removed.
+func purgeResponseCache(cutoff time.Time) (int, error) {
+	dir, err := responseCacheDir()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if !cutoff.IsZero() {
+			if cached, err := loadCachedResponse(path); err == nil && !cached.FetchedAt.Before(cutoff) {
+				return nil
+			}
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+func runCache(args []string) int {
+	flagSet := newFlagSet("cache")
+	expired := flagSet.Bool("expired", false, "only purge answers older than -cache-ttl")
+	ttl := flagSet.Duration("cache-ttl", defaultCacheTTL, "with -expired, the `age` past which answers are purged")
+	flagSet.StringVar(&cacheDir, "cache-dir", "", "the `directory` of cached answers and snapshots (default $XDG_CACHE_HOME/rdap-tester)")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . cache purge [-expired] [-cache-ttl 24h]\n       go run . cache export bundle.tar.gz|bundle.tar.zst|bundle.tar|-\n       go run . cache import bundle.tar.gz|bundle.tar.zst|bundle.tar|-")
+		flagSet.PrintDefaults()
+	}
+	positional, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	switch {
+	case len(positional) == 2 && positional[0] == "export":
+		return runCacheExport(positional[1])
+	case len(positional) == 2 && positional[0] == "import":
+		return runCacheImport(positional[1])
+	case len(positional) != 1 || positional[0] != "purge":
+		flagSet.Usage()
+		return 2
+	}
+	var cutoff time.Time
+	if *expired {
+		cutoff = time.Now().Add(-*ttl)
+	}
+	removed, err := purgeResponseCache(cutoff)
+	if err != nil {
+		fmt.Printf("cache: %v\n", err)
+		return 1
+	}
+	fmt.Printf("purged %d cached answers\n", removed)
+	return 0
+}
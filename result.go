@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+)
+
+// lookupResult is the outcome of one batch lookup of one target through one
+// vantage point.
+type lookupResult struct {
+	Target  string // canonical target, e.g. "AS15169"
+	Label   string // target as shown in output, tagged with the vantage if any
+	Vantage string
+	// Line is the -f file or stdin line the target was read from; 0 for
+	// targets given as arguments.
+	Line int
+	Name string
+	// Overridden is set when Name came from the overrides file rather than
+	// the RDAP record.
+	Overridden bool
+	// Skipped is set for targets on the skip list, which are never queried.
+	Skipped bool
+	Fetch   *autnumFetch // last HTTP exchange; nil if none happened
+	// Attempts lists every HTTP request made for the target, so a success
+	// after transient failures can be told apart from a clean one.
+	Attempts []rdaplookup.Attempt
+	// Historical is set when the result came from an archive source
+	// (-as-of) rather than a live registry.
+	Historical *historicalAnswer
+	// Domain holds the registration facts of a -domain lookup; Fetch then
+	// carries the exchange without an autnum Record.
+	Domain *domainDetails
+	// Network holds the facts of an IP address or prefix lookup; Fetch
+	// then carries the exchange without an autnum Record.
+	Network *networkDetails
+	// Nameserver holds the facts of a nameserver lookup; Fetch then
+	// carries the exchange without an autnum Record.
+	Nameserver *nameserverDetails
+	// Entity holds the vCard details of an entity lookup; Fetch then
+	// carries the exchange without an autnum Record.
+	Entity *entityDetails
+	// Whois is the port-43 answer of a -whois-fallback result; Fetch is
+	// then nil.
+	Whois *whoisAnswer
+	// Class is the object class that answered under -classes.
+	Class string
+	// OriginNetworks are the networks the ASN originates (--list-networks).
+	OriginNetworks []originNetwork
+	// Source is where a looked-up answer came from when not live: one of
+	// the source constants; see source() for the effective value.
+	Source string
+	// CachedAt is when a cached answer was fetched from the registry.
+	CachedAt time.Time
+	// Tags and Annotations are set by -hook rules.
+	Tags        []string
+	Annotations map[string]string
+	// Provenance is the detached signature of the raw response (-sign).
+	Provenance *resultProvenance
+	Err        error
+}
+
+// line renders the result as printed by the batch lookup, with the name
+// truncated for the console. Historical results are always labelled so they
+// are never mistaken for current registration data.
+func (r lookupResult) line() string {
+	if r.Historical != nil && r.Err == nil {
+		return fmt.Sprintf(localize("%s [historical: captured %s from %s]"), r.currentLine(),
+			r.Historical.CapturedAt.Format(time.RFC3339), r.Historical.Source) + r.lineNote() + r.hookNote()
+	}
+	if failures := rdaplookup.TransientFailures(r.Attempts); len(failures) > 0 && r.Err == nil {
+		reasons := make([]string, len(failures))
+		for index, failure := range failures {
+			reasons[index] = failure.Reason()
+		}
+		return fmt.Sprintf(localize("%s [succeeded after failed attempts: %s]"), r.currentLine(), strings.Join(reasons, ", ")) + r.lineNote() + r.classNote() + r.serverNote() + r.warningNote() + r.hookNote()
+	}
+	return r.currentLine() + r.lineNote() + r.classNote() + r.serverNote() + r.sourceNote(time.Now()) + r.warningNote() + r.hookNote()
+}
+
+// lineNote renders the input line the target was read from.
+func (r lookupResult) lineNote() string {
+	if r.Line == 0 {
+		return ""
+	}
+	return fmt.Sprintf(localize(" [line %d]"), r.Line)
+}
+
+func (r lookupResult) currentLine() string {
+	var invalid *invalidTargetError
+	switch {
+	case r.Skipped:
+		return fmt.Sprintf(localize("%s: skipped (skip list)"), r.Label)
+	case errors.As(r.Err, &invalid) && invalid.Domain:
+		return fmt.Sprintf(localize("%s: invalid domain name: %v"), r.Label, invalid.Err)
+	case errors.As(r.Err, &invalid):
+		return fmt.Sprintf(localize("%s: invalid ASN: %v"), r.Label, invalid.Err)
+	case r.Err != nil:
+		return fmt.Sprintf(localize("%s: error [%s]: %v"), r.Label, r.errorDetails(), r.Err)
+	case r.Domain != nil && r.Name == "":
+		return fmt.Sprintf(localize("%s: (no name found)"), r.Label) + r.Domain.line()
+	case r.Network != nil && r.Name == "":
+		return fmt.Sprintf(localize("%s: (no name found)"), r.Label) + r.Network.line()
+	case r.Nameserver != nil && r.Name == "":
+		return fmt.Sprintf(localize("%s: (no name found)"), r.Label) + r.Nameserver.line()
+	case r.Entity != nil && r.Name == "":
+		return fmt.Sprintf(localize("%s: (no name found)"), r.Label) + r.Entity.line()
+	case r.Name == "":
+		return fmt.Sprintf(localize("%s: (no name found)"), r.Label)
+	case r.Overridden:
+		return fmt.Sprintf(localize("%s: %s [override]"), r.Label, truncateName(r.Name, console.NameLimit))
+	default:
+		line := fmt.Sprintf("%s: %s", r.Label, truncateName(r.Name, console.NameLimit))
+		if suspect, reason := homographSuspect(r.Name); suspect {
+			line = fmt.Sprintf(localize("%s [homograph suspect: %s]"), line, reason)
+		}
+		if r.Domain != nil {
+			line += r.Domain.line()
+		}
+		if r.Network != nil {
+			line += r.Network.line()
+		}
+		if r.Nameserver != nil {
+			line += r.Nameserver.line()
+		}
+		if r.Entity != nil {
+			line += r.Entity.line()
+		}
+		return line
+	}
+}
+
+// invalidTargetError is the result error of an input row that is not an ASN
+// (or, with -domain, not a domain name).
+type invalidTargetError struct {
+	Err    error
+	Domain bool
+}
+
+func (e *invalidTargetError) Error() string {
+	if e.Domain {
+		return "invalid domain name: " + e.Err.Error()
+	}
+	return "invalid ASN: " + e.Err.Error()
+}
+
+func (e *invalidTargetError) Unwrap() error { return e.Err }
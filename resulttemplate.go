@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// parseResultTemplate parses a -format template, executed once per result
+// with its resultRecord (the fields of -json, by their Go names: .ASN,
+// .Name, .Country, ...). It is tried on an empty record so a misspelled
+// field fails before any lookup.
+func parseResultTemplate(text string) (*template.Template, error) {
+	functions := template.FuncMap{"join": strings.Join, "upper": strings.ToUpper, "lower": strings.ToLower}
+	parsed, err := template.New("format").Funcs(functions).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("-format: %v", err)
+	}
+	if err := parsed.Execute(io.Discard, resultRecord{}); err != nil {
+		return nil, fmt.Errorf("-format: %v", err)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+)
+
+// retryTransport retries queries that failed transiently: network errors,
+// attempt timeouts and 500, 502 and 504 responses. It waits delay, then
+// twice that and so on, each wait jittered to between half and all of its
+// value so clients that failed together do not retry together. Permanent
+// answers such as 404 are returned at once; 429 and 503 are retried by
+// throttleTransport, which honors Retry-After.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+	delay   time.Duration
+}
+
+func (t *retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	delay := t.delay
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		response, err := t.base.RoundTrip(request)
+		if attempt >= t.retries || !retryable(request.Context(), response, err) {
+			return response, err
+		}
+		recordRetriedAttempt(request, response, err, time.Since(start))
+		reason := "network error"
+		if err == nil {
+			reason = response.Status
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+		wait := delay/2 + rand.N(delay/2+1)
+		slog.Info("retrying after transient failure", "url", request.URL.String(), "reason", reason, "error", err, "wait", wait, "retry", attempt+1)
+		if err := sleepContext(request.Context(), wait); err != nil {
+			return nil, err
+		}
+		delay *= 2
+	}
+}
+
+// retryable reports whether an attempt failed transiently. Errors of the
+// caller's own context (cancellation, overall deadline) are final.
+func retryable(ctx context.Context, response *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		var netError net.Error
+		return errors.As(err, &netError) || errors.Is(err, context.DeadlineExceeded) ||
+			errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+	}
+	switch response.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// attemptLog collects the attempts retryTransport and throttleTransport
+// discard before retrying. openrdap only sees the response that ends the
+// retries, so these never reach rdaplookup.AttemptsFrom.
+type attemptLog struct {
+	mutex    sync.Mutex
+	attempts []rdaplookup.Attempt
+}
+
+type attemptLogKey struct{}
+
+// withAttemptLog returns a context under which every retried attempt of a
+// client built with newRDAPClient is appended to the returned log.
+func withAttemptLog(ctx context.Context) (context.Context, *attemptLog) {
+	log := &attemptLog{}
+	return context.WithValue(ctx, attemptLogKey{}, log), log
+}
+
+// recordRetriedAttempt adds an attempt about to be retried to the log
+// carried by the request context, if any.
+func recordRetriedAttempt(request *http.Request, response *http.Response, err error, duration time.Duration) {
+	log, _ := request.Context().Value(attemptLogKey{}).(*attemptLog)
+	if log == nil || isBootstrapRequest(request) {
+		return
+	}
+	attempt := rdaplookup.Attempt{URL: request.URL.String(), Duration: duration}
+	if err != nil {
+		attempt.Error = err.Error()
+	} else {
+		attempt.Status, attempt.Error = response.StatusCode, http.StatusText(response.StatusCode)
+	}
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	log.attempts = append(log.attempts, attempt)
+}
+
+// merge inserts the retried attempts before the attempt that finally
+// answered the same URL, taking its query format. Retried attempts of
+// other URLs, such as redirect targets, are appended.
+func (l *attemptLog) merge(attempts []rdaplookup.Attempt) []rdaplookup.Attempt {
+	l.mutex.Lock()
+	retried := append([]rdaplookup.Attempt(nil), l.attempts...)
+	l.mutex.Unlock()
+	if len(retried) == 0 {
+		return attempts
+	}
+	merged := make([]rdaplookup.Attempt, 0, len(attempts)+len(retried))
+	placed := make([]bool, len(retried))
+	for _, attempt := range attempts {
+		for index, earlier := range retried {
+			if !placed[index] && earlier.URL == attempt.URL {
+				earlier.Query, placed[index] = attempt.Query, true
+				merged = append(merged, earlier)
+			}
+		}
+		merged = append(merged, attempt)
+	}
+	for index, earlier := range retried {
+		if !placed[index] {
+			merged = append(merged, earlier)
+		}
+	}
+	return merged
+}
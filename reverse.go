@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+
+	rdap "github.com/openrdap/rdap"
+	"github.com/openrdap/rdap/bootstrap"
+)
+
+// Reverse zones are delegated on octet (in-addr.arpa) or nibble (ip6.arpa)
+// boundaries; these are the prefix lengths tried, most specific first, for
+// the zone holding an address. IPv6 stops at the sizes registries commonly
+// delegate.
+var (
+	reverseZoneBitsIPv4 = []int{24, 16, 8}
+	reverseZoneBitsIPv6 = []int{64, 56, 48, 40, 32}
+)
+
+// reverseZones returns the reverse DNS zones that can hold prefix, most
+// specific first, e.g. 8.8.8.in-addr.arpa, 8.8.in-addr.arpa and
+// 8.in-addr.arpa for 8.8.8.8.
+func reverseZones(prefix netip.Prefix) []string {
+	var zones []string
+	if prefix.Addr().Is4() {
+		octets := prefix.Addr().As4()
+		for _, bits := range reverseZoneBitsIPv4 {
+			if bits > prefix.Bits() {
+				continue
+			}
+			labels := make([]string, 0, bits/8+1)
+			for index := bits/8 - 1; index >= 0; index-- {
+				labels = append(labels, strconv.Itoa(int(octets[index])))
+			}
+			zones = append(zones, strings.Join(append(labels, "in-addr.arpa"), "."))
+		}
+		return zones
+	}
+	bytes := prefix.Addr().As16()
+	for _, bits := range reverseZoneBitsIPv6 {
+		if bits > prefix.Bits() {
+			continue
+		}
+		labels := make([]string, 0, bits/4+1)
+		for nibble := bits/4 - 1; nibble >= 0; nibble-- {
+			value := bytes[nibble/2] >> 4
+			if nibble%2 == 1 {
+				value = bytes[nibble/2] & 0x0f
+			}
+			labels = append(labels, strconv.FormatUint(uint64(value), 16))
+		}
+		zones = append(zones, strings.Join(append(labels, "ip6.arpa"), "."))
+	}
+	return zones
+}
+
+// reverseServer returns the base URL of the registry of the address block
+// holding prefix, which also serves its reverse zones: from -endpoints, or
+// else the IP bootstrap registry, since IANA's DNS registry has no arpa
+// entries.
+func reverseServer(client *rdap.Client, prefix netip.Prefix) (*url.URL, error) {
+	if override := customEndpoints.forPrefix(prefix); override != nil {
+		// Request.URL clears the server URL's query in place, so hand out a copy.
+		server := *override.BaseURL
+		return &server, nil
+	}
+	registry := bootstrap.IPv4
+	if prefix.Addr().Is6() {
+		registry = bootstrap.IPv6
+	}
+	return bootstrapServer(client, registry, prefix.String())
+}
+
+// rdapReverseLookup queries the reverse DNS domain of an IP address or
+// prefix (as returned by parseNetworkTarget) at the registry of its address
+// block, walking up from the most specific zone while the registry answers
+// not found. The result's Domain lists the zone's delegated nameservers;
+// its Name is the registrant organization managing the zone.
+func rdapReverseLookup(ctx context.Context, client *rdap.Client, network string) lookupResult {
+	prefix, err := netip.ParsePrefix(network)
+	if err != nil {
+		addr, addrErr := netip.ParseAddr(network)
+		if addrErr != nil {
+			return lookupResult{Err: fmt.Errorf("%q is not an IP address or prefix", network)}
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	zones := reverseZones(prefix)
+	if len(zones) == 0 {
+		return lookupResult{Err: fmt.Errorf("%s is wider than any reverse zone tried", network)}
+	}
+	server, err := reverseServer(client, prefix)
+	if err != nil {
+		return lookupResult{Err: err}
+	}
+	var result lookupResult
+	for _, zone := range zones {
+		if result = rdapDomainLookupAt(ctx, client, zone, server); resultClass(result.Err) != classNotFound {
+			if result.Domain != nil {
+				result.Domain.Zone = zone
+			}
+			break
+		}
+	}
+	return result
+}
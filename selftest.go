@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+)
+
+// selftestCase is one lookup of the self-test and what it must return.
+type selftestCase struct {
+	Name   string `json:"name"`
+	Class  string `json:"class"` // autnum, ip, domain, nameserver or entity
+	Target string `json:"target"`
+	// Server, for domain and entity cases, is asked instead of the
+	// bootstrapped registry.
+	Server string `json:"server,omitempty"`
+
+	WantName     string `json:"want_name,omitempty"` // case-insensitive substring of the name
+	WantNotFound bool   `json:"want_not_found,omitempty"`
+	WantRedirect bool   `json:"want_redirect,omitempty"`
+}
+
+// defaultSelftestCases are stable, well-known objects: one ASN per RIR,
+// a domain the .com registry answers 404 for, and a domain asked of the
+// rdap.org redirector, which answers with a redirect to the registry.
+var defaultSelftestCases = []selftestCase{
+	{Name: "ARIN autnum", Class: "autnum", Target: "AS15169", WantName: "Google"},
+	{Name: "RIPE autnum", Class: "autnum", Target: "AS3333", WantName: "RIPE"},
+	{Name: "APNIC autnum", Class: "autnum", Target: "AS4608", WantName: "Asia Pacific Network"},
+	{Name: "LACNIC autnum", Class: "autnum", Target: "AS28000", WantName: "Latin American and Caribbean"},
+	{Name: "AFRINIC autnum", Class: "autnum", Target: "AS33764", WantName: "Afri"},
+	{Name: "not found", Class: "domain", Target: "rdap-tester-selftest-no-such-domain.com", WantNotFound: true},
+	{Name: "redirect", Class: "domain", Target: "google.com", Server: "https://rdap.org/", WantName: "MarkMonitor", WantRedirect: true},
+}
+
+func runSelftest(args []string) int {
+	flagSet := newFlagSet("selftest")
+	var options clientOptions
+	options.registerFlags(flagSet)
+	casesPath := flagSet.String("cases", "", "JSON `file` of cases to run instead of the built-in ones: [{\"name\", \"class\", \"target\", \"server\", \"want_name\", \"want_not_found\", \"want_redirect\"}]")
+	timeout := flagSet.Duration("case-timeout", 30*time.Second, "give up on a case after this `duration`")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . selftest [-cases file] [-case-timeout 30s]")
+		fmt.Fprintln(flagSet.Output(), "Looks up well-known objects at the live registries and checks the answers.")
+		flagSet.PrintDefaults()
+	}
+	positional, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) > 0 {
+		flagSet.Usage()
+		return 2
+	}
+	if err := options.validate(); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	cases := defaultSelftestCases
+	if *casesPath != "" {
+		if cases, err = loadSelftestCases(*casesPath); err != nil {
+			fmt.Printf("selftest: %v\n", err)
+			return 2
+		}
+	}
+
+	client := newVantageClients(options, nil, nil)[0]
+	failures := 0
+	for _, testCase := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		result := testCase.run(ctx, client, options.Verbosity)
+		cancel()
+		problems := testCase.check(result)
+		verdict := "PASS"
+		if len(problems) > 0 {
+			verdict = "FAIL"
+			failures++
+		}
+		outcome := result.Name
+		if result.Err != nil {
+			outcome = result.Err.Error()
+		}
+		fmt.Printf("%s %-16s %s: %s\n", verdict, testCase.Name, testCase.Target, outcome)
+		for _, problem := range problems {
+			fmt.Printf("       ! %s\n", problem)
+		}
+	}
+	fmt.Printf("%d of %d cases passed\n", len(cases)-failures, len(cases))
+	if failures > 0 {
+		return 1
+	}
+	return 0
+}
+
+// loadSelftestCases reads a JSON array of cases and rejects unusable ones.
+func loadSelftestCases(path string) ([]selftestCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cases []selftestCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("%s: no cases", path)
+	}
+	for index, testCase := range cases {
+		if _, ok := classQuery(testCase.Class, testCase.Target); !ok {
+			return nil, fmt.Errorf("%s: case %d: %q is not a valid %s", path, index+1, testCase.Target, testCase.Class)
+		}
+		if testCase.Server != "" && testCase.Class != "domain" && testCase.Class != "entity" {
+			return nil, fmt.Errorf("%s: case %d: server is only supported for domain and entity cases", path, index+1)
+		}
+		if testCase.Name == "" {
+			cases[index].Name = testCase.Class + " " + testCase.Target
+		}
+	}
+	return cases, nil
+}
+
+// run looks the case up live, bypassing the response cache.
+func (c selftestCase) run(ctx context.Context, client vantageClient, verbosity int) lookupResult {
+	query, ok := classQuery(c.Class, c.Target)
+	if !ok {
+		return lookupResult{Err: fmt.Errorf("%q is not a valid %s", c.Target, c.Class)}
+	}
+	if c.Server == "" {
+		return lookupClass(ctx, client.Client, c.Class, query, verbosity, rdaplookup.DefaultFollowUps)
+	}
+	server, err := url.Parse(c.Server)
+	if err != nil {
+		return lookupResult{Err: fmt.Errorf("server %q: %v", c.Server, err)}
+	}
+	if c.Class == "entity" {
+		return rdapEntityLookup(ctx, client.Client, query, server)
+	}
+	return rdapDomainLookupAt(ctx, client.Client, query, server)
+}
+
+// check returns how result differs from what the case expects.
+func (c selftestCase) check(result lookupResult) []string {
+	var problems []string
+	switch {
+	case c.WantNotFound:
+		if resultClass(result.Err) != classNotFound {
+			problems = append(problems, "want not found")
+		}
+		return problems
+	case result.Err != nil:
+		return []string{"want an answer"}
+	}
+	if c.WantName != "" && !strings.Contains(strings.ToLower(result.Name), strings.ToLower(c.WantName)) {
+		problems = append(problems, fmt.Sprintf("want a name containing %q, got %q", c.WantName, result.Name))
+	}
+	if c.WantRedirect && (result.Fetch == nil || result.Fetch.Redirects.redirectCount() == 0) {
+		problems = append(problems, "want a redirect to the registry")
+	}
+	return problems
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// version is the release this binary was built from, set at release build
+// time with -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+// selfUpdatePublicKey is the base64 ed25519 key that signs release checksum
+// files, set at release build time with -ldflags. When set, self-update
+// refuses releases whose checksums.txt.sig does not verify.
+var selfUpdatePublicKey = ""
+
+// githubRelease is the subset of the GitHub releases API response we use.
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func (r *githubRelease) assetURL(name string) string {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset.URL
+		}
+	}
+	return ""
+}
+
+// releaseAssetName is the binary built for this platform, e.g.
+// "rdap-test_linux_amd64" or "rdap-test_windows_amd64.exe".
+func releaseAssetName() string {
+	name := fmt.Sprintf("rdap-test_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+func runSelfUpdate(args []string) int {
+	flagSet := newFlagSet("self-update")
+	repository := flagSet.String("repo", "hookster007/Golang-RDAP-Tester", "GitHub `owner/name` to fetch releases from")
+	checkOnly := flagSet.Bool("check", false, "only report whether a newer release exists")
+	force := flagSet.Bool("force", false, "install the latest release even if it is not newer (or this is a dev build)")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . self-update [--check] [--force] [--repo owner/name]")
+		flagSet.PrintDefaults()
+	}
+	if _, err := parseInterspersed(flagSet, args); err != nil {
+		return 2
+	}
+
+	httpClient := &http.Client{Timeout: 2 * time.Minute}
+	release, err := latestRelease(httpClient, *repository)
+	if err != nil {
+		fmt.Printf("self-update: %v\n", err)
+		return 1
+	}
+	fmt.Printf("current version: %s, latest release: %s\n", version, release.TagName)
+	upToDate := version == release.TagName
+	if *checkOnly {
+		if !upToDate {
+			fmt.Println("an update is available; run self-update to install it")
+		}
+		return 0
+	}
+	if (upToDate || version == "dev") && !*force {
+		if upToDate {
+			fmt.Println("already up to date")
+		} else {
+			fmt.Println("this is a dev build; use --force to replace it with the latest release")
+		}
+		return 0
+	}
+
+	assetName := releaseAssetName()
+	binaryURL, checksumsURL := release.assetURL(assetName), release.assetURL("checksums.txt")
+	if binaryURL == "" || checksumsURL == "" {
+		fmt.Printf("self-update: release %s has no %s or checksums.txt asset\n", release.TagName, assetName)
+		return 1
+	}
+	checksums, err := download(httpClient, checksumsURL)
+	if err != nil {
+		fmt.Printf("self-update: %v\n", err)
+		return 1
+	}
+	if selfUpdatePublicKey != "" {
+		if err := verifyChecksumsSignature(httpClient, release, checksums); err != nil {
+			fmt.Printf("self-update: %v\n", err)
+			return 1
+		}
+		fmt.Println("checksums.txt signature verified")
+	}
+	expected, err := checksumFor(checksums, assetName)
+	if err != nil {
+		fmt.Printf("self-update: %v\n", err)
+		return 1
+	}
+	binary, err := download(httpClient, binaryURL)
+	if err != nil {
+		fmt.Printf("self-update: %v\n", err)
+		return 1
+	}
+	if actual := sha256.Sum256(binary); hex.EncodeToString(actual[:]) != expected {
+		fmt.Printf("self-update: checksum mismatch for %s (expected %s, got %x)\n", assetName, expected, actual)
+		return 1
+	}
+	fmt.Printf("sha256 verified for %s\n", assetName)
+	if err := replaceExecutable(binary); err != nil {
+		fmt.Printf("self-update: %v\n", err)
+		return 1
+	}
+	fmt.Printf("updated to %s\n", release.TagName)
+	return 0
+}
+
+// latestRelease asks the GitHub API (or GITHUB_API_URL, as set for GitHub
+// Enterprise and in Actions) for the newest release of repository.
+func latestRelease(httpClient *http.Client, repository string) (*githubRelease, error) {
+	apiURL := os.Getenv("GITHUB_API_URL")
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+	body, err := download(httpClient, strings.TrimSuffix(apiURL, "/")+"/repos/"+repository+"/releases/latest")
+	if err != nil {
+		return nil, err
+	}
+	var release githubRelease
+	if err := json.Unmarshal(body, &release); err != nil {
+		return nil, fmt.Errorf("decoding release: %v", err)
+	}
+	return &release, nil
+}
+
+func download(httpClient *http.Client, downloadURL string) ([]byte, error) {
+	response, err := httpClient.Get(downloadURL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", downloadURL, response.Status)
+	}
+	return io.ReadAll(response.Body)
+}
+
+// checksumFor finds name in a sha256sum-style checksums file.
+func checksumFor(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("checksums.txt has no entry for %s", name)
+}
+
+func verifyChecksumsSignature(httpClient *http.Client, release *githubRelease, checksums []byte) error {
+	publicKey, err := base64.StdEncoding.DecodeString(selfUpdatePublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("built-in release signing key is invalid")
+	}
+	signatureURL := release.assetURL("checksums.txt.sig")
+	if signatureURL == "" {
+		return fmt.Errorf("release %s is not signed (no checksums.txt.sig)", release.TagName)
+	}
+	encoded, err := download(httpClient, signatureURL)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil || !ed25519.Verify(publicKey, checksums, signature) {
+		return fmt.Errorf("checksums.txt signature does not verify")
+	}
+	return nil
+}
+
+// replaceExecutable swaps the running binary for binary. The new file is
+// written next to the old one and renamed over it, so a failure never
+// leaves a partial binary in place.
+func replaceExecutable(binary []byte) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return err
+	}
+	info, err := os.Stat(executable)
+	if err != nil {
+		return err
+	}
+	temporary := executable + ".new"
+	if err := os.WriteFile(temporary, binary, info.Mode().Perm()|0o100); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		// A running executable cannot be overwritten on Windows, but it can
+		// be renamed out of the way.
+		old := executable + ".old"
+		os.Remove(old)
+		if err := os.Rename(executable, old); err != nil {
+			os.Remove(temporary)
+			return err
+		}
+	}
+	if err := os.Rename(temporary, executable); err != nil {
+		os.Remove(temporary)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+)
+
+// apiServer answers lookups over HTTP with the normalized JSON results of
+// -json. Every request shares the upstream rate limiter, budgets and
+// retries of one client, the response cache and a coalescer, so a burst of
+// identical requests costs the registry one query.
+type apiServer struct {
+	client    vantageClient
+	responses *responseCache
+	coalescer *lookupCoalescer
+	metrics   *serveMetrics
+	verbosity int
+	followUps int
+}
+
+func runServe(args []string) int {
+	flagSet := newFlagSet("serve")
+	var options clientOptions
+	options.registerFlags(flagSet)
+	listen := flagSet.String("listen", ":8080", "`address` to listen on")
+	followUps := flagSet.Int("follow-ups", rdaplookup.DefaultFollowUps, "dereference at most `n` linked entities per ASN when the record embeds no organization contact (0 disables)")
+	dedupWindow := flagSet.Duration("dedup-window", 2*time.Second, "answer identical requests arriving within this `window` with one upstream query (0 disables)")
+	cacheTTL := flagSet.Duration("cache-ttl", defaultCacheTTL, "answer from the on-disk response cache when the cached answer is younger than this `age`")
+	noCache := flagSet.Bool("no-cache", false, "neither read nor write the on-disk response cache")
+	offline := flagSet.Bool("offline", false, "answer from the on-disk response cache only, however old the answers, and send no request at all")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . serve [-listen :8080] [-rate-limit n] [-budget registry=N/period] [-cache-ttl age]")
+		flagSet.PrintDefaults()
+	}
+	if _, err := parseInterspersed(flagSet, args); err != nil {
+		return 2
+	}
+	if err := options.validate(); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if *cacheTTL <= 0 {
+		fmt.Println("-cache-ttl must be positive; use -no-cache to bypass the cache")
+		return 2
+	}
+	if *offline && *noCache {
+		fmt.Println("-offline answers from the response cache and cannot be combined with -no-cache")
+		return 2
+	}
+	options.Offline = *offline
+	metrics := newServeMetrics()
+	options.Trace = metrics.observeRequest
+
+	server := &apiServer{
+		client:    newVantageClients(options, nil, nil)[0],
+		responses: newResponseCache(*cacheTTL, *noCache, *offline),
+		coalescer: newLookupCoalescer(*dedupWindow),
+		metrics:   metrics,
+		verbosity: options.Verbosity,
+		followUps: *followUps,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /asn/{asn}", server.handle("autnum"))
+	mux.HandleFunc("GET /ip/{ip...}", server.handle("ip"))
+	mux.HandleFunc("GET /domain/{name}", server.handle("domain"))
+	mux.HandleFunc("GET /nameserver/{name}", server.handle("nameserver"))
+	mux.HandleFunc("GET /entity/{handle}", server.handle("entity"))
+	mux.Handle("GET /metrics", metrics)
+	httpServer := &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdown)
+	}()
+	slog.Info("serving lookups", "listen", *listen)
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("serve: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// handle returns the handler of one object class. It answers with the
+// result record: 200 when found, 404 when the registry says the object does
+// not exist, 400 for invalid targets and 502 for other upstream failures.
+// ?raw=1 embeds the untouched RDAP document.
+func (s *apiServer) handle(class string) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var value string
+		switch class {
+		case "autnum":
+			value = request.PathValue("asn")
+		case "ip":
+			value = request.PathValue("ip")
+		case "entity":
+			value = request.PathValue("handle")
+		default:
+			value = request.PathValue("name")
+		}
+		result, status := s.lookup(request.Context(), class, value)
+		includeRaw, _ := strconv.ParseBool(request.URL.Query().Get("raw"))
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(status)
+		if err := json.NewEncoder(writer).Encode(result.record(includeRaw)); err != nil {
+			slog.Debug("writing response failed", "target", result.Target, "error", err)
+		}
+	}
+}
+
+// lookup answers one request for value as an object of class, returning the
+// result and the HTTP status to answer with.
+func (s *apiServer) lookup(ctx context.Context, class, value string) (lookupResult, int) {
+	query, ok := classQuery(class, value)
+	if !ok {
+		return lookupResult{Target: value, Label: value, Err: fmt.Errorf("%q is not a valid %s", value, class)}, http.StatusBadRequest
+	}
+	target := query
+	if class == "autnum" {
+		target = "AS" + query
+	}
+	// An openrdap client writes its own fields on every query, so each
+	// request gets a copy sharing the transports and bootstrap files.
+	client := workerClients([]vantageClient{s.client})[0]
+	// Coalesced callers share the answer, so one of them hanging up must
+	// not cancel the query for the others.
+	ctx = context.WithoutCancel(ctx)
+	result := s.coalescer.do(class+"|"+query, func() lookupResult {
+		return s.responses.lookup(client.Vantage, class, query, func() lookupResult {
+			return lookupClass(ctx, client.Client, class, query, s.verbosity, s.followUps)
+		})
+	})
+	result.Target, result.Label, result.Vantage, result.Attempts = target, target, client.Vantage, result.Fetch.attempts()
+	s.metrics.observeLookup(class, result, s.responses != nil)
+	switch resultClass(result.Err) {
+	case classOK:
+		return result, http.StatusOK
+	case classNotFound:
+		return result, http.StatusNotFound
+	default:
+		return result, http.StatusBadGateway
+	}
+}
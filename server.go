@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// rdapQueryPaths are the path segments RDAP lookups append to a server's
+// base URL.
+var rdapQueryPaths = []string{"/autnum/", "/ip/", "/domain/", "/entity/", "/nameserver/"}
+
+// server returns the base URL of the RDAP server that answered, as selected
+// by the bootstrap registry or -endpoints, e.g. "https://rdap.arin.net/registry/".
+func (r lookupResult) server() string {
+	if r.Fetch == nil || r.Fetch.URL == "" {
+		return ""
+	}
+	for _, path := range rdapQueryPaths {
+		if index := strings.LastIndex(r.Fetch.URL, path); index >= 0 {
+			return r.Fetch.URL[:index+1]
+		}
+	}
+	return ""
+}
+
+// port43 returns the WHOIS server the answering record names in port43.
+func (r lookupResult) port43() string {
+	if r.Fetch == nil || r.Err != nil {
+		return ""
+	}
+	if r.Fetch.Record != nil {
+		return strings.TrimSpace(r.Fetch.Record.Port43)
+	}
+	var document struct {
+		Port43 string `json:"port43"`
+	}
+	if len(r.Fetch.RawBody) == 0 || json.Unmarshal(r.Fetch.RawBody, &document) != nil {
+		return ""
+	}
+	return strings.TrimSpace(document.Port43)
+}
+
+// serverNote renders which registry answered, through which server, after
+// the result line: the RIR when the server is a known one, then the base
+// URL and the port43 server.
+func (r lookupResult) serverNote() string {
+	server := r.server()
+	if server == "" || r.Err != nil {
+		return ""
+	}
+	note := server
+	if registry := r.registry(); knownRegistry(registry) {
+		note = registry + " " + server
+	}
+	if port43 := r.port43(); port43 != "" {
+		note += ", port43 " + port43
+	}
+	return " [" + note + "]"
+}
+
+// knownRegistry reports whether registry names one of the RIRs rather than
+// an unknown server's host name.
+func knownRegistry(registry string) bool {
+	for _, name := range knownRegistries {
+		if name == registry {
+			return true
+		}
+	}
+	return false
+}
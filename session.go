@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// upstreamSession is the pre-flight request of one base URL. Some national
+// registries front RDAP with a session or anti-bot layer that answers 403
+// until the client has fetched another page and kept the cookie it sets.
+type upstreamSession struct {
+	BaseURL   string // requests whose URL starts with it need the session
+	Preflight string // URL fetched for the session cookies
+
+	mu sync.Mutex
+	// fetched is when the pre-flight request last succeeded.
+	fetched time.Time
+}
+
+// upstreamSessions is the parsed sessions file.
+type upstreamSessions []*upstreamSession
+
+// defaultSessionsPath returns $XDG_CONFIG_HOME/rdap-tester/sessions,
+// defaulting to ~/.config/rdap-tester/sessions.
+func defaultSessionsPath() (string, error) {
+	return configFilePath("sessions")
+}
+
+// loadSessions reads a sessions file with one "base-URL pre-flight-URL" pair
+// per line. Blank lines and lines starting with # are ignored. A missing
+// file is not an error when optional is set.
+func loadSessions(path string, optional bool) (upstreamSessions, error) {
+	file, err := os.Open(path)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sessions upstreamSessions
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"base-URL pre-flight-URL\", got %q", path, lineNumber, line)
+		}
+		for _, field := range fields {
+			parsed, err := url.Parse(field)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return nil, fmt.Errorf("%s:%d: invalid URL %q", path, lineNumber, field)
+			}
+		}
+		sessions = append(sessions, &upstreamSession{BaseURL: fields[0], Preflight: fields[1]})
+	}
+	return sessions, scanner.Err()
+}
+
+// forURL returns the session with the longest base URL prefixing rawURL.
+func (s upstreamSessions) forURL(rawURL string) *upstreamSession {
+	var best *upstreamSession
+	for _, candidate := range s {
+		if strings.HasPrefix(rawURL, candidate.BaseURL) && (best == nil || len(candidate.BaseURL) > len(best.BaseURL)) {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// sessionTransport keeps the cookies servers set, per host, and sends them
+// back; before the first request under a base URL with a pre-flight, it
+// fetches the pre-flight URL so the session cookies are in place. A 401 or
+// 403 answer under such a base URL renews the session once and retries, as
+// sessions expire during long batches.
+type sessionTransport struct {
+	base     http.RoundTripper
+	jar      http.CookieJar
+	sessions upstreamSessions
+}
+
+func (t *sessionTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	session := t.sessions.forURL(request.URL.String())
+	if session == nil {
+		return t.send(request)
+	}
+	if err := session.start(request.Context(), t, time.Time{}); err != nil {
+		return nil, err
+	}
+	sent := time.Now()
+	response, err := t.send(request)
+	if err != nil || (response.StatusCode != http.StatusUnauthorized && response.StatusCode != http.StatusForbidden) || request.Body != nil {
+		return response, err
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+	if err := session.start(request.Context(), t, sent); err != nil {
+		return nil, err
+	}
+	return t.send(request)
+}
+
+// send sends request with the cookies stored for its URL and stores the
+// cookies of the response.
+func (t *sessionTransport) send(request *http.Request) (*http.Response, error) {
+	if cookies := t.jar.Cookies(request.URL); len(cookies) > 0 {
+		request = request.Clone(request.Context())
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+	}
+	response, err := t.base.RoundTrip(request)
+	if err == nil {
+		if cookies := response.Cookies(); len(cookies) > 0 {
+			t.jar.SetCookies(request.URL, cookies)
+		}
+	}
+	return response, err
+}
+
+// start fetches the pre-flight URL unless it succeeded after stale (or at
+// all, when stale is zero), so concurrent requests renew a session once.
+func (s *upstreamSession) start(ctx context.Context, t *sessionTransport, stale time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.fetched.IsZero() && s.fetched.After(stale) {
+		return nil
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, s.Preflight, nil)
+	if err != nil {
+		return fmt.Errorf("pre-flight %s: %v", s.Preflight, err)
+	}
+	response, err := t.send(request)
+	if err != nil {
+		return fmt.Errorf("pre-flight %s: %v", s.Preflight, err)
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+	if response.StatusCode >= 400 {
+		return fmt.Errorf("pre-flight %s: %s", s.Preflight, response.Status)
+	}
+	s.fetched = time.Now()
+	return nil
+}
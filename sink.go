@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/hookster007/rdap-test/pkg/sink"
+)
+
+func init() {
+	// -resume makes file sinks add to their file, which the library's
+	// file sink leaves to its caller.
+	sink.RegisterSink("file", openFileSink)
+}
+
+// sinkFormat selects how the built-in sinks render results.
+type sinkFormat struct {
+	JSON bool // one resultRecord object per line (-json)
+	// Stream flushes every result as it is written, file sinks included
+	// (-jsonl).
+	Stream     bool
+	IncludeRaw bool // embed the raw RDAP document in JSON records (-include-raw)
+	// CSVFields, when set, selects CSV output with these columns after a
+	// header row (-csv, -fields).
+	CSVFields []string
+	// Template, when set, renders each result as one line (-format).
+	Template *template.Template
+	// Contacts lists the registrant, administrative, technical and abuse
+	// contacts under each text result (-v).
+	Contacts bool
+	// Raw writes the RDAP document of each result as the server sent it,
+	// and nothing for results without one (-raw).
+	Raw bool
+}
+
+// sinkResult converts result for the sinks, rendering its Text in format.
+func sinkResult(result lookupResult, format sinkFormat) (sink.Result, error) {
+	record, err := json.Marshal(result.record(false))
+	if err != nil {
+		return sink.Result{}, err
+	}
+	converted := sink.Result{Target: result.Target, Line: result.Line, Record: record}
+	if result.Fetch != nil {
+		converted.Raw = result.Fetch.RawBody
+	}
+	converted.Text, err = format.render(result)
+	return converted, err
+}
+
+// render writes result as format selects: a CSV row, a template line, the
+// raw document, a JSON record or text lines, followed by its provenance
+// when signed, its networks when listed and its contacts with -v.
+func (f sinkFormat) render(result lookupResult) ([]byte, error) {
+	var text bytes.Buffer
+	switch {
+	case f.CSVFields != nil:
+		rows := csv.NewWriter(&text)
+		rows.Write(result.csvRow(f.CSVFields))
+		rows.Flush()
+		return text.Bytes(), rows.Error()
+	case f.Template != nil:
+		if err := f.Template.Execute(&text, result.record(false)); err != nil {
+			return nil, err
+		}
+		text.WriteByte('\n')
+		return text.Bytes(), nil
+	case f.Raw:
+		if result.Fetch != nil && len(result.Fetch.RawBody) > 0 {
+			body := result.Fetch.RawBody
+			text.Write(body)
+			// Separate documents whose server sent no final newline.
+			if body[len(body)-1] != '\n' {
+				text.WriteByte('\n')
+			}
+		}
+		return text.Bytes(), nil
+	case f.JSON:
+		encoded, err := json.Marshal(result.record(f.IncludeRaw))
+		if err != nil {
+			return nil, err
+		}
+		text.Write(encoded)
+		text.WriteByte('\n')
+		return text.Bytes(), nil
+	}
+	fmt.Fprintln(&text, result.line())
+	if result.Provenance != nil {
+		fmt.Fprintln(&text, result.Provenance.line())
+	}
+	for _, network := range result.OriginNetworks {
+		fmt.Fprintln(&text, network.line())
+	}
+	if f.Contacts {
+		for _, contact := range result.contacts() {
+			fmt.Fprintln(&text, contact.line())
+		}
+	}
+	return text.Bytes(), nil
+}
+
+// header returns what the built-in sinks write before the first result:
+// the CSV header row, or nothing.
+func (f sinkFormat) header() []byte {
+	if f.CSVFields == nil {
+		return nil
+	}
+	var header bytes.Buffer
+	rows := csv.NewWriter(&header)
+	rows.Write(f.CSVFields)
+	rows.Flush()
+	return header.Bytes()
+}
+
+func openFileSink(path string) (sink.Sink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("file sink needs a path (file:results.txt)")
+	}
+	return sink.OpenFile(path, appendToFileSinks)
+}
+
+// appendToFileSinks makes file sinks add to their file instead of
+// replacing it, so a -resume run completes the output of the run it
+// resumes.
+var appendToFileSinks bool
+
+// sinkFlag collects -sink specs.
+type sinkFlag []string
+
+func (f *sinkFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *sinkFlag) Set(value string) error {
+	name, _, _ := strings.Cut(value, ":")
+	if !slices.Contains(sink.Names(), name) {
+		return fmt.Errorf("unknown sink %q (known: %s)", name, strings.Join(sink.Names(), ", "))
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// openSinks opens every spec, setting up the built-in sinks for format;
+// on failure the sinks opened so far are flushed and closed again.
+func openSinks(specs []string, format sinkFormat) ([]sink.Sink, error) {
+	var sinks []sink.Sink
+	for _, spec := range specs {
+		name, argument, _ := strings.Cut(spec, ":")
+		opened, err := sink.Open(name, argument)
+		if err != nil {
+			flushSinks(sinks)
+			return nil, fmt.Errorf("-sink %s: %v", spec, err)
+		}
+		if writer, ok := opened.(*sink.Writer); ok {
+			writer.Header, writer.Stream = format.header(), format.Stream
+		}
+		sinks = append(sinks, opened)
+	}
+	return sinks, nil
+}
+
+// flushSinks flushes every sink, returning the first error.
+func flushSinks(sinks []sink.Sink) error {
+	var firstErr error
+	for _, opened := range sinks {
+		if err := opened.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// asnRange is an inclusive range of ASNs; a single ASN has Low == High.
+type asnRange struct {
+	Low, High int64
+}
+
+// parseASNRange accepts "64512", "AS64512" or "64512-65534".
+func parseASNRange(text string) (asnRange, error) {
+	lowText, highText, isRange := strings.Cut(strings.TrimSpace(text), "-")
+	low, err := parseASN(lowText)
+	if err != nil {
+		return asnRange{}, fmt.Errorf("invalid ASN range %q", text)
+	}
+	high := low
+	if isRange {
+		if high, err = parseASN(highText); err != nil || high < low {
+			return asnRange{}, fmt.Errorf("invalid ASN range %q", text)
+		}
+	}
+	return asnRange{Low: low, High: high}, nil
+}
+
+// skipList holds targets that must never be sent to a registry. It is a
+// flag.Value for -skip, which takes comma-separated ASNs and ranges and may
+// be repeated.
+type skipList []asnRange
+
+func (s *skipList) String() string {
+	if s == nil {
+		return ""
+	}
+	var parts []string
+	for _, r := range *s {
+		if r.Low == r.High {
+			parts = append(parts, fmt.Sprint(r.Low))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", r.Low, r.High))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s *skipList) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		r, err := parseASNRange(part)
+		if err != nil {
+			return err
+		}
+		*s = append(*s, r)
+	}
+	return nil
+}
+
+// loadFile adds the ASNs and ranges listed in path, one per line; blank lines
+// and # comments are ignored.
+func (s *skipList) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if err := s.Set(line); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func (s skipList) contains(asn int64) bool {
+	for _, r := range s {
+		if asn >= r.Low && asn <= r.High {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// snapshotDir returns the snapshots directory under cacheHomeDir. It holds
+// the last raw RDAP response seen for each object so later runs can compare
+// against it.
+func snapshotDir() (string, error) {
+	home, err := cacheHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "snapshots"), nil
+}
+
+// snapshotTimeLayout names the dated copies kept under history/<key>/.
+const snapshotTimeLayout = "20060102T150405Z"
+
+// saveSnapshot stores the raw response for key (e.g. "AS15169"), replacing
+// the previous one. Whenever the response changed, a dated copy is also kept
+// in history/<key>/ so --as-of can answer from it later. Errors are reported
+// on stderr only.
+func saveSnapshot(key string, rawBody []byte) {
+	if len(rawBody) == 0 {
+		return
+	}
+	dir, err := snapshotDir()
+	if err == nil {
+		err = os.MkdirAll(filepath.Join(dir, "history", key), 0o755)
+	}
+	if err == nil {
+		previous, _ := os.ReadFile(filepath.Join(dir, key+".json"))
+		if !bytes.Equal(previous, rawBody) {
+			dated := time.Now().UTC().Format(snapshotTimeLayout) + ".json"
+			err = os.WriteFile(filepath.Join(dir, "history", key, dated), rawBody, 0o644)
+		}
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, key+".json"), rawBody, 0o644)
+	}
+	if err != nil {
+		slog.Warn("snapshot not saved", "key", key, "error", err)
+	}
+}
+
+// loadSnapshot returns the last stored raw response for key and when it was saved.
+func loadSnapshot(key string) ([]byte, time.Time, error) {
+	dir, err := snapshotDir()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	path := filepath.Join(dir, key+".json")
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, time.Time{}, fmt.Errorf("no cached snapshot for %s", key)
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(path)
+	return data, info.ModTime(), err
+}
+
+// loadSnapshotAsOf returns the newest dated snapshot of key captured at or
+// before asOf, and when it was captured.
+func loadSnapshotAsOf(key string, asOf time.Time) ([]byte, time.Time, error) {
+	dir, err := snapshotDir()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "history", key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, time.Time{}, err
+	}
+	var newest string
+	var capturedAt time.Time
+	for _, entry := range entries {
+		captured, err := time.Parse(snapshotTimeLayout, strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil || captured.After(asOf) || captured.Before(capturedAt) {
+			continue
+		}
+		newest, capturedAt = entry.Name(), captured
+	}
+	if newest == "" {
+		return nil, time.Time{}, errNotArchived
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "history", key, newest))
+	return data, capturedAt, err
+}
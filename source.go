@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Result sources tell how fresh and how authoritative an answer is.
+const (
+	sourceLive          = "live"           // queried from the registry in this run
+	sourceCache         = "cache"          // from the response cache, within its TTL
+	sourceStaleCache    = "stale-cache"    // from the response cache, past its TTL
+	sourceOverride      = "override"       // name from the overrides file
+	sourceWhoisFallback = "whois-fallback" // from port-43 WHOIS after RDAP failed
+	sourceArchive       = "archive"        // from an -as-of archive source
+)
+
+// source reports where the result's answer came from, or "" for targets
+// that were never looked up (skipped or invalid).
+func (r lookupResult) source() string {
+	var invalid *invalidTargetError
+	switch {
+	case r.Skipped || errors.As(r.Err, &invalid):
+		return ""
+	case r.Overridden:
+		return sourceOverride
+	case r.Historical != nil:
+		return sourceArchive
+	case r.Source != "":
+		return r.Source
+	default:
+		return sourceLive
+	}
+}
+
+// cacheAge is how long ago a cached answer was fetched from the registry,
+// or zero for answers that did not come from the cache.
+func (r lookupResult) cacheAge(now time.Time) time.Duration {
+	if r.CachedAt.IsZero() {
+		return 0
+	}
+	return now.Sub(r.CachedAt).Truncate(time.Second)
+}
+
+// sourceNote labels answers that did not come live from the registry, so
+// a cached or fallback answer is never mistaken for a fresh one.
+func (r lookupResult) sourceNote(now time.Time) string {
+	switch source := r.source(); source {
+	case sourceCache, sourceStaleCache:
+		return fmt.Sprintf(localize(" [%s, %s old]"), source, r.cacheAge(now))
+	case sourceWhoisFallback:
+		if r.Whois != nil {
+			return " [" + source + " " + r.Whois.Server + "]"
+		}
+		return " [" + source + "]"
+	default:
+		return ""
+	}
+}
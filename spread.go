@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// spreadSchedule paces a batch so its rows start evenly across a window
+// (-spread) instead of bursting at the maximum rate: row i starts no
+// earlier than i*window/rows after the first. It suits large recurring
+// jobs where staying far below registry limits matters more than finishing
+// fast.
+type spreadSchedule struct {
+	start    time.Time
+	interval time.Duration
+}
+
+// newSpreadSchedule returns a schedule spreading rows across window, or nil
+// (no pacing) when window is not positive or there is at most one row.
+func newSpreadSchedule(window time.Duration, rows int) *spreadSchedule {
+	if window <= 0 || rows <= 1 {
+		return nil
+	}
+	interval := window / time.Duration(rows)
+	slog.Info("spreading queries", "rows", rows, "window", window, "interval", interval)
+	return &spreadSchedule{start: time.Now(), interval: interval}
+}
+
+// wait blocks until row's slot in the schedule, or until ctx is done.
+func (s *spreadSchedule) wait(ctx context.Context, row int) {
+	if s == nil {
+		return
+	}
+	sleepContext(ctx, time.Until(s.start.Add(time.Duration(row)*s.interval)))
+}
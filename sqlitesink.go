@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/sink"
+)
+
+// sqliteSchema creates the tables of a -sqlite database unless a previous
+// run already did; every run adds a row to runs and its results to
+// results, so a database collects a whole campaign.
+const sqliteSchema = `PRAGMA journal_mode = WAL;
+CREATE TABLE IF NOT EXISTS runs (
+	id         INTEGER PRIMARY KEY,
+	started_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS results (
+	id           INTEGER PRIMARY KEY,
+	run_id       INTEGER NOT NULL REFERENCES runs (id),
+	looked_up_at TEXT NOT NULL,
+	target       TEXT NOT NULL,
+	line         INTEGER,
+	vantage      TEXT,
+	class        TEXT,
+	asn          INTEGER,
+	name         TEXT,
+	rir          TEXT,
+	handle       TEXT,
+	country      TEXT,
+	source       TEXT,
+	server       TEXT,
+	url          TEXT,
+	error        TEXT,
+	error_class  TEXT,
+	http_status  INTEGER,
+	error_code   INTEGER,
+	record       TEXT NOT NULL,
+	raw          TEXT
+);
+CREATE INDEX IF NOT EXISTS results_run ON results (run_id);
+CREATE INDEX IF NOT EXISTS results_target ON results (target);
+CREATE INDEX IF NOT EXISTS results_asn ON results (asn);
+CREATE INDEX IF NOT EXISTS results_rir_country ON results (rir, country);
+CREATE INDEX IF NOT EXISTS results_error_class ON results (error_class);
+`
+
+// sqliteBatchSize is how many results are inserted per transaction.
+const sqliteBatchSize = 500
+
+// sqliteSink inserts results into a SQLite database (-sqlite, or -sink
+// sqlite:path). It feeds SQL to the sqlite3 command, so the binary needs
+// no database driver; results are inserted in transactions of
+// sqliteBatchSize.
+type sqliteSink struct {
+	path    string
+	process *exec.Cmd
+	input   *bufio.Writer
+	stdin   io.WriteCloser
+	output  *bufio.Reader
+	stderr  bytes.Buffer
+	pending int // results inserted since the transaction began
+	syncs   int
+	// exited is set once sqlite3 was waited for; its stderr is complete.
+	exited  bool
+	exitErr error
+}
+
+func init() {
+	sink.RegisterSink("sqlite", openSQLiteSink)
+}
+
+func openSQLiteSink(path string) (sink.Sink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite sink needs a path (sqlite:results.db)")
+	}
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		return nil, fmt.Errorf("sqlite sink needs the sqlite3 command on PATH")
+	}
+	s := &sqliteSink{path: path, process: exec.Command("sqlite3", "-batch", "-bail", path)}
+	s.process.Stderr = &s.stderr
+	stdin, err := s.process.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := s.process.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.process.Start(); err != nil {
+		return nil, err
+	}
+	s.stdin, s.input, s.output = stdin, bufio.NewWriter(stdin), bufio.NewReader(stdout)
+	fmt.Fprintf(s.input, "%sINSERT INTO runs (started_at) VALUES (%s);\n", sqliteSchema, sqlText(time.Now().UTC().Format(time.RFC3339Nano)))
+	s.input.WriteString("CREATE TEMP TABLE current_run AS SELECT last_insert_rowid() AS id;\nBEGIN;\n")
+	// Fail on an unwritable path or a database of another schema now,
+	// not at the first result.
+	if err := s.sync(); err != nil {
+		s.Flush()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sqliteSink) Write(result sink.Result) error {
+	var record resultRecord
+	if err := json.Unmarshal(result.Record, &record); err != nil {
+		return err
+	}
+	raw := rawDocument(result.Raw)
+	var message string
+	if record.Error != nil {
+		message = *record.Error
+	}
+	values := []string{
+		"(SELECT id FROM current_run)",
+		sqlText(time.Now().UTC().Format(time.RFC3339Nano)),
+		sqlText(record.Target),
+		sqlInteger(int64(record.Line)),
+		sqlText(record.Vantage),
+		sqlText(record.Class),
+		sqlInteger(record.ASN),
+		sqlText(record.Name),
+		sqlText(record.RIR),
+		sqlText(record.Handle),
+		sqlText(record.Country),
+		sqlText(record.Source),
+		sqlText(record.Server),
+		sqlText(record.URL),
+		sqlText(message),
+		sqlText(record.ErrorClass),
+		sqlInteger(int64(record.HTTPStatus)),
+		sqlInteger(int64(record.ErrorCode)),
+		sqlText(string(result.Record)),
+		sqlText(string(raw)),
+	}
+	fmt.Fprintf(s.input, "INSERT INTO results (run_id, looked_up_at, target, line, vantage, class, asn, name, rir, handle, country, source, server, url, error, error_class, http_status, error_code, record, raw) VALUES (%s);\n", strings.Join(values, ", "))
+	if s.pending++; s.pending >= sqliteBatchSize {
+		s.input.WriteString("COMMIT;\nBEGIN;\n")
+		// Wait for the commit, so a -checkpoint may list the batch; until
+		// it is confirmed the rows stay uncommitted.
+		if err := s.sync(); err != nil {
+			return err
+		}
+		s.pending = 0
+	}
+	return s.input.Flush()
+}
+
+// uncommitted returns the number of results inserted since the last
+// commit; -checkpoint lists their targets once they are committed.
+func (s *sqliteSink) uncommitted() int {
+	return s.pending
+}
+
+// sync waits until sqlite3 has executed everything written so far.
+func (s *sqliteSink) sync() error {
+	s.syncs++
+	marker := "synced " + strconv.Itoa(s.syncs)
+	fmt.Fprintf(s.input, "SELECT %s;\n", sqlText(marker))
+	if err := s.input.Flush(); err != nil {
+		return s.failure(err)
+	}
+	for {
+		line, err := s.output.ReadString('\n')
+		if err != nil {
+			return s.failure(err)
+		}
+		if strings.TrimSpace(line) == marker {
+			return nil
+		}
+	}
+}
+
+// failure explains err, typically a broken pipe to sqlite3 after it gave
+// up, with what sqlite3 said.
+func (s *sqliteSink) failure(err error) error {
+	s.exit()
+	if message := strings.TrimSpace(s.stderr.String()); message != "" {
+		return fmt.Errorf("%s: %s", s.path, message)
+	}
+	return err
+}
+
+// exit ends the input of sqlite3 and waits for it to exit.
+func (s *sqliteSink) exit() error {
+	if !s.exited {
+		s.exited = true
+		s.stdin.Close()
+		s.exitErr = s.process.Wait()
+	}
+	return s.exitErr
+}
+
+func (s *sqliteSink) Flush() error {
+	if s.exited {
+		return s.failure(s.exitErr)
+	}
+	s.input.WriteString("COMMIT;\n")
+	err := s.input.Flush()
+	if exitErr := s.exit(); err == nil {
+		err = exitErr
+	}
+	if err != nil {
+		return s.failure(err)
+	}
+	return nil
+}
+
+// sqlText quotes text as an SQL string literal; the empty string is NULL.
+func sqlText(text string) string {
+	if text == "" {
+		return "NULL"
+	}
+	// sqlite3 reads its input as C strings.
+	text = strings.ReplaceAll(text, "\x00", "")
+	return "'" + strings.ReplaceAll(text, "'", "''") + "'"
+}
+
+// sqlInteger renders value as an SQL integer; 0 is NULL.
+func sqlInteger(value int64) string {
+	if value == 0 {
+		return "NULL"
+	}
+	return strconv.FormatInt(value, 10)
+}
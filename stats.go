@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// runStats accumulates the end-of-run summary printed with -stats.
+type runStats struct {
+	mutex              sync.Mutex
+	requests           int
+	bootstrapDownloads int
+	bytes              atomic.Int64
+	requestsByRegistry map[string]int
+	lookups            int
+	bootstrapHits      int
+	outcomes           map[string]int
+	countries          map[string]int
+	durations          []time.Duration
+}
+
+func newRunStats() *runStats {
+	return &runStats{requestsByRegistry: map[string]int{}, outcomes: map[string]int{}, countries: map[string]int{}}
+}
+
+// countRequest records one HTTP request and counts its response body bytes
+// as they are read.
+func (s *runStats) countRequest(request *http.Request, response *http.Response) {
+	s.mutex.Lock()
+	s.requests++
+	if isBootstrapRequest(request) {
+		s.bootstrapDownloads++
+	} else {
+		s.requestsByRegistry[registryForURL(request.URL)]++
+	}
+	s.mutex.Unlock()
+	if response != nil && response.Body != nil {
+		response.Body = &countingBody{ReadCloser: response.Body, count: &s.bytes}
+	}
+}
+
+// addLookup records the outcome of one target lookup.
+func (s *runStats) addLookup(result lookupResult, duration time.Duration, downloadedBootstrap bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lookups++
+	if !downloadedBootstrap && result.Fetch != nil {
+		s.bootstrapHits++
+	}
+	s.outcomes[resultClass(result.Err)]++
+	if country := result.country(); country != "" {
+		s.countries[country]++
+	}
+	s.durations = append(s.durations, duration)
+}
+
+// bootstrapDownloadCount returns the number of bootstrap registry downloads so
+// far, so callers can tell whether a lookup needed one.
+func (s *runStats) bootstrapDownloadCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.bootstrapDownloads
+}
+
+func isBootstrapRequest(request *http.Request) bool {
+	for _, filename := range bootstrapFilenames {
+		if strings.HasSuffix(request.URL.Path, "/"+filename) {
+			return true
+		}
+	}
+	return false
+}
+
+type countingBody struct {
+	io.ReadCloser
+	count *atomic.Int64
+}
+
+func (b *countingBody) Read(buffer []byte) (int, error) {
+	n, err := b.ReadCloser.Read(buffer)
+	b.count.Add(int64(n))
+	return n, err
+}
+
+// statsSummary is the JSON form of the -stats block.
+type statsSummary struct {
+	Lookups            int            `json:"lookups"`
+	Outcomes           map[string]int `json:"outcomes"`
+	Requests           int            `json:"requests"`
+	BootstrapDownloads int            `json:"bootstrap_downloads"`
+	BootstrapCacheHits int            `json:"bootstrap_cache_hits"`
+	RequestsByRegistry map[string]int `json:"requests_by_registry"`
+	Countries          map[string]int `json:"countries"`
+	LatencyMeanMillis  float64        `json:"latency_mean_ms"`
+	LatencyP50Millis   float64        `json:"latency_p50_ms"`
+	LatencyP90Millis   float64        `json:"latency_p90_ms"`
+	LatencyP99Millis   float64        `json:"latency_p99_ms"`
+	BytesTransferred   int64          `json:"bytes_transferred"`
+}
+
+func (s *runStats) summary() statsSummary {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	summary := statsSummary{
+		Lookups:            s.lookups,
+		Outcomes:           s.outcomes,
+		Requests:           s.requests,
+		BootstrapDownloads: s.bootstrapDownloads,
+		BootstrapCacheHits: s.bootstrapHits,
+		RequestsByRegistry: s.requestsByRegistry,
+		Countries:          s.countries,
+		BytesTransferred:   s.bytes.Load(),
+	}
+	if len(s.durations) > 0 {
+		sorted := sortedDurations(s.durations)
+		var total time.Duration
+		for _, duration := range sorted {
+			total += duration
+		}
+		millis := func(duration time.Duration) float64 { return float64(duration.Microseconds()) / 1000 }
+		summary.LatencyMeanMillis = millis(total / time.Duration(len(sorted)))
+		summary.LatencyP50Millis = millis(percentile(sorted, 50))
+		summary.LatencyP90Millis = millis(percentile(sorted, 90))
+		summary.LatencyP99Millis = millis(percentile(sorted, 99))
+	}
+	return summary
+}
+
+// writeStats prints the summary as "human" text or "json".
+func (s *runStats) writeStats(output io.Writer, format string) error {
+	summary := s.summary()
+	if format == "json" {
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summary)
+	}
+	row := func(label, value string) {
+		fmt.Fprintf(output, "  %-17s %s\n", localize(label)+":", value)
+	}
+	fmt.Fprintln(output, "\n"+localize("Run statistics:"))
+	row("lookups", fmt.Sprintf("%d (%s)", summary.Lookups, formatCounts(summary.Outcomes)))
+	row("http requests", fmt.Sprintf(localize("%d (%d bootstrap downloads)"), summary.Requests, summary.BootstrapDownloads))
+	row("cache hits", fmt.Sprintf(localize("%d lookups used the cached bootstrap registry"), summary.BootstrapCacheHits))
+	row("per registry", formatCounts(summary.RequestsByRegistry))
+	row("per country", formatCounts(summary.Countries))
+	row("latency", fmt.Sprintf("mean %.1fms, p50 %.1fms, p90 %.1fms, p99 %.1fms",
+		summary.LatencyMeanMillis, summary.LatencyP50Millis, summary.LatencyP90Millis, summary.LatencyP99Millis))
+	row("bytes received", fmt.Sprint(summary.BytesTransferred))
+	return nil
+}
+
+// formatCounts renders counts as "key=n" pairs, largest first.
+func formatCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return localize("none")
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for index, key := range keys {
+		parts[index] = fmt.Sprintf("%s=%d", key, counts[key])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// statsTransport reports every round trip to a runStats.
+type statsTransport struct {
+	base  http.RoundTripper
+	stats *runStats
+}
+
+func (t *statsTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := t.base.RoundTrip(request)
+	t.stats.countRequest(request, response)
+	return response, err
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// monitorStatus aggregates check outcomes per RDAP endpoint over a rolling
+// window and renders them for the /status page.
+type monitorStatus struct {
+	mutex  sync.Mutex
+	window time.Duration
+	checks []monitorCheck
+}
+
+type monitorCheck struct {
+	Time     time.Time `json:"time"`
+	Endpoint string    `json:"endpoint"`
+	Vantage  string    `json:"vantage,omitempty"`
+	Target   string    `json:"target"`
+	OK       bool      `json:"ok"`
+	Message  string    `json:"message,omitempty"`
+}
+
+type endpointStatus struct {
+	Endpoint  string         `json:"endpoint"`
+	Vantage   string         `json:"vantage,omitempty"`
+	Checks    int            `json:"checks"`
+	Failures  int            `json:"failures"`
+	UptimePct float64        `json:"uptime_pct"`
+	LastCheck time.Time      `json:"last_check"`
+	Incidents []monitorCheck `json:"incidents"`
+}
+
+type statusReport struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	Window      string           `json:"window"`
+	Endpoints   []endpointStatus `json:"endpoints"`
+}
+
+// maxIncidentsPerEndpoint bounds how many recent incidents the page lists.
+const maxIncidentsPerEndpoint = 20
+
+func newMonitorStatus(window time.Duration) *monitorStatus {
+	return &monitorStatus{window: window}
+}
+
+// record stores one check result. Failed checks and detected changes count as
+// incidents; only failed checks count against uptime. Checks made from
+// different vantage points are summarized separately.
+func (m *monitorStatus) record(endpoint, vantage, target string, ok bool, message string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now().UTC()
+	m.checks = append(m.checks, monitorCheck{Time: now, Endpoint: endpoint, Vantage: vantage, Target: target, OK: ok, Message: message})
+	cutoff := now.Add(-m.window)
+	firstKept := 0
+	for firstKept < len(m.checks) && m.checks[firstKept].Time.Before(cutoff) {
+		firstKept++
+	}
+	m.checks = m.checks[firstKept:]
+}
+
+func (m *monitorStatus) report() statusReport {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	byEndpoint := map[string]*endpointStatus{}
+	for _, check := range m.checks {
+		key := check.Endpoint + " " + check.Vantage
+		status := byEndpoint[key]
+		if status == nil {
+			status = &endpointStatus{Endpoint: check.Endpoint, Vantage: check.Vantage}
+			byEndpoint[key] = status
+		}
+		status.Checks++
+		if !check.OK {
+			status.Failures++
+		}
+		status.LastCheck = check.Time
+		if !check.OK || check.Message != "" {
+			status.Incidents = append(status.Incidents, check)
+		}
+	}
+
+	report := statusReport{GeneratedAt: time.Now().UTC(), Window: m.window.String()}
+	for _, status := range byEndpoint {
+		status.UptimePct = 100 * float64(status.Checks-status.Failures) / float64(status.Checks)
+		// Newest incidents first, capped
+		sort.Slice(status.Incidents, func(i, j int) bool { return status.Incidents[i].Time.After(status.Incidents[j].Time) })
+		if len(status.Incidents) > maxIncidentsPerEndpoint {
+			status.Incidents = status.Incidents[:maxIncidentsPerEndpoint]
+		}
+		report.Endpoints = append(report.Endpoints, *status)
+	}
+	sort.Slice(report.Endpoints, func(i, j int) bool {
+		if report.Endpoints[i].Endpoint != report.Endpoints[j].Endpoint {
+			return report.Endpoints[i].Endpoint < report.Endpoints[j].Endpoint
+		}
+		return report.Endpoints[i].Vantage < report.Endpoints[j].Vantage
+	})
+	return report
+}
+
+var statusPageTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta http-equiv="refresh" content="60">
+<title>RDAP endpoint status</title>
+<style>body{font-family:sans-serif}td,th{padding:4px 10px;text-align:left}.bad{color:#b00}.ok{color:#080}</style>
+</head><body>
+<h1>RDAP endpoint status</h1>
+<p>Window: {{.Window}} &middot; generated {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</p>
+<table>
+<tr><th>Endpoint</th><th>Vantage</th><th>Uptime</th><th>Checks</th><th>Failures</th><th>Last check</th></tr>
+{{range .Endpoints}}<tr><td>{{.Endpoint}}</td><td>{{.Vantage}}</td><td class="{{if lt .UptimePct 100.0}}bad{{else}}ok{{end}}">{{printf "%.1f" .UptimePct}}%</td><td>{{.Checks}}</td><td>{{.Failures}}</td><td>{{.LastCheck.Format "15:04:05"}}</td></tr>
+{{end}}</table>
+<h2>Recent incidents</h2>
+<ul>
+{{range .Endpoints}}{{$endpoint := .Endpoint}}{{range .Incidents}}<li>{{.Time.Format "2006-01-02 15:04:05"}} {{$endpoint}}{{if .Vantage}} via {{.Vantage}}{{end}} {{.Target}}: {{.Message}}</li>
+{{end}}{{end}}</ul>
+</body></html>
+`))
+
+// ServeHTTP renders the status report as JSON, or as HTML when the client asks
+// for it via ?format=html or an Accept header preferring text/html.
+func (m *monitorStatus) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	report := m.report()
+	wantsHTML := request.URL.Query().Get("format") == "html" ||
+		(request.URL.Query().Get("format") == "" && strings.Contains(request.Header.Get("Accept"), "text/html"))
+	if wantsHTML {
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = statusPageTemplate.Execute(writer, report)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(report)
+}
+
+// endpointOf reduces an RDAP URL to its scheme and host, which identifies the
+// registry endpoint on the status page.
+func endpointOf(rdapURL string) string {
+	parsed, err := url.Parse(rdapURL)
+	if err != nil || parsed.Host == "" {
+		return "(bootstrap)"
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Sweep outcomes of one domain.
+const (
+	sweepRegistered = "registered"
+	sweepNotFound   = "notfound"
+	sweepError      = "error"
+)
+
+// sweepRecord is one domain of a sweep, as printed by -json.
+type sweepRecord struct {
+	Domain    string `json:"domain"`
+	Status    string `json:"status"`
+	Registrar string `json:"registrar,omitempty"`
+	Expires   string `json:"expires,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// sweepRecordOf classifies a domain lookup: registered when the registry
+// returned the domain, notfound on RDAP 404 (the name is likely available),
+// error for anything else, which says nothing about availability.
+func sweepRecordOf(result lookupResult) sweepRecord {
+	record := sweepRecord{Domain: result.Target}
+	switch resultClass(result.Err) {
+	case classOK:
+		record.Status = sweepRegistered
+		if result.Domain != nil {
+			record.Registrar, record.Expires = result.Domain.Registrar, result.Domain.Expires
+		}
+	case classNotFound:
+		record.Status = sweepNotFound
+	default:
+		record.Status, record.Error = sweepError, result.Err.Error()
+	}
+	return record
+}
+
+func (r sweepRecord) line() string {
+	switch r.Status {
+	case sweepRegistered:
+		line := fmt.Sprintf(localize("%s: registered"), r.Domain)
+		if r.Registrar != "" {
+			line += "; " + fmt.Sprintf(localize("registrar %s"), r.Registrar)
+		}
+		if r.Expires != "" {
+			line += "; " + fmt.Sprintf(localize("expires %s"), r.Expires)
+		}
+		return line
+	case sweepNotFound:
+		return fmt.Sprintf(localize("%s: not found"), r.Domain)
+	default:
+		return fmt.Sprintf(localize("%s: error: %v"), r.Domain, r.Error)
+	}
+}
+
+func runSweep(args []string) int {
+	flagSet := newFlagSet("sweep")
+	var options clientOptions
+	options.registerFlags(flagSet)
+	domainsPath := flagSet.String("domains", "", "`file` of domain names, one or more per line (# starts a comment; - is stdin)")
+	rate := flagSet.Float64("rate", 5, "start at most this many `queries` per second across all workers (0 for no limit)")
+	concurrency := flagSet.Int("concurrency", 4, "run at most `n` queries at once")
+	jsonOutput := flagSet.Bool("json", false, "emit one JSON object per domain (domain, status, registrar, expires, error)")
+	language := flagSet.String("lang", "", "language of the report: en, de, es or fr (default from LC_ALL, LC_MESSAGES or LANG)")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . sweep --domains list.txt [--rate 5] [--concurrency 4] [--json] [domain ...]")
+		flagSet.PrintDefaults()
+	}
+	positional, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	messageLanguage = detectLanguage(*language)
+	if err := options.validate(); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if *rate < 0 || *concurrency < 1 {
+		fmt.Println("sweep: -rate must not be negative and -concurrency must be at least 1")
+		return 2
+	}
+	domains := positional
+	if *domainsPath != "" {
+		listed, err := readTargetsFile(*domainsPath)
+		if err != nil {
+			fmt.Printf("sweep: %v\n", err)
+			return 2
+		}
+		domains = append(domains, listed...)
+	}
+	if len(domains) == 0 {
+		flagSet.Usage()
+		return 2
+	}
+
+	clients := newVantageClients(options, nil, nil)
+	if *concurrency > 1 {
+		warmBootstrap(clients, domains, true)
+	}
+	var pace <-chan time.Time
+	if *rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+		defer ticker.Stop()
+		pace = ticker.C
+	}
+	counts := map[string]int{}
+	encoder := json.NewEncoder(os.Stdout)
+	output := newOrderedOutput(func(result lookupResult) {
+		record := sweepRecordOf(result)
+		counts[record.Status]++
+		if *jsonOutput {
+			_ = encoder.Encode(record)
+		} else {
+			fmt.Println(record.line())
+		}
+	})
+
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	context.AfterFunc(ctx, stopSignals)
+	var collect sync.Mutex
+	rows := make(chan int)
+	var workers sync.WaitGroup
+	for range *concurrency {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			client := workerClients(clients)[0].Client
+			for row := range rows {
+				var result lookupResult
+				if name, err := parseDomainName(domains[row]); err != nil {
+					result = lookupResult{Target: domains[row], Err: err}
+				} else {
+					result = rdapDomainLookup(ctx, client, name)
+					result.Target = name
+				}
+				results := []lookupResult{result}
+				if ctx.Err() != nil {
+					results = nil // interrupted, not an error of the domain
+				}
+				collect.Lock()
+				output.complete(row, results)
+				collect.Unlock()
+			}
+		}()
+	}
+dispatch:
+	for row, domain := range domains {
+		// Invalid names never reach a registry, so they do not wait.
+		if _, err := parseDomainName(domain); err == nil && pace != nil && row > 0 {
+			select {
+			case <-pace:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		select {
+		case rows <- row:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(rows)
+	workers.Wait()
+
+	if !*jsonOutput {
+		fmt.Printf(localize("%d registered, %d not found, %d errors")+"\n", counts[sweepRegistered], counts[sweepNotFound], counts[sweepError])
+	}
+	if ctx.Err() != nil {
+		return 130
+	}
+	if counts[sweepError] > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readTargets reads targets from reader, one or more per line separated by
+// whitespace; # starts a comment. Lines are streamed, so lists of tens of
+// thousands of ASNs extracted from flow logs need no giant argv.
+func readTargets(reader io.Reader) ([]string, error) {
+	targets, _, err := readTargetLines(reader, lineRange{})
+	return targets, err
+}
+
+// readTargetLines is readTargets for the lines within lines only, returning
+// with each target the number of the line it was read from. Reading stops
+// after lines.End, so the rest of a huge stream is never consumed.
+func readTargetLines(reader io.Reader, lines lineRange) (targets []string, numbers []int, err error) {
+	scanner := bufio.NewScanner(reader)
+	for number := 1; scanner.Scan(); number++ {
+		if lines.End > 0 && number > lines.End {
+			break
+		}
+		if number < lines.Start {
+			continue
+		}
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		for _, target := range strings.Fields(line) {
+			targets = append(targets, target)
+			numbers = append(numbers, number)
+		}
+	}
+	return targets, numbers, scanner.Err()
+}
+
+// readTargetsFile reads the targets in path, or in stdin when path is "-".
+func readTargetsFile(path string) ([]string, error) {
+	targets, _, err := readTargetLinesFile(path, lineRange{})
+	return targets, err
+}
+
+// readTargetLinesFile is readTargetLines over path, or stdin when path is "-".
+func readTargetLinesFile(path string, lines lineRange) ([]string, []int, error) {
+	if path == "-" {
+		return readTargetLines(os.Stdin, lines)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	return readTargetLines(file, lines)
+}
+
+// lineRange is the -start-line/-end-line window of input lines whose
+// targets are looked up, so a failed stretch of a large job can be re-run
+// on its own. Zero bounds are open.
+type lineRange struct {
+	Start, End int
+}
+
+func (r lineRange) set() bool { return r.Start > 0 || r.End > 0 }
+
+// targetFileFlag collects -f paths.
+type targetFileFlag []string
+
+func (f *targetFileFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *targetFileFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// maxRangeTargets caps the ASNs one start-end argument expands into, so a
+// typo such as 1-4200000000 does not queue billions of lookups.
+const maxRangeTargets = 65536
+
+// expandASNRanges replaces every "start-end" target (e.g. 13335-13400 or
+// AS13335-AS13400) with the ASNs of the range, in order. Other targets are
+// kept as they are. lines, when not nil, holds the input line of each
+// target and is expanded alongside.
+func expandASNRanges(targets []string, lines []int) ([]string, []int, error) {
+	var expanded []string
+	var expandedLines []int
+	keep := func(target string, index int) {
+		expanded = append(expanded, target)
+		if lines != nil {
+			expandedLines = append(expandedLines, lines[index])
+		}
+	}
+	for index, target := range targets {
+		if !strings.Contains(target, "-") {
+			keep(target, index)
+			continue
+		}
+		span, err := parseASNRange(target)
+		if err != nil {
+			keep(target, index)
+			continue
+		}
+		if span.High-span.Low >= maxRangeTargets {
+			return nil, nil, fmt.Errorf("range %s spans %d ASNs, more than %d", target, span.High-span.Low+1, maxRangeTargets)
+		}
+		for asn := span.Low; asn <= span.High; asn++ {
+			keep("AS"+strconv.FormatInt(asn, 10), index)
+		}
+	}
+	return expanded, expandedLines, nil
+}
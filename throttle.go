@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Backoff after a 429 or 503 without Retry-After doubles from
+// initialBackoff up to maxBackoff; Retry-After is honored up to maxBackoff.
+const (
+	initialBackoff = time.Second
+	maxBackoff     = 2 * time.Minute
+)
+
+// serverThrottle paces the queries sent to one RDAP server (scheme and
+// host of the base URL the bootstrap selected) and holds every query back
+// while the server has asked clients to back off.
+type serverThrottle struct {
+	mutex       sync.Mutex
+	next        time.Time // earliest start of the next query under -rate-limit
+	pausedUntil time.Time // end of the server's last requested backoff
+}
+
+// serverThrottles are shared by every client in the process, so workers
+// and vantage points querying one registry back off together.
+var serverThrottles = struct {
+	sync.Mutex
+	byServer map[string]*serverThrottle
+}{byServer: map[string]*serverThrottle{}}
+
+func throttleFor(server string) *serverThrottle {
+	serverThrottles.Lock()
+	defer serverThrottles.Unlock()
+	throttle, ok := serverThrottles.byServer[server]
+	if !ok {
+		throttle = &serverThrottle{}
+		serverThrottles.byServer[server] = throttle
+	}
+	return throttle
+}
+
+// reserve returns when the next query to the server may start, at most
+// rate queries per second (0 is unlimited) and never during a backoff.
+func (s *serverThrottle) reserve(rate float64, now time.Time) time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	start := now
+	if s.pausedUntil.After(start) {
+		start = s.pausedUntil
+	}
+	if rate > 0 {
+		if s.next.After(start) {
+			start = s.next
+		}
+		s.next = start.Add(time.Duration(float64(time.Second) / rate))
+	}
+	return start
+}
+
+// pause holds queries to the server back until until.
+func (s *serverThrottle) pause(until time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if until.After(s.pausedUntil) {
+		s.pausedUntil = until
+	}
+}
+
+// throttleTransport rate-limits queries per RDAP server (-rate-limit) and
+// retries those answered 429 Too Many Requests or 503 Service Unavailable
+// up to retries times, waiting as long as Retry-After asks or, without
+// it, with exponential backoff. Each attempt runs under timeout (-timeout),
+// from sending the request to reading the end of the response body;
+// backoff waits between attempts do not count against it, and 0 waits as
+// long as the server takes.
+type throttleTransport struct {
+	base    http.RoundTripper
+	rate    float64
+	retries int
+	timeout time.Duration
+}
+
+func (t *throttleTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if isBootstrapRequest(request) {
+		return t.base.RoundTrip(request)
+	}
+	throttle := throttleFor(request.URL.Scheme + "://" + request.URL.Host)
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		if err := sleepContext(request.Context(), time.Until(throttle.reserve(t.rate, time.Now()))); err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		response, err := t.attempt(request)
+		if err != nil || attempt >= t.retries ||
+			(response.StatusCode != http.StatusTooManyRequests && response.StatusCode != http.StatusServiceUnavailable) {
+			return response, err
+		}
+		recordRetriedAttempt(request, response, nil, time.Since(start))
+		wait, ok := retryAfter(response.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			wait, backoff = backoff, min(backoff*2, maxBackoff)
+		}
+		wait = min(wait, maxBackoff)
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+		slog.Info("server asked to back off", "server", request.URL.Host, "status", response.StatusCode, "wait", wait, "retry", attempt+1)
+		throttle.pause(time.Now().Add(wait))
+	}
+}
+
+// attempt sends request once under timeout, which ends when the response
+// body is closed.
+func (t *throttleTransport) attempt(request *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.base.RoundTrip(request)
+	}
+	ctx, cancel := context.WithTimeout(request.Context(), t.timeout)
+	response, err := t.base.RoundTrip(request.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	response.Body = &cancelOnClose{ReadCloser: response.Body, cancel: cancel}
+	return response, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// retryAfter parses a Retry-After header, either delay seconds or an HTTP
+// date.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// sleepContext waits for d, or returns early with the error of ctx.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// defaultDisplayNameLimit is the longest name, in runes, shown in the
+// console views unless -max-name-len says otherwise. Structured outputs
+// always carry the full name.
+const defaultDisplayNameLimit = 40
+
+// displayName normalizes a name for display: surrounding whitespace is
+// trimmed and inner runs of whitespace (line breaks in remarks, padding)
+// become one space.
+func displayName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// truncateName normalizes name with displayName, then shortens it to at
+// most limit runes, ending with an ellipsis. Normalizing first means
+// whitespace never counts against the limit. It cuts at the last word
+// boundary when one falls in the second half of the allowed length, and
+// otherwise at a rune boundary (scripts without spaces, very long words),
+// never separating a base character from its combining marks. A limit
+// leaving no room for the ellipsis and some of the name cuts without one.
+// A limit of 0 or less means no truncation.
+func truncateName(name string, limit int) string {
+	name = displayName(name)
+	runes := []rune(name)
+	if limit <= 0 || len(runes) <= limit {
+		return name
+	}
+	mark := ellipsis()
+	if limit <= len([]rune(mark)) {
+		mark = ""
+	}
+	cut := limit - len([]rune(mark)) // leave room for the ellipsis
+	for cut > 0 && unicode.Is(unicode.Mn, runes[cut]) {
+		cut--
+	}
+	if !unicode.IsSpace(runes[cut]) {
+		for boundary := cut; boundary > limit/2; boundary-- {
+			if unicode.IsSpace(runes[boundary]) {
+				cut = boundary
+				break
+			}
+		}
+	}
+	kept := strings.TrimRightFunc(string(runes[:cut]), func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(",;:-/&(", r)
+	})
+	return kept + mark
+}
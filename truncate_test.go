@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateNameShortLimits(t *testing.T) {
+	tests := []struct {
+		ascii bool
+		limit int
+		want  string
+	}{
+		{false, 1, "E"},
+		{false, 2, "E…"},
+		{false, 3, "Ex…"},
+		{true, 1, "E"},
+		{true, 2, "Ex"},
+		{true, 3, "Exa"},
+		{true, 4, "E..."},
+	}
+	saved := console
+	defer func() { console = saved }()
+	for _, test := range tests {
+		console.ASCII = test.ascii
+		got := truncateName("Example Org Inc.", test.limit)
+		if got != test.want {
+			t.Errorf("ascii %v, limit %d: got %q, want %q", test.ascii, test.limit, got, test.want)
+		}
+		if length := utf8.RuneCountInString(got); length > test.limit {
+			t.Errorf("ascii %v, limit %d: %q is %d runes long", test.ascii, test.limit, got, length)
+		}
+	}
+}
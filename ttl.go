@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	rdap "github.com/openrdap/rdap"
+)
+
+// validUntil derives how long an answer may be reused from the caching
+// headers of the exchange that produced it, so downstream caches can expire
+// enrichment data as the registry intends instead of using a blanket TTL.
+// s-maxage wins over max-age (downstream stores are shared caches), both
+// win over Expires, and no-store or no-cache make the answer stale at once.
+// The zero time means the registry gave no hint.
+func validUntil(exchange *rdap.HTTPResponse, received time.Time) time.Time {
+	if exchange == nil || exchange.Response == nil {
+		return time.Time{}
+	}
+	header := exchange.Response.Header
+	directives := map[string]string{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, argument, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			directives[strings.ToLower(name)] = strings.Trim(argument, `"`)
+		}
+	}
+	if _, ok := directives["no-store"]; ok {
+		return received
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return received
+	}
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if seconds, err := strconv.ParseInt(directives[name], 10, 64); err == nil && seconds >= 0 {
+			// Age is how long the answer already sat in caches upstream.
+			age, _ := strconv.ParseInt(header.Get("Age"), 10, 64)
+			return received.Add(time.Duration(max(seconds-max(age, 0), 0)) * time.Second).Truncate(time.Second)
+		}
+	}
+	if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		// Measure Expires against the server's Date to cancel clock skew.
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			return received.Add(expires.Sub(date)).Truncate(time.Second)
+		}
+		return expires
+	} else if header.Get("Expires") != "" {
+		// An invalid Expires (often "0") means already expired.
+		return received
+	}
+	return time.Time{}
+}
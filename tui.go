@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+	rdap "github.com/openrdap/rdap"
+	"golang.org/x/term"
+)
+
+// tuiRow is one lookup shown in the results table.
+type tuiRow struct {
+	Target   string
+	Name     string
+	Err      error
+	Cached   bool
+	Duration time.Duration
+	RawJSON  string
+}
+
+// tuiState is the whole interactive session: input line, results, selection
+// and the scroll offset of the detail pane.
+type tuiState struct {
+	client       *rdap.Client
+	input        []rune
+	rows         []tuiRow
+	cache        map[int64]tuiRow
+	selected     int
+	detailOffset int
+	status       string
+}
+
+func runTUI(args []string) int {
+	flagSet := newFlagSet("tui")
+	var options clientOptions
+	options.registerFlags(flagSet)
+	if _, err := parseInterspersed(flagSet, args); err != nil {
+		return 2
+	}
+	if err := options.validate(); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Println("tui: stdin and stdout must be a terminal")
+		return 2
+	}
+
+	previousState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		fmt.Printf("tui: %v\n", err)
+		return 1
+	}
+	defer term.Restore(int(os.Stdin.Fd()), previousState)
+	fmt.Print("\x1b[?1049h") // alternate screen
+	defer fmt.Print("\x1b[?1049l")
+
+	state := &tuiState{
+		client: newRDAPClient(options),
+		cache:  map[int64]tuiRow{},
+		status: "Type an ASN and press Enter. Up/Down select, PgUp/PgDn scroll JSON, Ctrl-R refresh, Ctrl-C quit.",
+	}
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		state.render()
+		key, err := readTUIKey(reader)
+		if err != nil {
+			return 0
+		}
+		switch key {
+		case "ctrl-c", "ctrl-d":
+			return 0
+		case "enter":
+			state.submit(false)
+		case "ctrl-r":
+			state.refreshSelected()
+		case "backspace":
+			if len(state.input) > 0 {
+				state.input = state.input[:len(state.input)-1]
+			}
+		case "up":
+			if state.selected > 0 {
+				state.selected--
+				state.detailOffset = 0
+			}
+		case "down":
+			if state.selected < len(state.rows)-1 {
+				state.selected++
+				state.detailOffset = 0
+			}
+		case "pgup":
+			state.detailOffset = max(0, state.detailOffset-10)
+		case "pgdn":
+			state.detailOffset += 10
+		default:
+			if runes := []rune(key); len(runes) == 1 {
+				state.input = append(state.input, runes[0])
+			}
+		}
+	}
+}
+
+// readTUIKey decodes one keypress, including common ANSI escape sequences.
+func readTUIKey(reader *bufio.Reader) (string, error) {
+	character, _, err := reader.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	switch character {
+	case 3:
+		return "ctrl-c", nil
+	case 4:
+		return "ctrl-d", nil
+	case 18:
+		return "ctrl-r", nil
+	case '\r', '\n':
+		return "enter", nil
+	case 127, 8:
+		return "backspace", nil
+	case 27:
+		if reader.Buffered() == 0 {
+			return "escape", nil
+		}
+		sequence := make([]byte, 0, 4)
+		for reader.Buffered() > 0 && len(sequence) < 4 {
+			next, _ := reader.ReadByte()
+			sequence = append(sequence, next)
+			if next >= 'A' && next <= 'Z' || next == '~' {
+				break
+			}
+		}
+		switch string(sequence) {
+		case "[A", "OA":
+			return "up", nil
+		case "[B", "OB":
+			return "down", nil
+		case "[5~":
+			return "pgup", nil
+		case "[6~":
+			return "pgdn", nil
+		}
+		return "escape", nil
+	}
+	if character < 32 {
+		return "", nil
+	}
+	return string(character), nil
+}
+
+func (s *tuiState) submit(bypassCache bool) {
+	target := strings.TrimSpace(string(s.input))
+	s.input = s.input[:0]
+	if target == "" {
+		return
+	}
+	asn, err := parseASN(target)
+	if err != nil {
+		s.status = fmt.Sprintf("%s: invalid ASN", target)
+		return
+	}
+	s.lookup(asn, bypassCache)
+}
+
+func (s *tuiState) refreshSelected() {
+	if len(s.rows) == 0 {
+		return
+	}
+	asn, err := parseASN(s.rows[s.selected].Target)
+	if err == nil {
+		s.lookup(asn, true)
+	}
+}
+
+// lookup queries asn (or reuses the session cache) and selects the new row.
+func (s *tuiState) lookup(asn int64, bypassCache bool) {
+	if cached, ok := s.cache[asn]; ok && !bypassCache {
+		cached.Cached = true
+		cached.Duration = 0
+		s.rows = append(s.rows, cached)
+	} else {
+		s.status = fmt.Sprintf("Querying AS%d ...", asn)
+		s.render()
+		row := tuiRow{Target: "AS" + strconv.FormatInt(asn, 10)}
+		start := time.Now()
+		fetch, err := fetchAutnum(context.Background(), s.client, asn)
+		row.Duration = time.Since(start)
+		if err != nil {
+			row.Err = err
+		} else {
+			row.Name = rdaplookup.ExtractName(fetch.Record)
+			var indented bytes.Buffer
+			if json.Indent(&indented, fetch.RawBody, "", "  ") == nil {
+				row.RawJSON = indented.String()
+			} else {
+				row.RawJSON = string(fetch.RawBody)
+			}
+			s.cache[asn] = row
+		}
+		recordHistory("tui", row.Target, row.Name, row.Err)
+		s.rows = append(s.rows, row)
+	}
+	s.selected = len(s.rows) - 1
+	s.detailOffset = 0
+	s.status = fmt.Sprintf("%d lookup(s), %d cached object(s)", len(s.rows), len(s.cache))
+}
+
+// render redraws the full screen: input line, results table, detail pane and
+// status line. The table takes roughly a third of the available height.
+func (s *tuiState) render() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width < 20 || height < 10 {
+		width, height = 80, 24
+	}
+	tableHeight := max(3, (height-6)/3)
+	detailHeight := height - tableHeight - 6
+
+	var screen strings.Builder
+	screen.WriteString("\x1b[H\x1b[2J")
+	// writeStyledLine truncates to the terminal width before applying an SGR
+	// style so escape sequences are never cut off.
+	writeStyledLine := func(style, text string) {
+		runes := []rune(text)
+		if len(runes) > width {
+			runes = runes[:width]
+		}
+		screen.WriteString(styled(style, string(runes)))
+		screen.WriteString("\x1b[K\r\n")
+	}
+	writeLine := func(text string) { writeStyledLine("", text) }
+
+	writeLine("RDAP lookup> " + string(s.input))
+	writeStyledLine("7", fmt.Sprintf(" %-4s %-12s %-40s %-8s %9s ", "#", "target", "name", "cache", "time"))
+	firstRow := max(0, s.selected-tableHeight+1)
+	for index := firstRow; index < firstRow+tableHeight; index++ {
+		if index >= len(s.rows) {
+			writeLine("")
+			continue
+		}
+		row := s.rows[index]
+		name := truncateName(row.Name, console.NameLimit)
+		if row.Err != nil {
+			name = "error: " + row.Err.Error()
+		}
+		cacheLabel := "live"
+		if row.Cached {
+			cacheLabel = "cached"
+		}
+		cursor := " "
+		if index == s.selected && !console.Color {
+			cursor = ">"
+		}
+		line := fmt.Sprintf("%s%-4d %-12s %-40.40s %-8s %9s ", cursor, index+1, row.Target, name, cacheLabel, formatMillis(row.Duration))
+		if index == s.selected {
+			writeStyledLine("7", line)
+		} else {
+			writeLine(line)
+		}
+	}
+
+	writeLine(horizontalRule(width))
+	var detailLines []string
+	if len(s.rows) > 0 {
+		detailLines = strings.Split(s.rows[s.selected].RawJSON, "\n")
+	}
+	s.detailOffset = min(s.detailOffset, max(0, len(detailLines)-detailHeight))
+	for line := 0; line < detailHeight; line++ {
+		if s.detailOffset+line < len(detailLines) {
+			writeLine(detailLines[s.detailOffset+line])
+		} else {
+			writeLine("")
+		}
+	}
+	writeLine(horizontalRule(width))
+	screen.WriteString(styled("2", s.status) + "\x1b[K")
+	// Park the cursor at the end of the input line.
+	screen.WriteString(fmt.Sprintf("\x1b[1;%dH", len("RDAP lookup> ")+len(s.input)+1))
+	fmt.Print(screen.String())
+}
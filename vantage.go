@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	rdap "github.com/openrdap/rdap"
+)
+
+// vantagePoint is a network location probes can be relayed through: a SOCKS5
+// proxy or a remote agent exposed as an HTTP(S) proxy.
+type vantagePoint struct {
+	Name     string
+	ProxyURL *url.URL // nil means a direct connection from this host
+}
+
+// vantageFlag collects repeated -vantage name=url arguments.
+type vantageFlag []vantagePoint
+
+func (v *vantageFlag) String() string {
+	var names []string
+	for _, point := range *v {
+		names = append(names, point.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+// Set parses "name=socks5://host:1080", "name=http://agent:3128" or a bare
+// proxy URL, in which case the proxy host names the vantage point.
+func (v *vantageFlag) Set(value string) error {
+	name, rawURL, named := strings.Cut(value, "=")
+	if !named {
+		rawURL = value
+	}
+	proxyURL, err := parseProxyURL(rawURL)
+	if err != nil {
+		return err
+	}
+	if !named {
+		name = proxyURL.Hostname()
+	}
+	*v = append(*v, vantagePoint{Name: name, ProxyURL: proxyURL})
+	return nil
+}
+
+// points returns the configured vantage points, or a single direct one.
+func (v vantageFlag) points() []vantagePoint {
+	if len(v) == 0 {
+		return []vantagePoint{{Name: "local"}}
+	}
+	return v
+}
+
+// parseProxyURL parses a SOCKS5 or HTTP(S) proxy URL.
+func parseProxyURL(rawURL string) (*url.URL, error) {
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", rawURL, err)
+	}
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h", "http", "https":
+		return proxyURL, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (use socks5, http or https)", proxyURL.Scheme)
+	}
+}
+
+// vantageClient is an RDAP client whose traffic leaves through one vantage point.
+type vantageClient struct {
+	Vantage string
+	Proxy   *url.URL
+	Client  *rdap.Client
+}
+
+// newVantageClients builds one RDAP client per vantage point from a common
+// set of options. tracer, when non-nil, supplies the per-vantage Trace hook.
+func newVantageClients(options clientOptions, vantages vantageFlag, tracer func(vantage string) func(*http.Request, requestTimings)) []vantageClient {
+	var clients []vantageClient
+	for _, point := range vantages.points() {
+		pointOptions := options
+		if point.ProxyURL != nil {
+			pointOptions.Proxy = point.ProxyURL
+		}
+		if tracer != nil {
+			pointOptions.Trace = tracer(point.Name)
+		}
+		clients = append(clients, vantageClient{Vantage: point.Name, Proxy: pointOptions.Proxy, Client: newRDAPClient(pointOptions)})
+	}
+	return clients
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"time"
+)
+
+// Verbosity levels selected with -v, -vv and -vvv. Verbose output is logged
+// at debug level through slog, so stdout only ever carries results.
+const (
+	verboseExtraction = 1 // which extraction step produced the name, redirect chains
+	verboseHTTP       = 2 // one line per HTTP request/response, full record JSON
+	verboseTrace      = 3 // httptrace events: DNS, connect, TLS, first byte
+)
+
+// verbosityFlag is a boolean-style flag that raises the verbosity to level
+// when present; -v, -vv and -vvv each register one.
+type verbosityFlag struct {
+	verbosity *int
+	level     int
+}
+
+func (f verbosityFlag) IsBoolFlag() bool { return true }
+
+func (f verbosityFlag) String() string {
+	if f.verbosity == nil {
+		return "false"
+	}
+	return strconv.FormatBool(*f.verbosity >= f.level)
+}
+
+func (f verbosityFlag) Set(value string) error {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		*f.verbosity = max(*f.verbosity, f.level)
+	}
+	return nil
+}
+
+// verboseLog logs msg at debug level when verbosity is at least level.
+func verboseLog(verbosity, level int, msg string, args ...any) {
+	if verbosity >= level {
+		slog.Debug(msg, args...)
+	}
+}
+
+// loggingTransport logs request/response summaries (-vv) and, at -vvv, the
+// httptrace events of every request.
+type loggingTransport struct {
+	base      http.RoundTripper
+	verbosity int
+}
+
+func (t *loggingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	start := time.Now()
+	logger := slog.With("url", request.URL.String())
+	traceEvent := func(event string, args ...any) {
+		logger.Debug("httptrace", append([]any{"event", event, "elapsed", time.Since(start)}, args...)...)
+	}
+	if t.verbosity >= verboseTrace {
+		trace := &httptrace.ClientTrace{
+			DNSStart: func(info httptrace.DNSStartInfo) { traceEvent("dns_start", "host", info.Host) },
+			DNSDone: func(info httptrace.DNSDoneInfo) {
+				traceEvent("dns_done", "addrs", info.Addrs, "error", info.Err)
+			},
+			ConnectStart: func(network, address string) {
+				traceEvent("connect_start", "network", network, "address", address)
+			},
+			ConnectDone: func(network, address string, err error) {
+				traceEvent("connect_done", "network", network, "address", address, "error", err)
+			},
+			GotConn: func(info httptrace.GotConnInfo) {
+				traceEvent("got_conn", "remote", info.Conn.RemoteAddr().String(), "reused", info.Reused)
+			},
+			TLSHandshakeStart: func() { traceEvent("tls_start") },
+			TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+				traceEvent("tls_done", "version", tls.VersionName(state.Version), "alpn", state.NegotiatedProtocol, "error", err)
+			},
+			WroteRequest:         func(httptrace.WroteRequestInfo) { traceEvent("wrote_request") },
+			GotFirstResponseByte: func() { traceEvent("first_byte") },
+		}
+		request = request.WithContext(httptrace.WithClientTrace(request.Context(), trace))
+	}
+	logger.Debug("http request", "method", request.Method)
+	response, err := t.base.RoundTrip(request)
+	if err != nil {
+		logger.Debug("http error", "elapsed", time.Since(start), "error", err)
+		return response, err
+	}
+	logger.Debug("http response", "proto", response.Proto, "status", response.StatusCode,
+		"content_type", response.Header.Get("Content-Type"), "bytes", response.ContentLength, "elapsed", time.Since(start))
+	return response, err
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+)
+
+// Warnings are caveats on the quality of an answer that, unlike errors, do
+// not make it fail.
+const (
+	warningStaleCache     = "stale cache used"
+	warningNameFromRemark = "name derived from a remark"
+	warningNameFromHandle = "name derived from the record's name or handle"
+	warningNoConformance  = "response not strictly RFC 9083 compliant: no rdapConformance"
+	warningNoObjectClass  = "response not strictly RFC 9083 compliant: no objectClassName"
+)
+
+// warnings returns the caveats of an answered result: where the name came
+// from when no organization vCard named it, a stale cache answer, and
+// where the response departs from RFC 9083.
+func (r lookupResult) warnings() []string {
+	if r.Err != nil || r.Skipped || r.Fetch == nil {
+		return nil
+	}
+	var warnings []string
+	if r.source() == sourceStaleCache {
+		warnings = append(warnings, warningStaleCache)
+	}
+	if r.Fetch.Record != nil && !r.Overridden {
+		_, step := rdaplookup.ExplainName(r.Fetch.Record)
+		switch {
+		case strings.HasPrefix(step, "step 2"), strings.HasPrefix(step, "step 3"):
+			warnings = append(warnings, warningNameFromRemark)
+		case strings.HasPrefix(step, "step 4"):
+			warnings = append(warnings, warningNameFromHandle)
+		}
+	}
+	return append(warnings, complianceWarnings(r.Fetch.RawBody)...)
+}
+
+// complianceWarnings checks the members RFC 9083 requires of every object
+// response.
+func complianceWarnings(body []byte) []string {
+	var document struct {
+		Conformance     []string `json:"rdapConformance"`
+		ObjectClassName string   `json:"objectClassName"`
+	}
+	if len(body) == 0 || json.Unmarshal(body, &document) != nil {
+		return nil
+	}
+	var warnings []string
+	if len(document.Conformance) == 0 {
+		warnings = append(warnings, warningNoConformance)
+	}
+	if strings.TrimSpace(document.ObjectClassName) == "" {
+		warnings = append(warnings, warningNoObjectClass)
+	}
+	return warnings
+}
+
+// warningNote renders the warnings after the result line.
+func (r lookupResult) warningNote() string {
+	warnings := r.warnings()
+	if len(warnings) == 0 {
+		return ""
+	}
+	for index, warning := range warnings {
+		warnings[index] = localize(warning)
+	}
+	return fmt.Sprintf(localize(" [warnings: %s]"), strings.Join(warnings, "; "))
+}
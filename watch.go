@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+	rdap "github.com/openrdap/rdap"
+)
+
+// watchTarget re-queries one ASN every interval, redrawing the normalized
+// record each time and highlighting fields that changed since the previous
+// poll. It runs until interrupted.
+func watchTarget(client *rdap.Client, asn int64, interval time.Duration) {
+	var previous map[string]string
+	changedAt := map[string]time.Time{}
+	for {
+		fetch, err := fetchAutnum(context.Background(), client, asn)
+		var fields map[string]string
+		if err == nil {
+			fields, err = normalizeRDAPResponse(fetch.RawBody)
+		}
+
+		var screen strings.Builder
+		if console.Color {
+			screen.WriteString("\x1b[H\x1b[2J")
+		} else {
+			// Without cursor control, append each poll below the previous one.
+			screen.WriteString(horizontalRule(72) + "\n")
+		}
+		fmt.Fprintf(&screen, "Every %s: AS%d%s%s\n\n", interval, asn, strings.Repeat(" ", 8), time.Now().Format(time.RFC1123))
+		if err != nil {
+			fmt.Fprintf(&screen, "error: %v\n", err)
+			fmt.Print(screen.String())
+			time.Sleep(interval)
+			continue
+		}
+
+		fmt.Fprintf(&screen, "organization: %s\n\n", truncateName(rdaplookup.ExtractName(fetch.Record), console.NameLimit))
+		if previous != nil {
+			for _, change := range diffFields(previous, fields) {
+				changedAt[change.Path] = time.Now()
+				if change.After == "(removed)" {
+					fmt.Fprintln(&screen, styled("31", fmt.Sprintf("- %s: %s", change.Path, change.Before)))
+				}
+			}
+		}
+		paths := make([]string, 0, len(fields))
+		for path := range fields {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			line := fmt.Sprintf("%s: %s", path, fields[path])
+			if when, changed := changedAt[path]; changed {
+				// Highlight fields changed in the last poll brightly, earlier changes dimly.
+				style := "33"
+				if time.Since(when) < interval {
+					style = "1;7"
+				}
+				marker := "* "
+				if console.Color {
+					marker = ""
+				}
+				line = fmt.Sprintf("%s%s  (changed %s)", marker, styled(style, line), when.Format("15:04:05"))
+			}
+			screen.WriteString(line + "\n")
+		}
+		fmt.Fprint(os.Stdout, screen.String())
+		previous = fields
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hookster007/rdap-test/pkg/rdaplookup"
+)
+
+// watchedFacts are the registration facts the watch command compares, in
+// the order their changes are reported. Changes to any of them, such as a
+// new organization name or abuse contact, can point at a hijacked
+// registration.
+var watchedFacts = []string{"name", "handle", "status", "country", "registrant", "abuse", "registrar", "nameservers", "range", "origin_asns"}
+
+// watchedObject is one ASN, IP address or prefix, or domain of a watch list.
+type watchedObject struct {
+	Target string // canonical, e.g. "AS15169" or "192.0.2.0/24"
+	Class  string // autnum, ip or domain
+	Query  string
+}
+
+// watchState is the -state file: the facts last seen per watched target.
+type watchState struct {
+	Objects map[string]watchSnapshot `json:"objects"`
+}
+
+type watchSnapshot struct {
+	CheckedAt time.Time         `json:"checked_at"`
+	Facts     map[string]string `json:"facts"`
+}
+
+// watchMonitor holds the state shared by successive watch rounds.
+type watchMonitor struct {
+	client    vantageClient
+	verbosity int
+	objects   []watchedObject
+	state     *watchState
+	statePath string
+	log       io.Writer // -log file; nil when not set
+	webhook   *webhookNotifier
+}
+
+func runWatch(args []string) int {
+	flagSet := newFlagSet("watch")
+	var options clientOptions
+	options.registerFlags(flagSet)
+	var files targetFileFlag
+	flagSet.Var(&files, "f", "read targets to watch from `file`, one or more per line (repeatable)")
+	interval := flagSet.Duration("interval", time.Hour, "time between query rounds")
+	rounds := flagSet.Int("count", 0, "number of rounds to run (0 = run until interrupted)")
+	statePath := flagSet.String("state", "watch-state.json", "`file` keeping the facts last seen per target (created if missing)")
+	logPath := flagSet.String("log", "", "also append change reports to this `file`")
+	var webhook webhookOptions
+	webhook.registerFlags(flagSet)
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "usage: go run . watch [flags] [-f file] <ASN|IP|prefix|domain>...")
+		fmt.Fprintln(flagSet.Output(), "Re-queries the targets every interval and reports changed registration facts.")
+		flagSet.PrintDefaults()
+	}
+	targets, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		return 2
+	}
+	if err := options.validate(); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	for _, path := range files {
+		listed, err := readTargetsFile(path)
+		if err != nil {
+			fmt.Printf("watch: %v\n", err)
+			return 2
+		}
+		targets = append(targets, listed...)
+	}
+	if len(targets) == 0 {
+		flagSet.Usage()
+		return 2
+	}
+	if *interval <= 0 {
+		fmt.Println("watch: -interval must be positive")
+		return 2
+	}
+	notifier, err := webhook.notifier()
+	if err != nil {
+		fmt.Printf("watch: %v\n", err)
+		return 2
+	}
+
+	monitor := &watchMonitor{verbosity: options.Verbosity, statePath: *statePath, webhook: notifier}
+	seen := map[string]bool{}
+	for _, target := range targets {
+		object, err := parseWatchedObject(target)
+		if err != nil {
+			fmt.Printf("watch: %v\n", err)
+			return 2
+		}
+		if !seen[object.Target] {
+			seen[object.Target] = true
+			monitor.objects = append(monitor.objects, object)
+		}
+	}
+	if monitor.state, err = loadWatchState(*statePath); err != nil {
+		fmt.Printf("watch: %v\n", err)
+		return 1
+	}
+	if *logPath != "" {
+		file, err := os.OpenFile(*logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			fmt.Printf("watch: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		monitor.log = file
+	}
+
+	monitor.client = newVantageClients(options, nil, nil)[0]
+	changesSeen := false
+	for round := 1; ; round++ {
+		changed, err := monitor.round()
+		if err != nil {
+			fmt.Printf("watch: %v\n", err)
+			return 1
+		}
+		changesSeen = changesSeen || changed
+		if *rounds > 0 && round >= *rounds {
+			break
+		}
+		time.Sleep(*interval)
+	}
+	if changesSeen {
+		return 1
+	}
+	return 0
+}
+
+// parseWatchedObject recognizes target as an ASN, then as an IP address
+// or prefix, then as a domain name.
+func parseWatchedObject(target string) (watchedObject, error) {
+	for _, class := range []string{"autnum", "ip", "domain"} {
+		query, ok := classQuery(class, target)
+		if !ok {
+			continue
+		}
+		object := watchedObject{Target: query, Class: class, Query: query}
+		if class == "autnum" {
+			object.Target = "AS" + query
+		}
+		return object, nil
+	}
+	return watchedObject{}, fmt.Errorf("%q is not an ASN, IP address, prefix or domain name", target)
+}
+
+// round looks every object up once, reports the facts that changed since
+// the previous round and reports whether any did.
+func (m *watchMonitor) round() (bool, error) {
+	changesSeen := false
+	stateDirty := false
+	for _, object := range m.objects {
+		changed, recorded := m.check(object)
+		changesSeen = changesSeen || changed
+		stateDirty = stateDirty || recorded
+	}
+	if stateDirty {
+		if err := saveWatchState(m.statePath, m.state); err != nil {
+			return changesSeen, err
+		}
+	}
+	return changesSeen, nil
+}
+
+// check looks one object up and compares its facts with the state. It
+// reports whether they changed and whether the state was modified. A
+// failed lookup keeps the facts last seen, so a registry outage is not
+// taken for a change.
+func (m *watchMonitor) check(object watchedObject) (changed, recorded bool) {
+	result := lookupClass(context.Background(), m.client.Client, object.Class, object.Query, m.verbosity, rdaplookup.DefaultFollowUps)
+	now := time.Now().UTC()
+	if result.Err != nil {
+		m.report(fmt.Sprintf("%s %s: error [%s]: %v", now.Format(time.RFC3339), object.Target, result.errorDetails(), result.Err))
+		return false, false
+	}
+	facts := watchFactsOf(result)
+	previous, known := m.state.Objects[object.Target]
+	m.state.Objects[object.Target] = watchSnapshot{CheckedAt: now, Facts: facts}
+	if !known {
+		fmt.Printf("%s %s: recorded (%d facts)\n", now.Format(time.RFC3339), object.Target, len(facts))
+		return false, true
+	}
+	var changes []fieldChange
+	for _, fact := range watchedFacts {
+		if before, after := previous.Facts[fact], facts[fact]; before != after {
+			changes = append(changes, fieldChange{Path: fact, Before: before, After: after})
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Printf("%s %s: unchanged\n", now.Format(time.RFC3339), object.Target)
+		return false, true
+	}
+	lines := []string{fmt.Sprintf("%s %s: %d fact(s) changed since %s", now.Format(time.RFC3339), object.Target, len(changes), previous.CheckedAt.Format(time.RFC3339))}
+	for _, change := range changes {
+		lines = append(lines, fmt.Sprintf("  %s: %q -> %q", change.Path, change.Before, change.After))
+	}
+	m.report(strings.Join(lines, "\n"))
+	event := changeEvent{Time: now, Source: "watch", Target: object.Target, Class: object.Class, Server: result.server(), Since: previous.CheckedAt, Changes: changes}
+	if err := m.webhook.notify(event); err != nil {
+		slog.Warn("webhook not delivered", "target", object.Target, "error", err)
+	}
+	return true, true
+}
+
+// report prints a change or error report and appends it to the -log file.
+func (m *watchMonitor) report(text string) {
+	fmt.Println(text)
+	if m.log == nil {
+		return
+	}
+	if _, err := fmt.Fprintln(m.log, text); err != nil {
+		slog.Warn("watch log not written", "error", err)
+	}
+}
+
+// watchFactsOf extracts the watchedFacts of a successful lookup; facts the
+// record does not have are left out.
+func watchFactsOf(result lookupResult) map[string]string {
+	record := result.record(false)
+	facts := map[string]string{
+		"name":    result.Name,
+		"handle":  record.Handle,
+		"country": record.Country,
+	}
+	if result.Fetch != nil {
+		var document struct {
+			Status []string `json:"status"`
+		}
+		if json.Unmarshal(result.Fetch.RawBody, &document) == nil {
+			status := slices.Clone(document.Status)
+			slices.Sort(status)
+			facts["status"] = strings.Join(status, ", ")
+		}
+	}
+	if registrant, ok := result.contactOfRole("registrant"); ok {
+		facts["registrant"] = strings.Trim(registrant.Organization+", "+registrant.Name, ", ")
+	}
+	if abuse, ok := result.contactOfRole("abuse"); ok {
+		facts["abuse"] = abuse.Email
+		if abuse.Email == "" {
+			facts["abuse"] = abuse.line()
+		}
+	}
+	if domain := result.Domain; domain != nil {
+		nameservers := slices.Clone(domain.Nameservers)
+		slices.Sort(nameservers)
+		facts["registrar"] = domain.Registrar
+		facts["nameservers"] = strings.Join(nameservers, ", ")
+		if facts["registrant"] == "" {
+			facts["registrant"] = domain.Registrant
+		}
+	}
+	if network := result.Network; network != nil {
+		origins := make([]string, len(network.OriginASNs))
+		for index, asn := range network.OriginASNs {
+			origins[index] = "AS" + strconv.FormatInt(asn, 10)
+		}
+		facts["range"] = network.Range
+		facts["origin_asns"] = strings.Join(origins, ", ")
+	}
+	for fact, value := range facts {
+		if value == "" {
+			delete(facts, fact)
+		}
+	}
+	return facts
+}
+
+func loadWatchState(path string) (*watchState, error) {
+	state := &watchState{Objects: map[string]watchSnapshot{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("parsing state %s: %w", path, err)
+	}
+	if state.Objects == nil {
+		state.Objects = map[string]watchSnapshot{}
+	}
+	return state, nil
+}
+
+func saveWatchState(path string, state *watchState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeCacheEntry(path, data); err != nil {
+		return fmt.Errorf("writing state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// changeEvent reports the changed fields of one monitored object; watch
+// and canary send one per changed object to -webhook.
+type changeEvent struct {
+	Time    time.Time     `json:"time"`
+	Source  string        `json:"source"` // watch or canary
+	Target  string        `json:"target"`
+	Class   string        `json:"class"`
+	Server  string        `json:"server,omitempty"`
+	Since   time.Time     `json:"since"`
+	Changes []fieldChange `json:"changes"`
+	// Message is the event rendered with -webhook-template.
+	Message string `json:"message"`
+}
+
+// defaultWebhookTemplate renders an event as a short chat message.
+const defaultWebhookTemplate = `{{.Target}}: {{len .Changes}} field(s) changed since {{.Since.Format "2006-01-02 15:04 MST"}}` +
+	`{{range .Changes}}` + "\n" + `• {{.Path}}: {{.Before}} → {{.After}}{{end}}`
+
+// webhookFormats are the payloads -webhook-format can send.
+var webhookFormats = []string{"json", "slack"}
+
+// webhookTimeout bounds one -webhook delivery.
+const webhookTimeout = 10 * time.Second
+
+// webhookOptions are the -webhook flags shared by the monitoring commands.
+type webhookOptions struct {
+	URL      string
+	Format   string
+	Template string
+}
+
+func (o *webhookOptions) registerFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&o.URL, "webhook", "", "POST each changed object to this `URL`")
+	flagSet.StringVar(&o.Format, "webhook-format", "json", "webhook `payload`: json (the event with its message) or slack ({\"text\": message}, for Slack-compatible incoming webhooks)")
+	flagSet.StringVar(&o.Template, "webhook-template", defaultWebhookTemplate, "Go text/template `template` of the webhook message, over the event's .Target, .Class, .Server, .Since and .Changes (each .Path, .Before, .After)")
+}
+
+// notifier returns the webhook the options describe, or nil without
+// -webhook.
+func (o *webhookOptions) notifier() (*webhookNotifier, error) {
+	if o.URL == "" {
+		return nil, nil
+	}
+	if parsed, err := url.Parse(o.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("-webhook: invalid URL %q", o.URL)
+	}
+	if o.Format != "json" && o.Format != "slack" {
+		return nil, fmt.Errorf("-webhook-format: unknown payload %q (want %s)", o.Format, strings.Join(webhookFormats, " or "))
+	}
+	functions := template.FuncMap{"join": strings.Join, "upper": strings.ToUpper, "lower": strings.ToLower}
+	message, err := template.New("webhook").Funcs(functions).Parse(o.Template)
+	if err != nil {
+		return nil, fmt.Errorf("-webhook-template: %v", err)
+	}
+	sample := changeEvent{Changes: []fieldChange{{}}}
+	if err := message.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("-webhook-template: %v", err)
+	}
+	return &webhookNotifier{url: o.URL, format: o.Format, message: message, client: &http.Client{Timeout: webhookTimeout}}, nil
+}
+
+// webhookNotifier delivers change events to a webhook.
+type webhookNotifier struct {
+	url     string
+	format  string
+	message *template.Template
+	client  *http.Client
+}
+
+// notify renders the message of event and POSTs the payload; any status
+// but 2xx fails. A nil notifier does nothing.
+func (n *webhookNotifier) notify(event changeEvent) error {
+	if n == nil {
+		return nil
+	}
+	var message strings.Builder
+	if err := n.message.Execute(&message, event); err != nil {
+		return fmt.Errorf("-webhook-template: %v", err)
+	}
+	event.Message = message.String()
+	var payload any = event
+	if n.format == "slack" {
+		payload = map[string]string{"text": event.Message}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	response, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", n.url, response.Status)
+	}
+	return nil
+}
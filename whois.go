@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// whoisAnswer is what a port-43 WHOIS server said about a target RDAP could
+// not answer (-whois-fallback).
+type whoisAnswer struct {
+	Server  string `json:"server"`
+	Name    string `json:"name,omitempty"`
+	Handle  string `json:"handle,omitempty"`
+	Country string `json:"country,omitempty"`
+}
+
+// whoisServers are the WHOIS servers of the RIRs, by registry name.
+var whoisServers = map[string]string{
+	"ARIN":    "whois.arin.net",
+	"RIPE":    "whois.ripe.net",
+	"APNIC":   "whois.apnic.net",
+	"LACNIC":  "whois.lacnic.net",
+	"AFRINIC": "whois.afrinic.net",
+}
+
+// ianaWhoisServer refers queries for any number resource to the WHOIS
+// server of the registry holding it.
+const ianaWhoisServer = "whois.iana.org"
+
+// maxWhoisResponse bounds how much of a WHOIS answer is read.
+const maxWhoisResponse = 1 << 20
+
+// whoisNameKeys are the WHOIS attributes naming the holder, best first:
+// ARIN's OrgName, RPSL org-name and descr, then the AS name.
+var whoisNameKeys = []string{"orgname", "org-name", "descr", "as-name", "asname"}
+
+// needsWhoisFallback reports whether RDAP left the target unanswered: the
+// registry said it does not exist, or answered without any name.
+func needsWhoisFallback(result lookupResult) bool {
+	if result.Skipped {
+		return false
+	}
+	return resultClass(result.Err) == classNotFound || (result.Err == nil && result.Name == "")
+}
+
+// whoisFallback asks port-43 WHOIS about query ("AS15169", an IP address
+// or prefix) when needsWhoisFallback says RDAP left it unanswered. The
+// server is the record's port43 hint, else the WHOIS server of the
+// registry that answered, else the one IANA refers the query to. When
+// WHOIS names no holder either, result is returned unchanged.
+func whoisFallback(ctx context.Context, client *whoisClient, result lookupResult, query string) lookupResult {
+	if !needsWhoisFallback(result) {
+		return result
+	}
+	server := result.port43()
+	if server == "" {
+		server = whoisServers[result.registry()]
+	}
+	if server == "" {
+		referral, err := client.query(ctx, ianaWhoisServer, query)
+		if err != nil {
+			slog.Warn("whois fallback failed", "target", query, "error", err)
+			return result
+		}
+		if server = whoisReferral(referral); server == "" {
+			slog.Debug("whois fallback: no referral from IANA", "target", query)
+			return result
+		}
+	}
+	response, err := client.query(ctx, server, whoisQueryText(server, query))
+	if err != nil {
+		slog.Warn("whois fallback failed", "target", query, "server", server, "error", err)
+		return result
+	}
+	answer := parseWhois(response)
+	if answer.Name == "" {
+		slog.Debug("whois fallback: no holder named", "target", query, "server", server)
+		return result
+	}
+	answer.Server = server
+	return lookupResult{Name: answer.Name, Whois: &answer, Source: sourceWhoisFallback}
+}
+
+// whoisQueryText returns the query sent to server for query. ARIN needs the
+// object type: "a" for ASNs and "n" for networks; the RPSL servers take
+// the query as is.
+func whoisQueryText(server, query string) string {
+	if !strings.EqualFold(server, whoisServers["ARIN"]) {
+		return query
+	}
+	if number, ok := strings.CutPrefix(strings.ToUpper(query), "AS"); ok {
+		return "a " + number
+	}
+	return "n " + query
+}
+
+// whoisClient sends port-43 queries under the -budget, -rate-limit,
+// -audit-log and -dump-http of the RDAP client options, as the transport
+// chain of newRDAPClient does for RDAP queries.
+type whoisClient struct {
+	network      string
+	budgets      []registryBudget
+	deferQueries bool
+	rate         float64
+	audit        *auditLog
+	dump         io.Writer
+}
+
+func newWhoisClient(options clientOptions) *whoisClient {
+	return &whoisClient{
+		network:      options.network(),
+		budgets:      options.Budgets,
+		deferQueries: options.DeferOverBudget,
+		rate:         options.RateLimit,
+		audit:        options.AuditLog,
+		dump:         options.DumpHTTP,
+	}
+}
+
+// query sends query to server on port 43 and returns the answer. The query
+// counts against the budget of the registry operating server and is paced
+// with the other queries to it.
+func (c *whoisClient) query(ctx context.Context, server, query string) (string, error) {
+	if err := reserveQuery(ctx, whoisRegistry(server), c.budgets, c.deferQueries); err != nil {
+		return "", err
+	}
+	throttle := throttleFor("whois://" + server)
+	if err := sleepContext(ctx, time.Until(throttle.reserve(c.rate, time.Now()))); err != nil {
+		return "", err
+	}
+	response, err := whoisQuery(ctx, c.network, server, query)
+	if c.audit != nil {
+		c.audit.record("WHOIS", "whois://"+server+"/"+url.PathEscape(query), 0, err)
+	}
+	if c.dump != nil {
+		var dump bytes.Buffer
+		fmt.Fprintf(&dump, "===== %s whois://%s\n%s\r\n\n-----\n", time.Now().Format(time.RFC3339Nano), server, query)
+		if err != nil {
+			fmt.Fprintf(&dump, "(no response: %v)\n", err)
+		}
+		dump.WriteString(response)
+		dump.WriteString("\n\n")
+		c.dump.Write(dump.Bytes())
+	}
+	return response, err
+}
+
+// whoisQuery sends query to server on port 43 and returns the answer.
+func whoisQuery(ctx context.Context, network, server, query string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	address := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		address = net.JoinHostPort(server, "43")
+	}
+	connection, err := dialerForNetwork(network)(ctx, network, address)
+	if err != nil {
+		return "", err
+	}
+	defer connection.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		connection.SetDeadline(deadline)
+	}
+	if _, err := io.WriteString(connection, query+"\r\n"); err != nil {
+		return "", fmt.Errorf("%s: %v", server, err)
+	}
+	response, err := io.ReadAll(io.LimitReader(connection, maxWhoisResponse))
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", server, err)
+	}
+	return string(response), nil
+}
+
+// whoisAttributes calls visit with each "key: value" line of a WHOIS
+// answer, the key lowercased; comment lines are skipped.
+func whoisAttributes(response string, visit func(key, value string)) {
+	scanner := bufio.NewScanner(strings.NewReader(response))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "%") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.ContainsAny(key, " \t") {
+			continue
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			visit(strings.ToLower(key), value)
+		}
+	}
+}
+
+// whoisReferral returns the server an IANA answer refers to.
+func whoisReferral(response string) string {
+	var server string
+	whoisAttributes(response, func(key, value string) {
+		if key == "refer" && server == "" {
+			server = value
+		}
+	})
+	return server
+}
+
+// parseWhois extracts the holder's name, the handle and the country from a
+// WHOIS answer, keeping the first value of each attribute.
+func parseWhois(response string) whoisAnswer {
+	first := map[string]string{}
+	whoisAttributes(response, func(key, value string) {
+		if _, seen := first[key]; !seen {
+			first[key] = value
+		}
+	})
+	var answer whoisAnswer
+	for _, key := range whoisNameKeys {
+		if name := first[key]; name != "" {
+			answer.Name = name
+			break
+		}
+	}
+	for _, key := range []string{"ashandle", "aut-num", "nethandle", "inetnum", "inet6num"} {
+		if handle := first[key]; handle != "" {
+			answer.Handle = handle
+			break
+		}
+	}
+	answer.Country = strings.ToUpper(first["country"])
+	return answer
+}
+
+// whoisRegistry names the RIR operating a WHOIS server, or returns the
+// server itself.
+func whoisRegistry(server string) string {
+	for registry, known := range whoisServers {
+		if strings.EqualFold(server, known) {
+			return registry
+		}
+	}
+	return server
+}